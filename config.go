@@ -7,8 +7,10 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"reflect"
 	"time"
 
+	"github.com/9seconds/topographer/topolib"
 	"github.com/hjson/hjson-go"
 )
 
@@ -20,6 +22,17 @@ const (
 	DefaultCircuitBreakerOpenThreshold        = 5
 	DefaultCircuitBreakerHalfOpenTimeout      = time.Minute
 	DefaultCircuitBreakerResetFailuresTimeout = 20 * time.Second
+	DefaultVoteWeight                         = topolib.DefaultVoteWeight
+	DefaultGeoClusterRadius                   = topolib.DefaultGeoClusterRadius
+	DefaultReadyMinProviders                  = topolib.DefaultReadyMinProviders
+	DefaultResultCacheTTL                     = time.Hour
+	DefaultNegativeCacheTTL                   = time.Minute
+	DefaultTierConfidence                     = topolib.DefaultTierConfidence
+)
+
+const (
+	MergeModeMajority   = "majority"
+	MergeModeGeoCluster = "geo_cluster"
 )
 
 type duration struct {
@@ -49,12 +62,30 @@ func (d *duration) UnmarshalJSON(b []byte) error {
 }
 
 type config struct {
-	Listen            string           `json:"listen"`
-	RootDirectory     string           `json:"root_directory"`
-	WorkerPoolSize    uint             `json:"worker_pool_size"`
-	BasicAuthUser     string           `json:"basic_auth_user"`
-	BasicAuthPassword string           `json:"basic_auth_password"`
-	Providers         []configProvider `json:"providers"`
+	Listen               string           `json:"listen"`
+	RootDirectory        string           `json:"root_directory"`
+	WorkerPoolSize       uint             `json:"worker_pool_size"`
+	BasicAuthUser        string           `json:"basic_auth_user"`
+	BasicAuthPassword    string           `json:"basic_auth_password"`
+	MergeMode            string           `json:"merge_mode"`
+	GeoClusterRadius     float64          `json:"geo_cluster_radius"`
+	TrustedProxies       []string         `json:"trusted_proxies"`
+	TrustedProxyHeader   string           `json:"trusted_proxy_header"`
+	ProxyProtocol        bool             `json:"proxy_protocol"`
+	ProxyProtocolSources []string         `json:"proxy_protocol_sources"`
+	ReadyMinProviders    uint             `json:"ready_min_providers"`
+	ReadyProviders       []string         `json:"ready_providers"`
+	ResultCacheSize      uint             `json:"result_cache_size"`
+	ResultCacheTTL       duration         `json:"result_cache_ttl"`
+	ResultCacheIPv4      uint             `json:"result_cache_ipv4_prefix"`
+	ResultCacheIPv6      uint             `json:"result_cache_ipv6_prefix"`
+	NegativeCacheSize    uint             `json:"negative_cache_size"`
+	NegativeCacheTTL     duration         `json:"negative_cache_ttl"`
+	LookupDeadline       duration         `json:"lookup_deadline"`
+	EarlyVerdict         bool             `json:"early_verdict"`
+	EarlyVerdictQuorum   uint             `json:"early_verdict_quorum"`
+	TierConfidence       float64          `json:"tier_confidence"`
+	Providers            []configProvider `json:"providers"`
 }
 
 func (c config) GetListen() string {
@@ -81,14 +112,138 @@ func (c config) GetBasicAuthPassword() []byte {
 	return []byte(c.BasicAuthPassword)
 }
 
-func (c config) HasBasicAuth() bool {
-	return c.BasicAuthUser != "" || c.BasicAuthPassword != ""
+func (c config) GetMergeMode() string {
+	if c.MergeMode == "" {
+		return MergeModeMajority
+	}
+
+	return c.MergeMode
+}
+
+func (c config) GetGeoClusterRadius() float64 {
+	if c.GeoClusterRadius == 0 {
+		return DefaultGeoClusterRadius
+	}
+
+	return c.GeoClusterRadius
+}
+
+func (c config) GetTrustedProxies() []*net.IPNet {
+	rv := make([]*net.IPNet, 0, len(c.TrustedProxies))
+
+	for _, v := range c.TrustedProxies {
+		if network, err := parseNetwork(v); err == nil {
+			rv = append(rv, network)
+		}
+	}
+
+	return rv
+}
+
+func (c config) GetTrustedProxyHeader() string {
+	return c.TrustedProxyHeader
+}
+
+func (c config) UseProxyProtocol() bool {
+	return c.ProxyProtocol
+}
+
+func (c config) GetProxyProtocolSources() []*net.IPNet {
+	rv := make([]*net.IPNet, 0, len(c.ProxyProtocolSources))
+
+	for _, v := range c.ProxyProtocolSources {
+		if network, err := parseNetwork(v); err == nil {
+			rv = append(rv, network)
+		}
+	}
+
+	return rv
+}
+
+// GetReadyMinProviders returns a number of providers which have to be
+// ready. If it is not set but a list of required providers is, only
+// this list is taken into account.
+func (c config) GetReadyMinProviders() int {
+	switch {
+	case c.ReadyMinProviders != 0:
+		return int(c.ReadyMinProviders)
+	case len(c.ReadyProviders) != 0:
+		return 0
+	}
+
+	return DefaultReadyMinProviders
+}
+
+func (c config) GetReadyProviders() []string {
+	return c.ReadyProviders
+}
+
+func (c config) GetResultCacheSize() uint {
+	return c.ResultCacheSize
+}
+
+func (c config) GetResultCacheTTL() time.Duration {
+	if c.ResultCacheTTL.Duration == 0 {
+		return DefaultResultCacheTTL
+	}
+
+	return c.ResultCacheTTL.Duration
+}
+
+func (c config) GetResultCacheIPv4Prefix() int {
+	return int(c.ResultCacheIPv4)
+}
+
+func (c config) GetResultCacheIPv6Prefix() int {
+	return int(c.ResultCacheIPv6)
+}
+
+func (c config) GetNegativeCacheSize() uint {
+	return c.NegativeCacheSize
+}
+
+func (c config) GetNegativeCacheTTL() time.Duration {
+	if c.NegativeCacheTTL.Duration == 0 {
+		return DefaultNegativeCacheTTL
+	}
+
+	return c.NegativeCacheTTL.Duration
+}
+
+func (c config) GetLookupDeadline() time.Duration {
+	return c.LookupDeadline.Duration
+}
+
+func (c config) UseEarlyVerdict() bool {
+	return c.EarlyVerdict
+}
+
+func (c config) GetEarlyVerdictQuorum() int {
+	return int(c.EarlyVerdictQuorum)
+}
+
+func (c config) GetTierConfidence() float64 {
+	if c.TierConfidence == 0 {
+		return DefaultTierConfidence
+	}
+
+	return c.TierConfidence
 }
 
 func (c config) GetProviders() []configProvider {
 	return c.Providers
 }
 
+// restartRequired checks if another config has changes which cannot be
+// applied without a restart. Only providers and basic auth credentials
+// can be reloaded.
+func (c config) restartRequired(another config) bool {
+	c.BasicAuthUser, c.BasicAuthPassword, c.Providers = "", "", nil
+	another.BasicAuthUser, another.BasicAuthPassword, another.Providers = "", "", nil
+
+	return !reflect.DeepEqual(c, another)
+}
+
 type configProvider struct {
 	Name                               string            `json:"name"`
 	Directory                          string            `json:"directory"`
@@ -99,6 +254,9 @@ type configProvider struct {
 	CircuitBreakerResetFailuresTimeout duration          `json:"circuit_breaker_reset_failures_timeout"`
 	UpdateEvery                        duration          `json:"update_every"`
 	HTTPTimeout                        duration          `json:"http_timeout"`
+	VoteWeight                         float64           `json:"vote_weight"`
+	SoftTimeout                        duration          `json:"soft_timeout"`
+	Tier                               uint              `json:"tier"`
 	SpecificParameters                 map[string]string `json:"specific_parameters"`
 }
 
@@ -170,6 +328,22 @@ func (c configProvider) GetHTTPTimeout() time.Duration {
 	return c.HTTPTimeout.Duration
 }
 
+func (c configProvider) GetVoteWeight() float64 {
+	if c.VoteWeight == 0 {
+		return DefaultVoteWeight
+	}
+
+	return c.VoteWeight
+}
+
+func (c configProvider) GetSoftTimeout() time.Duration {
+	return c.SoftTimeout.Duration
+}
+
+func (c configProvider) GetTier() int {
+	return int(c.Tier)
+}
+
 func (c configProvider) GetSpecificParameters() map[string]string {
 	if c.SpecificParameters == nil {
 		return map[string]string{}
@@ -204,6 +378,64 @@ func parseConfig(path string) (*config, error) {
 		return nil, fmt.Errorf("incorrect root directory: %w", err)
 	}
 
+	switch conf.GetMergeMode() {
+	case MergeModeMajority, MergeModeGeoCluster:
+	default:
+		return nil, fmt.Errorf("unknown merge mode %s", conf.GetMergeMode())
+	}
+
+	if conf.GetGeoClusterRadius() < 0 {
+		return nil, fmt.Errorf("geo cluster radius is negative")
+	}
+
+	for _, v := range conf.TrustedProxies {
+		if _, err := parseNetwork(v); err != nil {
+			return nil, fmt.Errorf("incorrect trusted proxy %s: %w", v, err)
+		}
+	}
+
+	if len(conf.TrustedProxies) > 0 && conf.GetTrustedProxyHeader() == "" {
+		return nil, fmt.Errorf("trusted proxies are set but their header is not")
+	}
+
+	for _, v := range conf.ProxyProtocolSources {
+		if _, err := parseNetwork(v); err != nil {
+			return nil, fmt.Errorf("incorrect source of PROXY protocol %s: %w", v, err)
+		}
+	}
+
+	if conf.UseProxyProtocol() && len(conf.ProxyProtocolSources) == 0 {
+		return nil, fmt.Errorf("PROXY protocol is enabled but no sources are trusted")
+	}
+
+	if conf.GetResultCacheTTL() < 0 {
+		return nil, fmt.Errorf("result cache TTL is negative")
+	}
+
+	if conf.GetResultCacheIPv4Prefix() > 8*net.IPv4len {
+		return nil, fmt.Errorf("incorrect IPv4 prefix length of result cache")
+	}
+
+	if conf.GetResultCacheIPv6Prefix() > 8*net.IPv6len {
+		return nil, fmt.Errorf("incorrect IPv6 prefix length of result cache")
+	}
+
+	if conf.GetNegativeCacheTTL() < 0 {
+		return nil, fmt.Errorf("negative cache TTL is negative")
+	}
+
+	if conf.GetLookupDeadline() < 0 {
+		return nil, fmt.Errorf("lookup deadline is negative")
+	}
+
+	if conf.GetEarlyVerdictQuorum() > len(conf.Providers) {
+		return nil, fmt.Errorf("early_verdict_quorum is bigger than a number of providers")
+	}
+
+	if confidence := conf.GetTierConfidence(); confidence < 0 || confidence > 1 {
+		return nil, fmt.Errorf("tier_confidence has to be in [0, 1] range")
+	}
+
 	seenProviderNames := map[string]struct{}{}
 	seenDirectories := map[string]struct{}{}
 
@@ -219,7 +451,46 @@ func parseConfig(path string) (*config, error) {
 		}
 
 		seenDirectories[v.GetDirectory()] = struct{}{}
+
+		if v.GetVoteWeight() < 0 {
+			return nil, fmt.Errorf("Vote weight of %s is negative", v.GetName())
+		}
+
+		if v.GetSoftTimeout() < 0 {
+			return nil, fmt.Errorf("Soft timeout of %s is negative", v.GetName())
+		}
+	}
+
+	for _, v := range conf.ReadyProviders {
+		if _, ok := seenProviderNames[v]; !ok {
+			return nil, fmt.Errorf("ready provider %s is not configured", v)
+		}
+	}
+
+	if int(conf.ReadyMinProviders) > len(conf.Providers) {
+		return nil, fmt.Errorf("ready_min_providers is bigger than a number of providers")
 	}
 
 	return &conf, nil
 }
+
+// parseNetwork parses a network from the config. It could be either
+// CIDR or a single IP address.
+func parseNetwork(value string) (*net.IPNet, error) {
+	if ip := net.ParseIP(value); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+			bits = 8 * net.IPv4len
+		}
+
+		return &net.IPNet{
+			IP:   ip,
+			Mask: net.CIDRMask(bits, bits),
+		}, nil
+	}
+
+	_, network, err := net.ParseCIDR(value)
+
+	return network, err
+}
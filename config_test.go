@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ConfigTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ConfigTestSuite) TestRestartRequired() {
+	base := config{
+		Listen:            "127.0.0.1:8000",
+		RootDirectory:     "/tmp/topographer",
+		BasicAuthUser:     "user",
+		BasicAuthPassword: "password",
+		ReadyProviders:    []string{"ip2c"},
+		ResultCacheTTL:    duration{time.Hour},
+		Providers: []configProvider{
+			{Name: "ip2c"},
+		},
+	}
+
+	testData := map[string]struct {
+		change   func(*config)
+		expected bool
+	}{
+		"nothing": {
+			change:   func(*config) {},
+			expected: false,
+		},
+		"basic auth": {
+			change: func(c *config) {
+				c.BasicAuthUser = "another"
+				c.BasicAuthPassword = ""
+			},
+			expected: false,
+		},
+		"providers": {
+			change: func(c *config) {
+				c.Providers = []configProvider{
+					{Name: "ip2c", VoteWeight: 2},
+					{Name: "ipinfo"},
+				}
+			},
+			expected: false,
+		},
+		"listen": {
+			change: func(c *config) {
+				c.Listen = "127.0.0.1:8001"
+			},
+			expected: true,
+		},
+		"ready providers": {
+			change: func(c *config) {
+				c.ReadyProviders = nil
+			},
+			expected: true,
+		},
+		"duration": {
+			change: func(c *config) {
+				c.ResultCacheTTL = duration{time.Minute}
+			},
+			expected: true,
+		},
+		"trusted proxies": {
+			change: func(c *config) {
+				c.TrustedProxies = []string{"10.0.0.0/8"}
+			},
+			expected: true,
+		},
+	}
+
+	for name, testCase := range testData {
+		another := base
+		another.ReadyProviders = append([]string{}, base.ReadyProviders...)
+		another.Providers = append([]configProvider{}, base.Providers...)
+
+		testCase.change(&another)
+
+		suite.Equal(testCase.expected, base.restartRequired(another), name)
+		suite.Equal(testCase.expected, another.restartRequired(base), name)
+	}
+
+	suite.Equal("user", base.BasicAuthUser)
+	suite.Len(base.Providers, 1)
+}
+
+func TestConfig(t *testing.T) {
+	suite.Run(t, &ConfigTestSuite{})
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	proxyProtocolV1Prefix    = "PROXY "
+	proxyProtocolV1MaxLength = 107
+
+	proxyProtocolV2CommandLocal = 0x0
+	proxyProtocolV2CommandProxy = 0x1
+	proxyProtocolV2FamilyTCP4   = 0x11
+	proxyProtocolV2FamilyTCP6   = 0x21
+	proxyProtocolV2HeaderLength = 16
+
+	proxyProtocolReaderSize = 256
+)
+
+var (
+	proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	errProxyProtocolNoHeader = errors.New("no PROXY protocol header")
+)
+
+// proxyProtocolListener accepts connections only from trusted sources
+// and expects each connection to start with PROXY protocol header (v1
+// or v2). An address from the header is reported as RemoteAddr of the
+// connection.
+type proxyProtocolListener struct {
+	net.Listener
+
+	sources       []*net.IPNet
+	headerTimeout time.Duration
+}
+
+func (p *proxyProtocolListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := p.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.isTrusted(conn.RemoteAddr()) {
+			return &proxyProtocolConn{
+				Conn:          conn,
+				reader:        bufio.NewReaderSize(conn, proxyProtocolReaderSize),
+				headerTimeout: p.headerTimeout,
+			}, nil
+		}
+
+		conn.Close()
+	}
+}
+
+func (p *proxyProtocolListener) isTrusted(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+
+	for _, v := range p.sources {
+		if v.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// proxyProtocolConn reads PROXY protocol header lazily, on the first
+// Read or RemoteAddr call. So a slow client does not block Accept.
+// If header is incorrect, connection is closed.
+type proxyProtocolConn struct {
+	net.Conn
+
+	reader        *bufio.Reader
+	headerTimeout time.Duration
+	headerOnce    sync.Once
+	headerErr     error
+	remoteAddr    net.Addr
+}
+
+func (p *proxyProtocolConn) Read(b []byte) (int, error) {
+	p.init()
+
+	if p.headerErr != nil {
+		return 0, p.headerErr
+	}
+
+	return p.reader.Read(b)
+}
+
+func (p *proxyProtocolConn) RemoteAddr() net.Addr {
+	p.init()
+
+	if p.remoteAddr != nil {
+		return p.remoteAddr
+	}
+
+	return p.Conn.RemoteAddr()
+}
+
+func (p *proxyProtocolConn) init() {
+	p.headerOnce.Do(func() {
+		p.Conn.SetReadDeadline(time.Now().Add(p.headerTimeout)) // nolint: errcheck
+
+		if err := p.readHeader(); err != nil {
+			p.headerErr = fmt.Errorf("cannot read PROXY protocol header: %w", err)
+			p.Conn.Close()
+
+			return
+		}
+
+		p.Conn.SetReadDeadline(time.Time{}) // nolint: errcheck
+	})
+}
+
+func (p *proxyProtocolConn) readHeader() error {
+	signature, err := p.reader.Peek(len(proxyProtocolV2Signature))
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case bytes.Equal(signature, proxyProtocolV2Signature):
+		return p.readHeaderV2()
+	case bytes.HasPrefix(signature, []byte(proxyProtocolV1Prefix)):
+		return p.readHeaderV1()
+	}
+
+	return errProxyProtocolNoHeader
+}
+
+func (p *proxyProtocolConn) readHeaderV1() error {
+	line, err := p.reader.ReadSlice('\n')
+
+	switch {
+	case errors.Is(err, bufio.ErrBufferFull):
+		return errors.New("header is too long")
+	case err != nil:
+		return err
+	case len(line) > proxyProtocolV1MaxLength || !bytes.HasSuffix(line, []byte("\r\n")):
+		return errors.New("header is malformed")
+	}
+
+	fields := strings.Fields(string(line))
+
+	switch {
+	case len(fields) >= 2 && fields[1] == "UNKNOWN":
+		return nil
+	case len(fields) != 6:
+		return errors.New("incorrect number of fields")
+	case fields[1] != "TCP4" && fields[1] != "TCP6":
+		return fmt.Errorf("unknown protocol %s", fields[1])
+	}
+
+	ip := net.ParseIP(fields[2])
+	if ip == nil || (ip.To4() != nil) != (fields[1] == "TCP4") {
+		return fmt.Errorf("incorrect source address %s", fields[2])
+	}
+
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return fmt.Errorf("incorrect source port %s: %w", fields[4], err)
+	}
+
+	p.remoteAddr = &net.TCPAddr{
+		IP:   ip,
+		Port: int(port),
+	}
+
+	return nil
+}
+
+func (p *proxyProtocolConn) readHeaderV2() error {
+	header := make([]byte, proxyProtocolV2HeaderLength)
+
+	if _, err := io.ReadFull(p.reader, header); err != nil {
+		return err
+	}
+
+	if header[12]>>4 != 2 {
+		return fmt.Errorf("unsupported version %d", header[12]>>4)
+	}
+
+	length := int(binary.BigEndian.Uint16(header[14:]))
+	payload := make([]byte, length)
+
+	if _, err := io.ReadFull(p.reader, payload); err != nil {
+		return err
+	}
+
+	switch header[12] & 0xf {
+	case proxyProtocolV2CommandLocal:
+		return nil
+	case proxyProtocolV2CommandProxy:
+	default:
+		return fmt.Errorf("unsupported command %d", header[12]&0xf)
+	}
+
+	addrLength := 0
+
+	switch header[13] {
+	case proxyProtocolV2FamilyTCP4:
+		addrLength = net.IPv4len
+	case proxyProtocolV2FamilyTCP6:
+		addrLength = net.IPv6len
+	default:
+		// UDP or unix sockets: keep the address of the peer.
+		return nil
+	}
+
+	if length < 2*addrLength+4 {
+		return errors.New("address block is too short")
+	}
+
+	p.remoteAddr = &net.TCPAddr{
+		IP:   net.IP(append([]byte{}, payload[:addrLength]...)),
+		Port: int(binary.BigEndian.Uint16(payload[2*addrLength:])),
+	}
+
+	return nil
+}
+
+func newProxyProtocolListener(listener net.Listener,
+	sources []*net.IPNet,
+	headerTimeout time.Duration) net.Listener {
+	return &proxyProtocolListener{
+		Listener:      listener,
+		sources:       sources,
+		headerTimeout: headerTimeout,
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const proxyProtocolTestPayload = "GET / HTTP/1.0\r\n\r\n"
+
+func makeProxyProtocolV2Header(command, family byte, addresses []byte) []byte {
+	header := append([]byte{}, proxyProtocolV2Signature...)
+	header = append(header, 0x20|command, family, 0, 0)
+
+	binary.BigEndian.PutUint16(header[14:], uint16(len(addresses)))
+
+	return append(header, addresses...)
+}
+
+func makeProxyProtocolV2Addresses(src, dst net.IP, srcPort, dstPort uint16) []byte {
+	rv := append([]byte{}, src...)
+	rv = append(rv, dst...)
+	rv = append(rv, 0, 0, 0, 0)
+
+	binary.BigEndian.PutUint16(rv[len(rv)-4:], srcPort)
+	binary.BigEndian.PutUint16(rv[len(rv)-2:], dstPort)
+
+	return rv
+}
+
+type ProxyProtocolTestSuite struct {
+	suite.Suite
+}
+
+// makeConn returns a connection with PROXY protocol and its client
+// side. Address of the client is "pipe".
+func (suite *ProxyProtocolTestSuite) makeConn(headerTimeout time.Duration) (net.Conn, *proxyProtocolConn) {
+	client, server := net.Pipe()
+
+	return client, &proxyProtocolConn{
+		Conn:          server,
+		reader:        bufio.NewReaderSize(server, proxyProtocolReaderSize),
+		headerTimeout: headerTimeout,
+	}
+}
+
+func (suite *ProxyProtocolTestSuite) send(client net.Conn, data []byte) {
+	go func() {
+		client.Write(data) // nolint: errcheck
+		client.Close()
+	}()
+}
+
+func (suite *ProxyProtocolTestSuite) TestHeaders() {
+	testData := map[string]struct {
+		data     []byte
+		expected string
+		isErr    bool
+		// truncated means that connection is closed right after
+		// data, without a payload.
+		truncated bool
+	}{
+		"v1 tcp4": {
+			data:     []byte("PROXY TCP4 192.168.1.1 10.0.0.1 56324 443\r\n"),
+			expected: "192.168.1.1:56324",
+		},
+		"v1 tcp6": {
+			data:     []byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n"),
+			expected: "[2001:db8::1]:56324",
+		},
+		"v1 unknown": {
+			data:     []byte("PROXY UNKNOWN\r\n"),
+			expected: "pipe",
+		},
+		"v1 unknown with addresses": {
+			data:     []byte("PROXY UNKNOWN ffff::1 ffff::2 1 2\r\n"),
+			expected: "pipe",
+		},
+		"v1 tcp4 with ipv6 address": {
+			data:  []byte("PROXY TCP4 2001:db8::1 10.0.0.1 56324 443\r\n"),
+			isErr: true,
+		},
+		"v1 incorrect address": {
+			data:  []byte("PROXY TCP4 192.168.1 10.0.0.1 56324 443\r\n"),
+			isErr: true,
+		},
+		"v1 incorrect port": {
+			data:  []byte("PROXY TCP4 192.168.1.1 10.0.0.1 65536 443\r\n"),
+			isErr: true,
+		},
+		"v1 unknown protocol": {
+			data:  []byte("PROXY UDP4 192.168.1.1 10.0.0.1 56324 443\r\n"),
+			isErr: true,
+		},
+		"v1 incorrect number of fields": {
+			data:  []byte("PROXY TCP4 192.168.1.1 10.0.0.1 56324\r\n"),
+			isErr: true,
+		},
+		"v1 no crlf": {
+			data:  []byte("PROXY TCP4 192.168.1.1 10.0.0.1 56324 443\n"),
+			isErr: true,
+		},
+		"v1 truncated": {
+			data:      []byte("PROXY TCP4 192.168.1.1 10.0.0.1"),
+			isErr:     true,
+			truncated: true,
+		},
+		"v1 oversized": {
+			data:  []byte("PROXY TCP4 192.168.1.1 10.0.0.1 56324 443" + strings.Repeat(" ", 100) + "\r\n"),
+			isErr: true,
+		},
+		"v1 too long": {
+			data:  []byte("PROXY " + strings.Repeat("A", 2*proxyProtocolReaderSize) + "\r\n"),
+			isErr: true,
+		},
+		"bad signature": {
+			data:  []byte(proxyProtocolTestPayload),
+			isErr: true,
+		},
+		"v2 proxy tcp4": {
+			data: makeProxyProtocolV2Header(proxyProtocolV2CommandProxy,
+				proxyProtocolV2FamilyTCP4,
+				makeProxyProtocolV2Addresses(net.IPv4(192, 168, 1, 1).To4(),
+					net.IPv4(10, 0, 0, 1).To4(), 56324, 443)),
+			expected: "192.168.1.1:56324",
+		},
+		"v2 proxy tcp6": {
+			data: makeProxyProtocolV2Header(proxyProtocolV2CommandProxy,
+				proxyProtocolV2FamilyTCP6,
+				makeProxyProtocolV2Addresses(net.ParseIP("2001:db8::1"),
+					net.ParseIP("2001:db8::2"), 56324, 443)),
+			expected: "[2001:db8::1]:56324",
+		},
+		"v2 proxy tcp4 with tlvs": {
+			data: makeProxyProtocolV2Header(proxyProtocolV2CommandProxy,
+				proxyProtocolV2FamilyTCP4,
+				append(makeProxyProtocolV2Addresses(net.IPv4(192, 168, 1, 1).To4(),
+					net.IPv4(10, 0, 0, 1).To4(), 56324, 443), 0x4, 0, 1, 0)),
+			expected: "192.168.1.1:56324",
+		},
+		"v2 proxy udp": {
+			data: makeProxyProtocolV2Header(proxyProtocolV2CommandProxy, 0x12,
+				makeProxyProtocolV2Addresses(net.IPv4(192, 168, 1, 1).To4(),
+					net.IPv4(10, 0, 0, 1).To4(), 56324, 443)),
+			expected: "pipe",
+		},
+		"v2 local": {
+			data:     makeProxyProtocolV2Header(proxyProtocolV2CommandLocal, 0, nil),
+			expected: "pipe",
+		},
+		"v2 unsupported command": {
+			data:  makeProxyProtocolV2Header(0x2, 0, nil),
+			isErr: true,
+		},
+		"v2 unsupported version": {
+			data: func() []byte {
+				header := makeProxyProtocolV2Header(proxyProtocolV2CommandLocal, 0, nil)
+				header[12] = 0x10 | proxyProtocolV2CommandLocal
+
+				return header
+			}(),
+			isErr: true,
+		},
+		"v2 short address block": {
+			data: makeProxyProtocolV2Header(proxyProtocolV2CommandProxy,
+				proxyProtocolV2FamilyTCP4, net.IPv4(192, 168, 1, 1).To4()),
+			isErr: true,
+		},
+		"v2 truncated header": {
+			data:      makeProxyProtocolV2Header(proxyProtocolV2CommandLocal, 0, nil)[:14],
+			isErr:     true,
+			truncated: true,
+		},
+		"v2 truncated addresses": {
+			data: makeProxyProtocolV2Header(proxyProtocolV2CommandProxy,
+				proxyProtocolV2FamilyTCP4,
+				makeProxyProtocolV2Addresses(net.IPv4(192, 168, 1, 1).To4(),
+					net.IPv4(10, 0, 0, 1).To4(), 56324, 443))[:20],
+			isErr:     true,
+			truncated: true,
+		},
+		"v2 oversized": {
+			data: func() []byte {
+				header := makeProxyProtocolV2Header(proxyProtocolV2CommandLocal, 0, nil)
+				binary.BigEndian.PutUint16(header[14:], 0xffff)
+
+				return header
+			}(),
+			isErr: true,
+		},
+	}
+
+	for name, testCase := range testData {
+		client, conn := suite.makeConn(time.Second)
+
+		data := append([]byte{}, testCase.data...)
+
+		if !testCase.truncated {
+			data = append(data, proxyProtocolTestPayload...)
+		}
+
+		suite.send(client, data)
+
+		data, err := ioutil.ReadAll(conn)
+
+		if testCase.isErr {
+			suite.Error(err, name)
+			suite.Empty(data, name)
+			suite.Equal("pipe", conn.RemoteAddr().String(), name)
+		} else {
+			suite.NoError(err, name)
+			suite.Equal(proxyProtocolTestPayload, string(data), name)
+			suite.Equal(testCase.expected, conn.RemoteAddr().String(), name)
+		}
+
+		conn.Close()
+	}
+}
+
+func (suite *ProxyProtocolTestSuite) TestHeaderTimeout() {
+	client, conn := suite.makeConn(100 * time.Millisecond)
+
+	defer client.Close()
+
+	go func() {
+		client.Write([]byte("PROXY TCP4 ")) // nolint: errcheck
+	}()
+
+	started := time.Now()
+	_, err := conn.Read(make([]byte, 1))
+
+	suite.True(errors.Is(err, os.ErrDeadlineExceeded))
+	suite.Less(int64(time.Since(started)), int64(time.Second))
+}
+
+func (suite *ProxyProtocolTestSuite) TestHeaderIsReadOnce() {
+	client, conn := suite.makeConn(time.Second)
+
+	defer conn.Close()
+
+	suite.send(client, []byte("PROXY TCP4 192.168.1.1 10.0.0.1 56324 443\r\n"+proxyProtocolTestPayload))
+
+	suite.Equal("192.168.1.1:56324", conn.RemoteAddr().String())
+
+	data, err := ioutil.ReadAll(conn)
+
+	suite.NoError(err)
+	suite.Equal(proxyProtocolTestPayload, string(data))
+	suite.Equal("192.168.1.1:56324", conn.RemoteAddr().String())
+}
+
+func TestProxyProtocol(t *testing.T) {
+	suite.Run(t, &ProxyProtocolTestSuite{})
+}
+
+type ProxyProtocolListenerTestSuite struct {
+	suite.Suite
+
+	listener net.Listener
+}
+
+func (suite *ProxyProtocolListenerTestSuite) SetupTest() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	suite.Require().NoError(err)
+
+	suite.listener = listener
+}
+
+func (suite *ProxyProtocolListenerTestSuite) TearDownTest() {
+	suite.listener.Close()
+}
+
+func (suite *ProxyProtocolListenerTestSuite) accept(sources ...string) <-chan net.Conn {
+	networks := []*net.IPNet{}
+
+	for _, v := range sources {
+		network, err := parseNetwork(v)
+
+		suite.Require().NoError(err)
+
+		networks = append(networks, network)
+	}
+
+	listener := newProxyProtocolListener(suite.listener, networks, time.Second)
+	rv := make(chan net.Conn, 1)
+
+	go func() {
+		defer close(rv)
+
+		if conn, err := listener.Accept(); err == nil {
+			rv <- conn
+		}
+	}()
+
+	return rv
+}
+
+func (suite *ProxyProtocolListenerTestSuite) dial() net.Conn {
+	conn, err := net.Dial("tcp", suite.listener.Addr().String())
+
+	suite.Require().NoError(err)
+
+	_, err = conn.Write([]byte("PROXY TCP4 192.168.1.1 10.0.0.1 56324 443\r\n" + proxyProtocolTestPayload))
+
+	suite.NoError(err)
+
+	return conn
+}
+
+func (suite *ProxyProtocolListenerTestSuite) TestTrusted() {
+	accepted := suite.accept("10.0.0.0/8", "127.0.0.1")
+	client := suite.dial()
+
+	defer client.Close()
+
+	conn := <-accepted
+
+	suite.Require().NotNil(conn)
+
+	defer conn.Close()
+
+	data := make([]byte, len(proxyProtocolTestPayload))
+	_, err := conn.Read(data)
+
+	suite.NoError(err)
+	suite.Equal(proxyProtocolTestPayload, string(data))
+	suite.Equal("192.168.1.1:56324", conn.RemoteAddr().String())
+}
+
+func (suite *ProxyProtocolListenerTestSuite) TestUntrusted() {
+	accepted := suite.accept("10.0.0.0/8")
+	client := suite.dial()
+
+	defer client.Close()
+
+	client.SetReadDeadline(time.Now().Add(time.Second)) // nolint: errcheck
+
+	_, err := client.Read(make([]byte, 1))
+
+	suite.Error(err)
+
+	var netErr net.Error
+
+	suite.False(errors.As(err, &netErr) && netErr.Timeout(),
+		"connection of untrusted source has to be closed")
+
+	suite.listener.Close()
+
+	suite.Nil(<-accepted)
+}
+
+func TestProxyProtocolListener(t *testing.T) {
+	suite.Run(t, &ProxyProtocolListenerTestSuite{})
+}
@@ -4,13 +4,14 @@ import (
 	"net"
 	"os"
 
-	"github.com/9seconds/topographer/topolib"
 	"github.com/rs/zerolog"
 )
 
 type logger struct {
-	lookupLog zerolog.Logger
-	updateLog zerolog.Logger
+	lookupLog         zerolog.Logger
+	updateLog         zerolog.Logger
+	circuitBreakerLog zerolog.Logger
+	reloadLog         zerolog.Logger
 }
 
 func (l *logger) LookupError(ip net.IP, name string, err error) {
@@ -25,11 +26,39 @@ func (l *logger) UpdateError(name string, err error) {
 	l.updateLog.Error().Str("provider", name).Err(err).Msg("")
 }
 
-func newLogger() topolib.Logger {
+func (l *logger) CircuitBreakerStateChanged(name string, from, to string) {
+	l.circuitBreakerLog.Warn().
+		Str("provider", name).
+		Str("from", from).
+		Str("to", to).
+		Msg("Circuit breaker has changed its state")
+}
+
+func (l *logger) ReloadInfo(msg string) {
+	l.reloadLog.Info().Msg(msg)
+}
+
+func (l *logger) ReloadProviderInfo(name, msg string) {
+	l.reloadLog.Info().Str("provider", name).Msg(msg)
+}
+
+func (l *logger) ReloadError(name string, err error) {
+	event := l.reloadLog.Error()
+
+	if name != "" {
+		event = event.Str("provider", name)
+	}
+
+	event.Err(err).Msg("")
+}
+
+func newLogger() *logger {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 
 	return &logger{
-		lookupLog: zerolog.New(os.Stderr).With().Timestamp().Stack().Str("event_name", "lookup").Logger(),
-		updateLog: zerolog.New(os.Stderr).With().Timestamp().Stack().Str("event_name", "update").Logger(),
+		lookupLog:         zerolog.New(os.Stderr).With().Timestamp().Stack().Str("event_name", "lookup").Logger(),
+		updateLog:         zerolog.New(os.Stderr).With().Timestamp().Stack().Str("event_name", "update").Logger(),
+		circuitBreakerLog: zerolog.New(os.Stderr).With().Timestamp().Str("event_name", "circuit_breaker").Logger(),
+		reloadLog:         zerolog.New(os.Stderr).With().Timestamp().Str("event_name", "reload").Logger(),
 	}
 }
@@ -55,25 +55,28 @@ func mainFunc() error {
 		return fmt.Errorf("cannot create root directory %s: %w", conf.GetRootDirectory(), err)
 	}
 
-	providers, err := makeProviders(conf)
+	httpClients := makeHTTPClients(conf)
+
+	providers, err := makeProviders(conf, httpClients)
 	if err != nil {
 		return fmt.Errorf("cannot initialise a list of providers: %w", err)
 	}
 
-	topo, err := topolib.NewTopographer(providers, newLogger(), conf.GetWorkerPoolSize())
+	logger := newLogger()
+
+	topo, err := topolib.NewTopographer(providers,
+		logger,
+		conf.GetWorkerPoolSize(),
+		makeTopographerOptions(conf, httpClients)...)
 	if err != nil {
 		return fmt.Errorf("cannot initialize topographer: %w", err)
 	}
 
-	var httpHandler http.Handler = topo
-
-	if conf.HasBasicAuth() {
-		httpHandler = &basicAuthMiddleware{
-			handler:  httpHandler,
-			user:     conf.GetBasicAuthUser(),
-			password: conf.GetBasicAuthPassword(),
-		}
-	}
+	// basic auth is always set up so credentials could be enabled on
+	// reload. Empty credentials mean that auth is disabled.
+	httpHandler := newBasicAuthMiddleware(topo,
+		conf.GetBasicAuthUser(),
+		conf.GetBasicAuthPassword())
 
 	srv := &http.Server{
 		ReadTimeout:  DefaultReadTimeout,
@@ -82,6 +85,20 @@ func mainFunc() error {
 	}
 	closeChan := make(chan struct{})
 
+	go func() {
+		reloadChan := makeReloadChannel()
+		reloader := newReloader(configPath, conf, topo, httpHandler, logger)
+
+		for {
+			select {
+			case <-rootCtx.Done():
+				return
+			case <-reloadChan:
+				reloader.Reload()
+			}
+		}
+	}()
+
 	go func() {
 		<-rootCtx.Done()
 		srv.Shutdown(context.Background()) // nolint: errcheck
@@ -93,6 +110,12 @@ func mainFunc() error {
 		return fmt.Errorf("cannot start listener: %w", err)
 	}
 
+	if conf.UseProxyProtocol() {
+		listener = newProxyProtocolListener(listener,
+			conf.GetProxyProtocolSources(),
+			DefaultReadTimeout)
+	}
+
 	defer listener.Close()
 
 	srv.Serve(listener) // nolint: errcheck
@@ -3,21 +3,44 @@ package main
 import (
 	"crypto/subtle"
 	"net/http"
+	"sync/atomic"
 )
 
-type basicAuthMiddleware struct {
-	handler  http.Handler
+type basicAuthCredentials struct {
 	user     []byte
 	password []byte
 }
 
+// basicAuthMiddleware checks basic auth credentials. Credentials can
+// be swapped at any time with setCredentials; if they are empty, all
+// requests are passed through.
+type basicAuthMiddleware struct {
+	handler     http.Handler
+	credentials atomic.Value
+}
+
+func (b *basicAuthMiddleware) setCredentials(user, password []byte) {
+	b.credentials.Store(basicAuthCredentials{
+		user:     user,
+		password: password,
+	})
+}
+
 func (b *basicAuthMiddleware) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	credentials, _ := b.credentials.Load().(basicAuthCredentials)
+
+	if len(credentials.user) == 0 && len(credentials.password) == 0 {
+		b.handler.ServeHTTP(w, req)
+
+		return
+	}
+
 	user, pass, _ := req.BasicAuth()
 
 	userBytes := []byte(user)
 	passBytes := []byte(pass)
 
-	if subtle.ConstantTimeCompare(b.user, userBytes)+subtle.ConstantTimeCompare(b.password, passBytes) == 2 {
+	if subtle.ConstantTimeCompare(credentials.user, userBytes)+subtle.ConstantTimeCompare(credentials.password, passBytes) == 2 {
 		b.handler.ServeHTTP(w, req)
 
 		return
@@ -26,3 +49,13 @@ func (b *basicAuthMiddleware) ServeHTTP(w http.ResponseWriter, req *http.Request
 	w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
 	http.Error(w, "Authentication is required", http.StatusUnauthorized)
 }
+
+func newBasicAuthMiddleware(handler http.Handler, user, password []byte) *basicAuthMiddleware {
+	rv := &basicAuthMiddleware{
+		handler: handler,
+	}
+
+	rv.setCredentials(user, password)
+
+	return rv
+}
@@ -24,16 +24,28 @@ var (
 	dbipLiteSha1ChecksumRegexp = regexp.MustCompile(`(?i)[0-9a-f]{40}`)
 )
 
+const (
+	dbipLiteCityPageURL = "https://db-ip.com/db/download/ip-to-city-lite"
+	dbipLiteASNPageURL  = "https://db-ip.com/db/download/ip-to-asn-lite"
+)
+
 type dbipLiteProvider struct {
 	maxmindBase
 
+	name          string
+	capabilities  topolib.Capability
+	pageURL       string
 	baseDirectory string
 	updateEvery   time.Duration
 	httpClient    topolib.HTTPClient
 }
 
 func (d *dbipLiteProvider) Name() string {
-	return NameDBIPLite
+	return d.name
+}
+
+func (d *dbipLiteProvider) Capabilities() topolib.Capability {
+	return d.capabilities
 }
 
 func (d *dbipLiteProvider) UpdateEvery() time.Duration {
@@ -58,8 +70,7 @@ func (d *dbipLiteProvider) Download(ctx context.Context, rootDir string) error {
 }
 
 func (d *dbipLiteProvider) getFileData(ctx context.Context) (string, string, error) {
-	req, _ := http.NewRequestWithContext(ctx, http.MethodGet,
-		"https://db-ip.com/db/download/ip-to-city-lite", nil)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, d.pageURL, nil)
 
 	htmlPageResp, err := d.httpClient.Do(req)
 	if err != nil {
@@ -138,6 +149,30 @@ func (d *dbipLiteProvider) downloadFile(ctx context.Context, rootDir string, url
 // similar to MaxMind.
 func NewDBIPLite(httpClient topolib.HTTPClient, updateEvery time.Duration, baseDirectory string) topolib.OfflineProvider {
 	return &dbipLiteProvider{
+		name:          NameDBIPLite,
+		capabilities:  maxmindBaseCityCapabilities,
+		pageURL:       dbipLiteCityPageURL,
+		httpClient:    httpClient,
+		updateEvery:   updateEvery,
+		baseDirectory: filepath.Clean(baseDirectory),
+	}
+}
+
+// NewDBIPASNLite returns a new instance which works with ASN database
+// of db-ip.com
+//
+//   Identifier: dbip_asn_lite
+//   Provider type: offline
+//   Website: https://db-ip.com
+//
+// This provider knows nothing about geolocation. It returns only a
+// number of autonomous system and a name of organization which
+// operates it.
+func NewDBIPASNLite(httpClient topolib.HTTPClient, updateEvery time.Duration, baseDirectory string) topolib.OfflineProvider {
+	return &dbipLiteProvider{
+		name:          NameDBIPASNLite,
+		capabilities:  maxmindBaseASNCapabilities,
+		pageURL:       dbipLiteASNPageURL,
 		httpClient:    httpClient,
 		updateEvery:   updateEvery,
 		baseDirectory: filepath.Clean(baseDirectory),
@@ -244,6 +244,61 @@ func (suite *MockedDBIPTestSuite) TestDownloadOk() {
 	suite.NoError(suite.prov.Download(ctx, suite.tmpDir))
 }
 
+type MockedDBIPASNTestSuite struct {
+	TmpDirTestSuite
+	OfflineProviderTestSuite
+	HTTPMockMixin
+}
+
+func (suite *MockedDBIPASNTestSuite) SetupTest() {
+	suite.TmpDirTestSuite.SetupTest()
+	suite.OfflineProviderTestSuite.SetupTest()
+
+	suite.prov = providers.NewDBIPASNLite(suite.http, time.Minute, suite.tmpDir)
+}
+
+func (suite *MockedDBIPASNTestSuite) TearDownTest() {
+	suite.HTTPMockMixin.TearDownTest()
+	suite.OfflineProviderTestSuite.TearDownTest()
+	suite.TmpDirTestSuite.TearDownTest()
+}
+
+func (suite *MockedDBIPASNTestSuite) TestName() {
+	suite.Equal(providers.NameDBIPASNLite, suite.prov.Name())
+}
+
+func (suite *MockedDBIPASNTestSuite) TestDownloadOk() {
+	ctx := context.Background()
+
+	httpmock.RegisterResponder("GET",
+		"https://db-ip.com/db/download/ip-to-asn-lite",
+		httpmock.NewStringResponder(http.StatusOK, `
+<html><body>
+    <div class="card">
+        <dl>
+            <dd class="small">aaa</dd>
+            <dd class="small">7037807198c22a7d2b0807371d763779a84fdfcF</dd>
+        </dl>
+        <div>
+            <a href="https://download.db-ip.com/free/dbip-asn-lite.mmdb.gz" class="free_download_link">Download me</a>
+        </div>
+    </div>
+</body></html>
+        `))
+
+	fileBuffer := &bytes.Buffer{}
+	wr := gzip.NewWriter(fileBuffer)
+
+	wr.Write([]byte{1, 2, 3}) // nolint: errcheck
+	wr.Close()
+
+	httpmock.RegisterResponder("GET",
+		"https://download.db-ip.com/free/dbip-asn-lite.mmdb.gz",
+		httpmock.NewBytesResponder(http.StatusOK, fileBuffer.Bytes()))
+
+	suite.NoError(suite.prov.Download(ctx, suite.tmpDir))
+}
+
 type IntegrationDBIPTestSuite struct {
 	TmpDirTestSuite
 	OfflineProviderTestSuite
@@ -274,6 +329,10 @@ func TestDBIP(t *testing.T) {
 	suite.Run(t, &MockedDBIPTestSuite{})
 }
 
+func TestDBIPASN(t *testing.T) {
+	suite.Run(t, &MockedDBIPASNTestSuite{})
+}
+
 func TestIntegrationDBIP(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipped because of the short mode")
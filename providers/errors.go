@@ -1,12 +1,17 @@
 package providers
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/9seconds/topographer/topolib"
+)
 
 var (
 	// ErrDatabaseIsNotReadyYet returns if you are trying to access
 	// an offline provider but it haven't opened a database yet. For
-	// example, it can be in process of downloading it.
-	ErrDatabaseIsNotReadyYet = errors.New("database is not initialized yet")
+	// example, it can be in process of downloading it. This is the same
+	// error as topolib.ErrDatabaseIsNotReadyYet.
+	ErrDatabaseIsNotReadyYet = topolib.ErrDatabaseIsNotReadyYet
 
 	// ErrAuthTokenIsRequired is returned if you are trying to initialize
 	// a provider which requires some token to work.
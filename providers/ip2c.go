@@ -23,6 +23,10 @@ func (i ip2cProvider) Name() string {
 	return NameIP2C
 }
 
+func (i ip2cProvider) Capabilities() topolib.Capability {
+	return topolib.CapabilityIPv4 | topolib.CapabilityCountry
+}
+
 func (i ip2cProvider) Lookup(ctx context.Context, ip net.IP) (topolib.ProviderLookupResult, error) {
 	result := topolib.ProviderLookupResult{}
 	ip4 := ip.To4()
@@ -42,7 +46,7 @@ func (i ip2cProvider) Lookup(ctx context.Context, ip net.IP) (topolib.ProviderLo
 	defer flushResponse(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return result, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return result, &topolib.HTTPStatusError{StatusCode: resp.StatusCode}
 	}
 
 	bodyBytes, err := ioutil.ReadAll(bufio.NewReader(resp.Body))
@@ -55,9 +59,9 @@ func (i ip2cProvider) Lookup(ctx context.Context, ip net.IP) (topolib.ProviderLo
 	chunks := strings.SplitN(body, ";", 3)
 	switch {
 	case len(chunks) != 3:
-		return result, fmt.Errorf("incorrect response: %s", body)
+		return result, fmt.Errorf("%w: %s", topolib.ErrIncorrectResponse, body)
 	case chunks[0] != "1":
-		return result, fmt.Errorf("ip2c cannot detect region: %s", body)
+		return result, fmt.Errorf("ip2c cannot detect region: %s: %w", body, topolib.ErrIPNotFound)
 	}
 
 	result.CountryCode = topolib.Alpha2ToCountryCode(chunks[1])
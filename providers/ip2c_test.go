@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/9seconds/topographer/providers"
+	"github.com/9seconds/topographer/topolib"
 	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/suite"
 )
@@ -26,6 +27,14 @@ func (suite *MockedIP2CTestSuite) TestName() {
 	suite.Equal(providers.NameIP2C, suite.prov.Name())
 }
 
+func (suite *MockedIP2CTestSuite) TestCapabilities() {
+	capabilities := suite.prov.(topolib.CapableProvider).Capabilities()
+
+	suite.True(capabilities.Has(topolib.CapabilityIPv4 | topolib.CapabilityCountry))
+	suite.False(capabilities.Has(topolib.CapabilityIPv6))
+	suite.False(capabilities.Has(topolib.CapabilityCity))
+}
+
 func (suite *MockedIP2CTestSuite) TestLookupClosedContext() {
 	ctx, cancel := context.WithCancel(context.Background())
 
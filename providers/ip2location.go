@@ -38,6 +38,11 @@ func (i *ip2locationProvider) Name() string {
 	return NameIP2Location
 }
 
+func (i *ip2locationProvider) Capabilities() topolib.Capability {
+	return topolib.CapabilityIPv4 | topolib.CapabilityIPv6 | topolib.CapabilityCountry |
+		topolib.CapabilityCity | topolib.CapabilityLocation
+}
+
 func (i *ip2locationProvider) UpdateEvery() time.Duration {
 	return i.updateEvery
 }
@@ -63,10 +68,33 @@ func (i *ip2locationProvider) Lookup(ctx context.Context, ip net.IP) (topolib.Pr
 
 	result.City = resolved.City
 	result.CountryCode = topolib.Alpha2ToCountryCode(resolved.Country_short)
+	result.Region = ip2locationValue(resolved.Region)
+	result.TimeZone = ip2locationValue(resolved.Timezone)
+	result.PostalCode = ip2locationValue(resolved.Zipcode)
+
+	// lite databases below DB5 have no coordinates. ip2location
+	// returns zeroes in that case.
+	if resolved.Latitude != 0 || resolved.Longitude != 0 {
+		result.Location = &topolib.Location{
+			Latitude:  float64(resolved.Latitude),
+			Longitude: float64(resolved.Longitude),
+		}
+	}
 
 	return result, nil
 }
 
+// ip2locationValue filters out placeholders which are returned by
+// ip2location if a database has no such field. For example, DB3 has no
+// postal codes.
+func ip2locationValue(value string) string {
+	if strings.HasPrefix(value, "This parameter is unavailable") || value == "-" {
+		return ""
+	}
+
+	return value
+}
+
 func (i *ip2locationProvider) Open(rootDir string) error {
 	db, err := ip2location.OpenDB(filepath.Join(rootDir, ip2locationFileName))
 	if err != nil {
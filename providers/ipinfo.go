@@ -8,13 +8,19 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/9seconds/topographer/topolib"
 )
 
 type ipinfoResponse struct {
-	City    string `json:"city"`
-	Country string `json:"country"`
+	City     string `json:"city"`
+	Country  string `json:"country"`
+	Region   string `json:"region"`
+	Loc      string `json:"loc"`
+	Postal   string `json:"postal"`
+	Timezone string `json:"timezone"`
 }
 
 type ipinfoProvider struct {
@@ -26,6 +32,11 @@ func (i ipinfoProvider) Name() string {
 	return NameIPInfo
 }
 
+func (i ipinfoProvider) Capabilities() topolib.Capability {
+	return topolib.CapabilityIPv4 | topolib.CapabilityIPv6 | topolib.CapabilityCountry |
+		topolib.CapabilityCity | topolib.CapabilityLocation
+}
+
 func (i ipinfoProvider) Lookup(ctx context.Context, ip net.IP) (topolib.ProviderLookupResult, error) {
 	result := topolib.ProviderLookupResult{}
 
@@ -46,7 +57,7 @@ func (i ipinfoProvider) Lookup(ctx context.Context, ip net.IP) (topolib.Provider
 	defer flushResponse(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return result, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return result, &topolib.HTTPStatusError{StatusCode: resp.StatusCode}
 	}
 
 	jsonResponse := ipinfoResponse{}
@@ -58,10 +69,38 @@ func (i ipinfoProvider) Lookup(ctx context.Context, ip net.IP) (topolib.Provider
 
 	result.City = jsonResponse.City
 	result.CountryCode = topolib.Alpha2ToCountryCode(jsonResponse.Country)
+	result.Region = jsonResponse.Region
+	result.PostalCode = jsonResponse.Postal
+	result.TimeZone = jsonResponse.Timezone
+	result.Location = i.parseLocation(jsonResponse.Loc)
 
 	return result, nil
 }
 
+// parseLocation parses coordinates which are given by ipinfo as
+// 'latitude,longitude' string.
+func (i ipinfoProvider) parseLocation(loc string) *topolib.Location {
+	chunks := strings.SplitN(loc, ",", 2)
+	if len(chunks) != 2 {
+		return nil
+	}
+
+	latitude, err := strconv.ParseFloat(strings.TrimSpace(chunks[0]), 64)
+	if err != nil {
+		return nil
+	}
+
+	longitude, err := strconv.ParseFloat(strings.TrimSpace(chunks[1]), 64)
+	if err != nil {
+		return nil
+	}
+
+	return &topolib.Location{
+		Latitude:  latitude,
+		Longitude: longitude,
+	}
+}
+
 func (i ipinfoProvider) buildURL(ip net.IP) string {
 	u := url.URL{
 		Scheme: "https",
@@ -77,8 +77,31 @@ func (suite *MockedIPInfoTestSuite) TestLookupOk() {
 	result, err := suite.prov.Lookup(context.Background(),
 		net.ParseIP("23.22.13.113"))
 
+	suite.NoError(err)
 	suite.Equal("US", result.CountryCode.String())
+	suite.Equal("Virginia Beach", result.City)
+	suite.Equal("Virginia", result.Region)
+	suite.Equal("23479", result.PostalCode)
+	suite.Equal("America/New_York", result.TimeZone)
+	suite.InDelta(36.7957, result.Location.Latitude, 0.0001)
+	suite.InDelta(-76.0126, result.Location.Longitude, 0.0001)
+}
+
+func (suite *MockedIPInfoTestSuite) TestLookupNoLocation() {
+	httpmock.RegisterResponder("GET",
+		"https://ipinfo.io/23.22.13.113",
+		httpmock.NewStringResponder(http.StatusOK, `{
+  "ip": "23.22.13.113",
+  "city": "Virginia Beach",
+  "country": "US"
+}`))
+
+	result, err := suite.prov.Lookup(context.Background(),
+		net.ParseIP("23.22.13.113"))
+
 	suite.NoError(err)
+	suite.Equal("US", result.CountryCode.String())
+	suite.Nil(result.Location)
 }
 
 type IntegrationIPInfoTestSuite struct {
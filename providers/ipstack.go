@@ -18,8 +18,15 @@ type ipstackResponse struct {
 		Type string `json:"type"`
 		Info string `json:"info"`
 	} `json:"error"`
-	City    string `json:"city"`
-	Country string `json:"country_code"`
+	City      string   `json:"city"`
+	Country   string   `json:"country_code"`
+	Region    string   `json:"region_name"`
+	Zip       string   `json:"zip"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	TimeZone  struct {
+		ID string `json:"id"`
+	} `json:"time_zone"`
 }
 
 type ipstackProvider struct {
@@ -32,6 +39,11 @@ func (i ipstackProvider) Name() string {
 	return NameIPStack
 }
 
+func (i ipstackProvider) Capabilities() topolib.Capability {
+	return topolib.CapabilityIPv4 | topolib.CapabilityIPv6 | topolib.CapabilityCountry |
+		topolib.CapabilityCity | topolib.CapabilityLocation
+}
+
 func (i ipstackProvider) Lookup(ctx context.Context, ip net.IP) (topolib.ProviderLookupResult, error) {
 	result := topolib.ProviderLookupResult{}
 
@@ -47,7 +59,7 @@ func (i ipstackProvider) Lookup(ctx context.Context, ip net.IP) (topolib.Provide
 	defer flushResponse(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return result, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return result, &topolib.HTTPStatusError{StatusCode: resp.StatusCode}
 	}
 
 	jsonResponse := ipstackResponse{}
@@ -67,6 +79,16 @@ func (i ipstackProvider) Lookup(ctx context.Context, ip net.IP) (topolib.Provide
 
 	result.City = jsonResponse.City
 	result.CountryCode = topolib.Alpha2ToCountryCode(jsonResponse.Country)
+	result.Region = jsonResponse.Region
+	result.PostalCode = jsonResponse.Zip
+	result.TimeZone = jsonResponse.TimeZone.ID
+
+	if jsonResponse.Latitude != nil && jsonResponse.Longitude != nil {
+		result.Location = &topolib.Location{
+			Latitude:  *jsonResponse.Latitude,
+			Longitude: *jsonResponse.Longitude,
+		}
+	}
 
 	return result, nil
 }
@@ -76,7 +98,7 @@ func (i ipstackProvider) buildURL(ip net.IP) string {
 
 	getQuery.Set("access_key", i.authToken)
 	getQuery.Set("output", "json")
-	getQuery.Set("fields", "country_code,city")
+	getQuery.Set("fields", "country_code,city,region_name,zip,latitude,longitude,time_zone.id")
 	getQuery.Set("language", "en")
 	getQuery.Set("hostname", "0")
 	getQuery.Set("security", "0")
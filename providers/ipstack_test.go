@@ -83,7 +83,9 @@ func (suite *MockedIPStackTestSuite) TestLookupOK() {
 	httpmock.RegisterResponder("GET",
 		"https://api.ipstack.com/23.22.13.113",
 		httpmock.NewStringResponder(http.StatusOK, `{
-"country_code": "RU", "city": "Moscow"
+"country_code": "RU", "city": "Moscow", "region_name": "Moscow",
+"zip": "101000", "latitude": 55.7522, "longitude": 37.6156,
+"time_zone": {"id": "Europe/Moscow"}
         }`))
 
 	result, err := suite.prov.Lookup(context.Background(),
@@ -91,6 +93,11 @@ func (suite *MockedIPStackTestSuite) TestLookupOK() {
 
 	suite.Equal("RU", result.CountryCode.String())
 	suite.Equal("Moscow", result.City)
+	suite.Equal("Moscow", result.Region)
+	suite.Equal("101000", result.PostalCode)
+	suite.Equal("Europe/Moscow", result.TimeZone)
+	suite.InDelta(55.7522, result.Location.Latitude, 0.0001)
+	suite.InDelta(37.6156, result.Location.Longitude, 0.0001)
 	suite.NoError(err)
 }
 
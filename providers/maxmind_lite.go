@@ -26,11 +26,17 @@ var (
 
 const (
 	maxmindLiteArchiveName = "archive.tar.gz"
+
+	maxmindLiteCityEditionID = "GeoLite2-City"
+	maxmindLiteASNEditionID  = "GeoLite2-ASN"
 )
 
 type maxmindLiteProvider struct {
 	maxmindBase
 
+	name          string
+	capabilities  topolib.Capability
+	editionID     string
 	baseDirectory string
 	licenseKey    string
 	updateEvery   time.Duration
@@ -38,7 +44,11 @@ type maxmindLiteProvider struct {
 }
 
 func (m *maxmindLiteProvider) Name() string {
-	return NameMaxmindLite
+	return m.name
+}
+
+func (m *maxmindLiteProvider) Capabilities() topolib.Capability {
+	return m.capabilities
 }
 
 func (m *maxmindLiteProvider) UpdateEvery() time.Duration {
@@ -184,7 +194,7 @@ func (m *maxmindLiteProvider) extractArchive(rootDir string) error {
 func (m *maxmindLiteProvider) buildURL(suffix string) string {
 	queryValues := url.Values{}
 
-	queryValues.Set("edition_id", "GeoLite2-City")
+	queryValues.Set("edition_id", m.editionID)
 	queryValues.Set("suffix", suffix)
 	queryValues.Set("license_key", m.licenseKey)
 
@@ -216,6 +226,38 @@ func NewMaxmindLite(httpClient topolib.HTTPClient,
 	}
 
 	return &maxmindLiteProvider{
+		name:          NameMaxmindLite,
+		capabilities:  maxmindBaseCityCapabilities,
+		editionID:     maxmindLiteCityEditionID,
+		httpClient:    httpClient,
+		updateEvery:   updateEvery,
+		baseDirectory: filepath.Clean(baseDirectory),
+		licenseKey:    licenseKey,
+	}, nil
+}
+
+// NewMaxmindASNLite returns a new instance which works with lite ASN
+// databases from MaxMind.
+//
+//   Identifier: maxmind_asn_lite
+//   Provider type: offline
+//   Website: https://maxmind.com
+//
+// This provider knows nothing about geolocation. It returns only a
+// number of autonomous system and a name of organization which
+// operates it.
+func NewMaxmindASNLite(httpClient topolib.HTTPClient,
+	updateEvery time.Duration,
+	baseDirectory string,
+	licenseKey string) (topolib.OfflineProvider, error) {
+	if licenseKey == "" {
+		return nil, ErrAuthTokenIsRequired
+	}
+
+	return &maxmindLiteProvider{
+		name:          NameMaxmindASNLite,
+		capabilities:  maxmindBaseASNCapabilities,
+		editionID:     maxmindLiteASNEditionID,
 		httpClient:    httpClient,
 		updateEvery:   updateEvery,
 		baseDirectory: filepath.Clean(baseDirectory),
@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"github.com/9seconds/topographer/providers"
+	"github.com/9seconds/topographer/topolib"
 	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/suite"
 )
@@ -222,6 +223,52 @@ func (suite *MaxmindLiteTestSuite) TestOk() {
 	suite.Equal([]byte("hello"), data)
 }
 
+type MaxmindASNLiteTestSuite struct {
+	TmpDirTestSuite
+	OfflineProviderTestSuite
+	HTTPMockMixin
+}
+
+func (suite *MaxmindASNLiteTestSuite) SetupTest() {
+	suite.TmpDirTestSuite.SetupTest()
+	suite.OfflineProviderTestSuite.SetupTest()
+
+	suite.prov, _ = providers.NewMaxmindASNLite(suite.http, time.Minute, suite.tmpDir, "apikey")
+}
+
+func (suite *MaxmindASNLiteTestSuite) TearDownTest() {
+	suite.HTTPMockMixin.TearDownTest()
+	suite.OfflineProviderTestSuite.TearDownTest()
+	suite.TmpDirTestSuite.TearDownTest()
+}
+
+func (suite *MaxmindASNLiteTestSuite) TestName() {
+	suite.Equal(providers.NameMaxmindASNLite, suite.prov.Name())
+}
+
+func (suite *MaxmindASNLiteTestSuite) TestCapabilities() {
+	capabilities := suite.prov.(topolib.CapableProvider).Capabilities()
+
+	suite.True(capabilities.Has(topolib.CapabilityASN))
+	suite.False(capabilities.Has(topolib.CapabilityCountry))
+}
+
+func (suite *MaxmindASNLiteTestSuite) TestNoLicenseKey() {
+	_, err := providers.NewMaxmindASNLite(suite.http, time.Minute, suite.tmpDir, "")
+
+	suite.Error(err)
+}
+
+func (suite *MaxmindASNLiteTestSuite) TestCannotDownloadChecksumBadStatus() {
+	ctx := context.Background()
+
+	httpmock.RegisterResponder("GET",
+		"https://download.maxmind.com/app/geoip_download?edition_id=GeoLite2-ASN&license_key=apikey&suffix=tar.gz.sha256",
+		httpmock.NewStringResponder(http.StatusInternalServerError, ""))
+
+	suite.Error(suite.prov.Download(ctx, suite.tmpDir))
+}
+
 type IntegrationMaxmindLiteTestSuite struct {
 	TmpDirTestSuite
 	OfflineProviderTestSuite
@@ -252,6 +299,10 @@ func TestMaxmindLite(t *testing.T) {
 	suite.Run(t, &MaxmindLiteTestSuite{})
 }
 
+func TestMaxmindASNLite(t *testing.T) {
+	suite.Run(t, &MaxmindASNLiteTestSuite{})
+}
+
 func TestIntegrationMaxmindLite(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipped because of the short mode")
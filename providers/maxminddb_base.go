@@ -13,6 +13,13 @@ import (
 
 const maxmindBaseFileName = "database.mmdb"
 
+const (
+	maxmindBaseCityCapabilities = topolib.CapabilityIPv4 | topolib.CapabilityIPv6 |
+		topolib.CapabilityCountry | topolib.CapabilityCity | topolib.CapabilityLocation
+	maxmindBaseASNCapabilities = topolib.CapabilityIPv4 | topolib.CapabilityIPv6 |
+		topolib.CapabilityASN
+)
+
 type maxmindLookupResult struct {
 	City struct {
 		Names struct {
@@ -22,6 +29,22 @@ type maxmindLookupResult struct {
 	Country struct {
 		IsoCode string `maxminddb:"iso_code"`
 	} `maxminddb:"country"`
+	Subdivisions []struct {
+		Names struct {
+			En string `maxminddb:"en"`
+		} `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	Location struct {
+		AccuracyRadius uint16   `maxminddb:"accuracy_radius"`
+		Latitude       *float64 `maxminddb:"latitude"`
+		Longitude      *float64 `maxminddb:"longitude"`
+		TimeZone       string   `maxminddb:"time_zone"`
+	} `maxminddb:"location"`
+	Postal struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"postal"`
+	AutonomousSystemNumber       uint32 `maxminddb:"autonomous_system_number"`
+	AutonomousSystemOrganization string `maxminddb:"autonomous_system_organization"`
 }
 
 type maxmindBase struct {
@@ -75,6 +98,58 @@ func (m *maxmindBase) Lookup(ctx context.Context, ip net.IP) (topolib.ProviderLo
 
 	rv.CountryCode = topolib.Alpha2ToCountryCode(record.Country.IsoCode)
 	rv.City = record.City.Names.En
+	rv.TimeZone = record.Location.TimeZone
+	rv.PostalCode = record.Postal.Code
+	rv.ASN = record.AutonomousSystemNumber
+	rv.Organization = record.AutonomousSystemOrganization
+
+	if len(record.Subdivisions) > 0 {
+		rv.Region = record.Subdivisions[0].Names.En
+	}
+
+	if record.Location.Latitude != nil && record.Location.Longitude != nil {
+		rv.Location = &topolib.Location{
+			Latitude:       *record.Location.Latitude,
+			Longitude:      *record.Location.Longitude,
+			AccuracyRadius: float64(record.Location.AccuracyRadius),
+		}
+	}
+
+	return rv, nil
+}
+
+func (m *maxmindBase) Networks(ctx context.Context, prefix *net.IPNet) ([]*net.IPNet, error) {
+	m.dbReaderLock.RLock()
+	defer m.dbReaderLock.RUnlock()
+
+	if m.dbReader == nil {
+		return nil, ErrDatabaseIsNotReadyYet
+	}
+
+	rv := []*net.IPNet{}
+	record := struct{}{}
+	iter := m.dbReader.NetworksWithin(prefix, maxminddb.SkipAliasedNetworks)
+
+	for iter.Next() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if len(rv) == topolib.MaxPrefixSubnets {
+			return nil, topolib.ErrPrefixTooLarge
+		}
+
+		network, err := iter.Network(&record)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read a network: %w", err)
+		}
+
+		rv = append(rv, network)
+	}
+
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("cannot traverse networks: %w", err)
+	}
 
 	return rv, nil
 }
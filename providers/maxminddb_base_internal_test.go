@@ -97,6 +97,32 @@ func (suite *MaxmindDBBaseTestSuite) TestLookupOk() {
 	suite.Equal("London", result.City)
 }
 
+func (suite *MaxmindDBBaseTestSuite) TestNetworksNotReady() {
+	_, prefix, _ := net.ParseCIDR("81.2.69.0/24")
+
+	_, err := suite.m.Networks(context.Background(), prefix)
+
+	suite.True(errors.Is(err, ErrDatabaseIsNotReadyYet))
+}
+
+func (suite *MaxmindDBBaseTestSuite) TestNetworksOk() {
+	path := filepath.Join(suite.GetTestdataPath(),
+		"maxmind", "ok", "target_xxx")
+
+	suite.NoError(suite.m.Open(path))
+
+	_, prefix, _ := net.ParseCIDR("81.2.69.0/24")
+
+	networks, err := suite.m.Networks(context.Background(), prefix)
+
+	suite.NoError(err)
+	suite.NotEmpty(networks)
+
+	for _, v := range networks {
+		suite.True(prefix.Contains(v.IP) || v.Contains(prefix.IP))
+	}
+}
+
 func TestMaxmindDBBase(t *testing.T) {
 	suite.Run(t, &MaxmindDBBaseTestSuite{})
 }
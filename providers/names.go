@@ -4,6 +4,9 @@ const (
 	// Identifier for DB-IP.com provider.
 	NameDBIPLite = "dbip_lite"
 
+	// Identifier for DB-IP.com ASN provider.
+	NameDBIPASNLite = "dbip_asn_lite"
+
 	// Identifier for ip2.org.
 	NameIP2C = "ip2c"
 
@@ -19,6 +22,9 @@ const (
 	// Identifier for MaxMind Geo2Lite databases.
 	NameMaxmindLite = "maxmind_lite"
 
+	// Identifier for MaxMind GeoLite2 ASN databases.
+	NameMaxmindASNLite = "maxmind_asn_lite"
+
 	// Identifier for software77.
 	NameSoftware77 = "software77"
 )
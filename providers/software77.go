@@ -48,6 +48,10 @@ func (s *software77Provider) Name() string {
 	return NameSoftware77
 }
 
+func (s *software77Provider) Capabilities() topolib.Capability {
+	return topolib.CapabilityIPv4 | topolib.CapabilityIPv6 | topolib.CapabilityCountry
+}
+
 func (s *software77Provider) UpdateEvery() time.Duration {
 	return s.updateEvery
 }
@@ -76,6 +80,17 @@ func (s *software77Provider) Lookup(ctx context.Context, ip net.IP) (topolib.Pro
 	return result, nil
 }
 
+func (s *software77Provider) Networks(ctx context.Context, prefix *net.IPNet) ([]*net.IPNet, error) {
+	s.dbMutex.RLock()
+	defer s.dbMutex.RUnlock()
+
+	if s.db == nil {
+		return nil, ErrDatabaseIsNotReadyYet
+	}
+
+	return s.db.Networks(prefix)
+}
+
 func (s *software77Provider) Open(rootDir string) error {
 	db := newSoftware77DB()
 
@@ -87,6 +102,8 @@ func (s *software77Provider) Open(rootDir string) error {
 		return fmt.Errorf("cannot process db with v4 addresses: %w", err)
 	}
 
+	db.sortNetworks()
+
 	s.dbMutex.Lock()
 	defer s.dbMutex.Unlock()
 
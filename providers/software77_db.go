@@ -1,10 +1,11 @@
 package providers
 
 import (
+	"bytes"
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"net"
+	"sort"
 	"strconv"
 
 	"github.com/9seconds/topographer/topolib"
@@ -14,12 +15,18 @@ import (
 )
 
 var (
-	errSoftware77DBNotFound = errors.New("ip has not been found")
+	errSoftware77DBNotFound = topolib.ErrIPNotFound
 )
 
 type software77DB struct {
 	v4Tree *uint8_tree.TreeV4
 	v6Tree *uint8_tree.TreeV6
+
+	// patricia trees cannot be traversed so we keep added networks
+	// separately. These lists are sorted by sortNetworks so prefix
+	// can be found with binary search.
+	v4Networks []*net.IPNet
+	v6Networks []*net.IPNet
 }
 
 func (s *software77DB) Lookup(addr net.IP) (topolib.CountryCode, error) {
@@ -47,8 +54,69 @@ func (s *software77DB) Lookup(addr net.IP) (topolib.CountryCode, error) {
 	return topolib.CountryCode(value), nil
 }
 
+func (s *software77DB) Networks(prefix *net.IPNet) ([]*net.IPNet, error) {
+	networks := s.v6Networks
+	addr := prefix.IP.To16()
+	bits := net.IPv6len * 8
+
+	if v4Addr := prefix.IP.To4(); v4Addr != nil {
+		networks = s.v4Networks
+		addr = v4Addr
+		bits = net.IPv4len * 8
+	}
+
+	ones, maskBits := prefix.Mask.Size()
+	if maskBits > bits {
+		ones -= maskBits - bits
+	}
+
+	start := addr.Mask(net.CIDRMask(ones, bits))
+	end := make(net.IP, len(start))
+
+	for i, v := range net.CIDRMask(ones, bits) {
+		end[i] = start[i] | ^v
+	}
+
+	rv := []*net.IPNet{}
+
+	// networks which are larger than prefix. Since networks are
+	// CIDRs, each of them is prefix address masked by a shorter mask.
+	for length := 0; length < ones; length++ {
+		mask := net.CIDRMask(length, bits)
+		network := &net.IPNet{
+			IP:   addr.Mask(mask),
+			Mask: mask,
+		}
+
+		// this one starts within prefix and is found below.
+		if network.IP.Equal(start) {
+			continue
+		}
+
+		if idx := searchSoftware77Network(networks, network); idx < len(networks) &&
+			compareSoftware77Networks(networks[idx], network) == 0 {
+			rv = append(rv, networks[idx])
+		}
+	}
+
+	// networks which start within a prefix.
+	idx := sort.Search(len(networks), func(i int) bool {
+		return bytes.Compare(networks[i].IP, start) >= 0
+	})
+
+	for ; idx < len(networks) && bytes.Compare(networks[idx].IP, end) <= 0; idx++ {
+		if len(rv) == topolib.MaxPrefixSubnets {
+			return nil, topolib.ErrPrefixTooLarge
+		}
+
+		rv = append(rv, networks[idx])
+	}
+
+	return rv, nil
+}
+
 func (s *software77DB) AddIPv4Range(start, end, countryCode string) error {
-    cc := topolib.Alpha2ToCountryCode(countryCode)
+	cc := topolib.Alpha2ToCountryCode(countryCode)
 	if !cc.Known() {
 		return nil
 	}
@@ -83,7 +151,7 @@ func (s *software77DB) AddIPv4Range(start, end, countryCode string) error {
 }
 
 func (s *software77DB) AddIPv6CIDR(cidr, countryCode string) error {
-    cc := topolib.Alpha2ToCountryCode(countryCode)
+	cc := topolib.Alpha2ToCountryCode(countryCode)
 	if !cc.Known() {
 		return nil
 	}
@@ -104,6 +172,12 @@ func (s *software77DB) add(ipnet *net.IPNet, countryCode topolib.CountryCode) er
 		_, _, err := s.v4Tree.Add(patricia.NewIPv4AddressFromBytes(addrBytes, uint(addrLength)),
 			uint8(countryCode),
 			nil)
+		if err == nil {
+			s.v4Networks = append(s.v4Networks, &net.IPNet{
+				IP:   addrBytes,
+				Mask: net.CIDRMask(addrLength, net.IPv4len*8),
+			})
+		}
 
 		return err
 	} else {
@@ -112,11 +186,56 @@ func (s *software77DB) add(ipnet *net.IPNet, countryCode topolib.CountryCode) er
 		_, _, err := s.v6Tree.Add(patricia.NewIPv6Address(addrBytes, uint(addrLength)),
 			uint8(countryCode),
 			nil)
+		if err == nil {
+			s.v6Networks = append(s.v6Networks, &net.IPNet{
+				IP:   addrBytes,
+				Mask: net.CIDRMask(addrLength, net.IPv6len*8),
+			})
+		}
 
 		return err
 	}
 }
 
+// sortNetworks has to be called when all networks are added.
+func (s *software77DB) sortNetworks() {
+	s.v4Networks = sortSoftware77Networks(s.v4Networks)
+	s.v6Networks = sortSoftware77Networks(s.v6Networks)
+}
+
+func sortSoftware77Networks(networks []*net.IPNet) []*net.IPNet {
+	sort.Slice(networks, func(i, j int) bool {
+		return compareSoftware77Networks(networks[i], networks[j]) < 0
+	})
+
+	rv := networks[:0]
+
+	for _, v := range networks {
+		if len(rv) == 0 || compareSoftware77Networks(rv[len(rv)-1], v) != 0 {
+			rv = append(rv, v)
+		}
+	}
+
+	return rv
+}
+
+func searchSoftware77Network(networks []*net.IPNet, network *net.IPNet) int {
+	return sort.Search(len(networks), func(i int) bool {
+		return compareSoftware77Networks(networks[i], network) >= 0
+	})
+}
+
+func compareSoftware77Networks(left, right *net.IPNet) int {
+	if rv := bytes.Compare(left.IP, right.IP); rv != 0 {
+		return rv
+	}
+
+	leftLength, _ := left.Mask.Size()
+	rightLength, _ := right.Mask.Size()
+
+	return leftLength - rightLength
+}
+
 func newSoftware77DB() *software77DB {
 	return &software77DB{
 		v4Tree: uint8_tree.NewTreeV4(),
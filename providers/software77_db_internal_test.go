@@ -0,0 +1,94 @@
+package providers
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/9seconds/topographer/topolib"
+	"github.com/stretchr/testify/suite"
+)
+
+type Software77DBTestSuite struct {
+	suite.Suite
+
+	db *software77DB
+}
+
+func (suite *Software77DBTestSuite) SetupTest() {
+	suite.db = newSoftware77DB()
+}
+
+func (suite *Software77DBTestSuite) add(cidrs ...string) {
+	for _, v := range cidrs {
+		_, network, err := net.ParseCIDR(v)
+
+		suite.NoError(err)
+		suite.NoError(suite.db.add(network, topolib.Alpha2ToCountryCode("NL")))
+	}
+
+	suite.db.sortNetworks()
+}
+
+func (suite *Software77DBTestSuite) networks(cidr string) ([]string, error) {
+	_, prefix, _ := net.ParseCIDR(cidr)
+
+	networks, err := suite.db.Networks(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := []string{}
+
+	for _, v := range networks {
+		rv = append(rv, v.String())
+	}
+
+	return rv, nil
+}
+
+func (suite *Software77DBTestSuite) TestNetworks() {
+	suite.add("10.1.0.0/16", "10.0.0.0/8", "10.2.0.0/24", "10.2.1.0/24",
+		"11.0.0.0/24", "10.2.0.0/24", "2001:db8::/32", "2001:db8:1::/48")
+
+	networks, err := suite.networks("10.2.0.0/16")
+
+	suite.NoError(err)
+	suite.Equal([]string{"10.0.0.0/8", "10.2.0.0/24", "10.2.1.0/24"}, networks)
+
+	networks, err = suite.networks("10.0.0.0/8")
+
+	suite.NoError(err)
+	suite.Equal([]string{"10.0.0.0/8", "10.1.0.0/16", "10.2.0.0/24", "10.2.1.0/24"}, networks)
+
+	networks, err = suite.networks("2001:db8:1:1::/64")
+
+	suite.NoError(err)
+	suite.Equal([]string{"2001:db8::/32", "2001:db8:1::/48"}, networks)
+
+	networks, err = suite.networks("12.0.0.0/16")
+
+	suite.NoError(err)
+	suite.Empty(networks)
+}
+
+func (suite *Software77DBTestSuite) TestNetworksTooMany() {
+	for i := 0; i <= topolib.MaxPrefixSubnets; i++ {
+		network := &net.IPNet{
+			IP:   net.IPv4(10, byte(i>>8), byte(i), 0).To4(),
+			Mask: net.CIDRMask(24, 32),
+		}
+
+		suite.NoError(suite.db.add(network, topolib.Alpha2ToCountryCode("NL")))
+	}
+
+	suite.db.sortNetworks()
+
+	_, err := suite.networks("10.0.0.0/8")
+
+	suite.True(errors.Is(err, topolib.ErrPrefixTooLarge))
+}
+
+func TestSoftware77DB(t *testing.T) {
+	suite.Run(t, &Software77DBTestSuite{})
+}
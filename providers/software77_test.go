@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"github.com/9seconds/topographer/providers"
+	"github.com/9seconds/topographer/topolib"
 	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/suite"
 )
@@ -252,6 +253,44 @@ func (suite *MockedSoftware77TestSuite) TestLookupFaulyReopen() {
 	suite.Equal("TH", res.CountryCode.String())
 }
 
+func (suite *MockedSoftware77TestSuite) TestNetworksNotReady() {
+	_, prefix, _ := net.ParseCIDR("1.0.0.0/22")
+
+	_, err := suite.prov.(topolib.PrefixProvider).Networks(context.Background(), prefix)
+
+	suite.Error(err)
+}
+
+func (suite *MockedSoftware77TestSuite) TestNetworksOk() {
+	suite.NoError(suite.prov.Open(suite.BaseDirectory()))
+
+	_, prefix, _ := net.ParseCIDR("1.0.0.0/22")
+
+	networks, err := suite.prov.(topolib.PrefixProvider).Networks(context.Background(), prefix)
+
+	suite.NoError(err)
+
+	cidrs := []string{}
+
+	for _, v := range networks {
+		cidrs = append(cidrs, v.String())
+	}
+
+	suite.Equal([]string{"1.0.0.0/24", "1.0.1.0/24", "1.0.2.0/23"}, cidrs)
+}
+
+func (suite *MockedSoftware77TestSuite) TestNetworksLarger() {
+	suite.NoError(suite.prov.Open(suite.BaseDirectory()))
+
+	_, prefix, _ := net.ParseCIDR("1.0.2.128/25")
+
+	networks, err := suite.prov.(topolib.PrefixProvider).Networks(context.Background(), prefix)
+
+	suite.NoError(err)
+	suite.Len(networks, 1)
+	suite.Equal("1.0.2.0/23", networks[0].String())
+}
+
 type IntegrationSoftware77TestSuite struct {
 	TmpDirTestSuite
 	OfflineProviderTestSuite
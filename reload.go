@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/9seconds/topographer/topolib"
+)
+
+// reloader applies a new configuration to working topographer on
+// SIGHUP. Only providers and basic auth credentials are reloaded: other
+// settings require a restart.
+//
+// Providers are diffed by their configurations: unchanged providers
+// are kept as is, changed ones are replaced, absent ones are removed.
+// Replaced offline providers reuse already downloaded databases.
+// Providers from ready_providers of initial config are never removed:
+// readiness settings are not reloaded, so Topographer would never be
+// ready without them.
+type reloader struct {
+	path string
+	// conf is an initial config. Settings which require a restart are
+	// taken from it.
+	conf      *config
+	providers map[string]configProvider
+	topo      *topolib.Topographer
+	auth      *basicAuthMiddleware
+	logger    *logger
+}
+
+func (r *reloader) Reload() {
+	conf, err := parseConfig(r.path)
+	if err != nil {
+		r.logger.ReloadError("", fmt.Errorf("cannot read config: %w", err))
+
+		return
+	}
+
+	if r.conf.restartRequired(*conf) {
+		r.logger.ReloadInfo("Some settings require a restart, only providers and basic auth are reloaded")
+	}
+
+	seen := map[string]struct{}{}
+	required := map[string]struct{}{}
+
+	for _, v := range r.conf.GetReadyProviders() {
+		required[v] = struct{}{}
+	}
+
+	for _, v := range conf.GetProviders() {
+		seen[v.GetName()] = struct{}{}
+
+		if err := r.reloadProvider(v); err != nil {
+			r.logger.ReloadError(v.GetName(), err)
+		}
+	}
+
+	for name := range r.providers {
+		if _, ok := seen[name]; ok {
+			continue
+		}
+
+		if _, ok := required[name]; ok {
+			r.logger.ReloadError(name,
+				fmt.Errorf("provider is in ready_providers, it cannot be removed without a restart"))
+
+			continue
+		}
+
+		if err := r.topo.RemoveProvider(name); err != nil {
+			r.logger.ReloadError(name, fmt.Errorf("cannot remove provider: %w", err))
+
+			continue
+		}
+
+		delete(r.providers, name)
+		r.logger.ReloadProviderInfo(name, "Provider was removed")
+	}
+
+	r.auth.setCredentials(conf.GetBasicAuthUser(), conf.GetBasicAuthPassword())
+
+	r.logger.ReloadInfo("Config was reloaded")
+}
+
+// reloadProvider adds or replaces the provider if its configuration
+// has changed. Providers are made with initial config so they use the
+// same root directory.
+func (r *reloader) reloadProvider(v configProvider) error {
+	oldConf, exists := r.providers[v.GetName()]
+	if exists && reflect.DeepEqual(oldConf, v) {
+		return nil
+	}
+
+	httpClient := makeNewHTTPClient(v)
+
+	prov, err := makeProvider(r.conf, v, httpClient)
+	if err != nil {
+		return fmt.Errorf("cannot make provider: %w", err)
+	}
+
+	opts := []topolib.ProviderOption{
+		topolib.ProviderVoteWeight(v.GetVoteWeight()),
+		topolib.ProviderTimeout(v.GetSoftTimeout()),
+		topolib.ProviderTier(v.GetTier()),
+		topolib.ProviderHTTPClient(httpClient),
+	}
+
+	if exists {
+		if err := r.topo.ReplaceProvider(prov, opts...); err != nil {
+			return fmt.Errorf("cannot replace provider: %w", err)
+		}
+
+		r.logger.ReloadProviderInfo(v.GetName(), "Provider was reconfigured")
+	} else {
+		if err := r.topo.AddProvider(prov, opts...); err != nil {
+			return fmt.Errorf("cannot add provider: %w", err)
+		}
+
+		r.logger.ReloadProviderInfo(v.GetName(), "Provider was added")
+	}
+
+	r.providers[v.GetName()] = v
+
+	return nil
+}
+
+func newReloader(path string,
+	conf *config,
+	topo *topolib.Topographer,
+	auth *basicAuthMiddleware,
+	logger *logger) *reloader {
+	providers := make(map[string]configProvider, len(conf.GetProviders()))
+
+	for _, v := range conf.GetProviders() {
+		providers[v.GetName()] = v
+	}
+
+	return &reloader{
+		path:      path,
+		conf:      conf,
+		providers: providers,
+		topo:      topo,
+		auth:      auth,
+		logger:    logger,
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/9seconds/topographer/topolib"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/suite"
+)
+
+const reloaderTestConfig = `{
+	listen: "127.0.0.1:0"
+	root_directory: %q
+	basic_auth_user: user
+	basic_auth_password: password
+	ready_providers: ["ip2c"]
+	providers: [
+		{name: "ip2c"}
+		{name: "ipinfo", vote_weight: 2, specific_parameters: {auth_token: "token"}}
+	]
+}`
+
+type ReloaderTestSuite struct {
+	suite.Suite
+
+	tmpDir    string
+	path      string
+	reloadLog *bytes.Buffer
+	topo      *topolib.Topographer
+	auth      *basicAuthMiddleware
+	reloader  *reloader
+}
+
+func (suite *ReloaderTestSuite) SetupTest() {
+	suite.tmpDir, _ = ioutil.TempDir("", "topo_reload_test_")
+	suite.path = filepath.Join(suite.tmpDir, "config.hjson")
+	suite.reloadLog = &bytes.Buffer{}
+
+	suite.writeConfig(reloaderTestConfig, suite.tmpDir)
+
+	conf, err := parseConfig(suite.path)
+
+	suite.Require().NoError(err)
+
+	httpClients := makeHTTPClients(conf)
+	providers, err := makeProviders(conf, httpClients)
+
+	suite.Require().NoError(err)
+
+	log := &logger{
+		lookupLog:         zerolog.Nop(),
+		updateLog:         zerolog.Nop(),
+		circuitBreakerLog: zerolog.Nop(),
+		reloadLog:         zerolog.New(suite.reloadLog),
+	}
+
+	suite.topo, err = topolib.NewTopographer(providers, log, 10,
+		makeTopographerOptions(conf, httpClients)...)
+
+	suite.Require().NoError(err)
+
+	suite.auth = newBasicAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), conf.GetBasicAuthUser(), conf.GetBasicAuthPassword())
+	suite.reloader = newReloader(suite.path, conf, suite.topo, suite.auth, log)
+}
+
+func (suite *ReloaderTestSuite) TearDownTest() {
+	suite.topo.Shutdown()
+	os.RemoveAll(suite.tmpDir)
+}
+
+func (suite *ReloaderTestSuite) writeConfig(format string, args ...interface{}) {
+	content := fmt.Sprintf(format, args...)
+
+	suite.Require().NoError(ioutil.WriteFile(suite.path, []byte(content), 0600))
+}
+
+func (suite *ReloaderTestSuite) stats() map[string]*topolib.UsageStats {
+	rv := map[string]*topolib.UsageStats{}
+
+	for _, v := range suite.topo.UsageStats() {
+		rv[v.Name] = v
+	}
+
+	return rv
+}
+
+func (suite *ReloaderTestSuite) authStatus(user, password string) int {
+	req := httptest.NewRequest("GET", "/", nil)
+	resp := httptest.NewRecorder()
+
+	req.SetBasicAuth(user, password)
+	suite.auth.ServeHTTP(resp, req)
+
+	return resp.Code
+}
+
+func (suite *ReloaderTestSuite) TestUnchanged() {
+	before := suite.stats()
+
+	suite.reloader.Reload()
+
+	after := suite.stats()
+
+	suite.Len(after, 2)
+	suite.Same(before["ip2c"], after["ip2c"])
+	suite.Same(before["ipinfo"], after["ipinfo"])
+	suite.NotContains(suite.reloadLog.String(), `"level":"error"`)
+}
+
+func (suite *ReloaderTestSuite) TestAddProvider() {
+	suite.writeConfig(`{
+		listen: "127.0.0.1:0"
+		root_directory: %q
+		basic_auth_user: user
+		basic_auth_password: password
+		ready_providers: ["ip2c"]
+		providers: [
+			{name: "ip2c"}
+			{name: "ipinfo", vote_weight: 2, specific_parameters: {auth_token: "token"}}
+			{name: "ipstack", vote_weight: 3, specific_parameters: {auth_token: "token"}}
+		]
+	}`, suite.tmpDir)
+
+	suite.reloader.Reload()
+
+	stats := suite.stats()
+
+	suite.Len(stats, 3)
+	suite.InEpsilon(3.0, stats["ipstack"].VoteWeight(), 0.001)
+	suite.Contains(suite.reloader.providers, "ipstack")
+	suite.Contains(suite.reloadLog.String(), "Provider was added")
+}
+
+func (suite *ReloaderTestSuite) TestReplaceProvider() {
+	before := suite.stats()
+
+	suite.writeConfig(`{
+		listen: "127.0.0.1:0"
+		root_directory: %q
+		basic_auth_user: user
+		basic_auth_password: password
+		ready_providers: ["ip2c"]
+		providers: [
+			{name: "ip2c"}
+			{name: "ipinfo", vote_weight: 5, specific_parameters: {auth_token: "another"}}
+		]
+	}`, suite.tmpDir)
+
+	suite.reloader.Reload()
+
+	after := suite.stats()
+
+	suite.Len(after, 2)
+	suite.Same(before["ip2c"], after["ip2c"])
+	suite.NotSame(before["ipinfo"], after["ipinfo"])
+	suite.InEpsilon(5.0, after["ipinfo"].VoteWeight(), 0.001)
+	suite.Equal("another",
+		suite.reloader.providers["ipinfo"].GetSpecificParameters()["auth_token"])
+	suite.Contains(suite.reloadLog.String(), "Provider was reconfigured")
+}
+
+func (suite *ReloaderTestSuite) TestAddProviderCannotMake() {
+	before := suite.stats()
+
+	suite.writeConfig(`{
+		listen: "127.0.0.1:0"
+		root_directory: %q
+		basic_auth_user: user
+		basic_auth_password: password
+		ready_providers: ["ip2c"]
+		providers: [
+			{name: "ip2c"}
+			{name: "ipinfo", vote_weight: 2, specific_parameters: {auth_token: "token"}}
+			{name: "unknown"}
+		]
+	}`, suite.tmpDir)
+
+	suite.reloader.Reload()
+
+	after := suite.stats()
+
+	suite.Len(after, 2)
+	suite.Same(before["ipinfo"], after["ipinfo"])
+	suite.NotContains(suite.reloader.providers, "unknown")
+	suite.Contains(suite.reloadLog.String(), "cannot make provider")
+}
+
+func (suite *ReloaderTestSuite) TestRemoveProvider() {
+	suite.writeConfig(`{
+		listen: "127.0.0.1:0"
+		root_directory: %q
+		basic_auth_user: user
+		basic_auth_password: password
+		ready_providers: ["ip2c"]
+		providers: [
+			{name: "ip2c"}
+		]
+	}`, suite.tmpDir)
+
+	suite.reloader.Reload()
+
+	stats := suite.stats()
+
+	suite.Len(stats, 1)
+	suite.Contains(stats, "ip2c")
+	suite.NotContains(suite.reloader.providers, "ipinfo")
+	suite.Contains(suite.reloadLog.String(), "Provider was removed")
+}
+
+func (suite *ReloaderTestSuite) TestRemoveReadyProvider() {
+	suite.writeConfig(`{
+		listen: "127.0.0.1:0"
+		root_directory: %q
+		basic_auth_user: user
+		basic_auth_password: password
+		providers: [
+			{name: "ipinfo", vote_weight: 2, specific_parameters: {auth_token: "token"}}
+		]
+	}`, suite.tmpDir)
+
+	suite.reloader.Reload()
+
+	stats := suite.stats()
+
+	suite.Len(stats, 2)
+	suite.Contains(stats, "ip2c")
+	suite.Contains(suite.reloader.providers, "ip2c")
+	suite.Contains(suite.reloadLog.String(), "it cannot be removed without a restart")
+}
+
+func (suite *ReloaderTestSuite) TestCredentials() {
+	suite.Equal(http.StatusNoContent, suite.authStatus("user", "password"))
+
+	suite.writeConfig(`{
+		listen: "127.0.0.1:0"
+		root_directory: %q
+		basic_auth_user: user
+		basic_auth_password: another
+		ready_providers: ["ip2c"]
+		providers: [
+			{name: "ip2c"}
+			{name: "ipinfo", vote_weight: 2, specific_parameters: {auth_token: "token"}}
+		]
+	}`, suite.tmpDir)
+
+	suite.reloader.Reload()
+
+	suite.Equal(http.StatusUnauthorized, suite.authStatus("user", "password"))
+	suite.Equal(http.StatusNoContent, suite.authStatus("user", "another"))
+}
+
+func (suite *ReloaderTestSuite) TestRestartRequired() {
+	suite.writeConfig(`{
+		listen: "127.0.0.1:1"
+		root_directory: %q
+		basic_auth_user: user
+		basic_auth_password: password
+		ready_providers: ["ip2c"]
+		providers: [
+			{name: "ip2c"}
+			{name: "ipinfo", vote_weight: 2, specific_parameters: {auth_token: "token"}}
+		]
+	}`, suite.tmpDir)
+
+	suite.reloader.Reload()
+
+	suite.Contains(suite.reloadLog.String(), "Some settings require a restart")
+	suite.Len(suite.stats(), 2)
+}
+
+func (suite *ReloaderTestSuite) TestIncorrectConfig() {
+	before := suite.stats()
+
+	suite.writeConfig(`{listen: "incorrect"}`)
+
+	suite.reloader.Reload()
+
+	after := suite.stats()
+
+	suite.Len(after, 2)
+	suite.Same(before["ip2c"], after["ip2c"])
+	suite.Same(before["ipinfo"], after["ipinfo"])
+	suite.Contains(suite.reloadLog.String(), "cannot read config")
+	suite.NotContains(suite.reloadLog.String(), "Config was reloaded")
+	suite.Equal(http.StatusNoContent, suite.authStatus("user", "password"))
+}
+
+func TestReloader(t *testing.T) {
+	suite.Run(t, &ReloaderTestSuite{})
+}
@@ -0,0 +1,123 @@
+package topolib
+
+import "net"
+
+const (
+	// AddressTypePublic is a type of globally routable addresses. Only
+	// such addresses are resolved by providers.
+	AddressTypePublic = "public"
+
+	// AddressTypePrivate is a type of private addresses (RFC 1918) and
+	// IPv6 unique local addresses (RFC 4193).
+	AddressTypePrivate = "private"
+
+	// AddressTypeShared is a type of shared address space used by
+	// carrier-grade NAT (RFC 6598).
+	AddressTypeShared = "shared"
+
+	// AddressTypeLoopback is a type of loopback addresses.
+	AddressTypeLoopback = "loopback"
+
+	// AddressTypeLinkLocal is a type of link-local addresses.
+	AddressTypeLinkLocal = "link_local"
+
+	// AddressTypeDocumentation is a type of addresses reserved for
+	// documentation and examples (RFC 5737, RFC 3849, RFC 9637).
+	AddressTypeDocumentation = "documentation"
+
+	// AddressTypeMulticast is a type of multicast addresses.
+	AddressTypeMulticast = "multicast"
+
+	// AddressTypeReserved is a type of all other addresses from IANA
+	// special-purpose registries which are not globally routable.
+	AddressTypeReserved = "reserved"
+)
+
+type addressTypeNetwork struct {
+	network     *net.IPNet
+	addressType string
+}
+
+var addressTypeNetworks = func() []addressTypeNetwork {
+	networks := []struct {
+		cidr        string
+		addressType string
+	}{
+		// globally reachable blocks of special-purpose ranges, they
+		// have to go before ranges which contain them.
+		{"2001:1::1/128", AddressTypePublic},
+		{"2001:1::2/128", AddressTypePublic},
+		{"2001:1::3/128", AddressTypePublic},
+		{"2001:3::/32", AddressTypePublic},
+		{"2001:4:112::/48", AddressTypePublic},
+		{"2001:20::/28", AddressTypePublic},
+		{"2001:30::/28", AddressTypePublic},
+
+		{"0.0.0.0/8", AddressTypeReserved},
+		{"10.0.0.0/8", AddressTypePrivate},
+		{"100.64.0.0/10", AddressTypeShared},
+		{"127.0.0.0/8", AddressTypeLoopback},
+		{"169.254.0.0/16", AddressTypeLinkLocal},
+		{"172.16.0.0/12", AddressTypePrivate},
+		{"192.0.0.0/24", AddressTypeReserved},
+		{"192.0.2.0/24", AddressTypeDocumentation},
+		{"192.88.99.0/24", AddressTypeReserved},
+		{"192.168.0.0/16", AddressTypePrivate},
+		{"198.18.0.0/15", AddressTypeReserved},
+		{"198.51.100.0/24", AddressTypeDocumentation},
+		{"203.0.113.0/24", AddressTypeDocumentation},
+		{"224.0.0.0/4", AddressTypeMulticast},
+		{"240.0.0.0/4", AddressTypeReserved},
+		{"::/128", AddressTypeReserved},
+		{"::1/128", AddressTypeLoopback},
+		{"64:ff9b:1::/48", AddressTypeReserved},
+		{"100::/64", AddressTypeReserved},
+		{"2001:db8::/32", AddressTypeDocumentation},
+		{"2001::/23", AddressTypeReserved},
+		{"3fff::/20", AddressTypeDocumentation},
+		{"5f00::/16", AddressTypeReserved},
+		{"fc00::/7", AddressTypePrivate},
+		{"fe80::/10", AddressTypeLinkLocal},
+		{"ff00::/8", AddressTypeMulticast},
+	}
+
+	rv := make([]addressTypeNetwork, 0, len(networks))
+
+	for _, v := range networks {
+		_, network, err := net.ParseCIDR(v.cidr)
+		if err != nil {
+			panic(err)
+		}
+
+		rv = append(rv, addressTypeNetwork{
+			network:     network,
+			addressType: v.addressType,
+		})
+	}
+
+	return rv
+}()
+
+// getAddressType classifies IP address. It returns AddressTypePublic
+// if this address does not belong to any special-purpose range or it
+// belongs to its globally reachable block. Please pay attention that
+// more specific ranges have to go first in addressTypeNetworks.
+func getAddressType(ip net.IP) string {
+	for _, v := range addressTypeNetworks {
+		if v.network.Contains(ip) {
+			return v.addressType
+		}
+	}
+
+	return AddressTypePublic
+}
+
+// newSpecialResolveResult returns a result for the address which should
+// not be resolved by providers.
+func newSpecialResolveResult(ip net.IP, addressType string) ResolveResult {
+	return ResolveResult{
+		IP:          ip.To16(),
+		AddressType: addressType,
+		Details:     []ResolveResultDetail{},
+	}
+}
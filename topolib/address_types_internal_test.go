@@ -0,0 +1,72 @@
+package topolib
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type AddressTypeTestSuite struct {
+	suite.Suite
+}
+
+func (suite *AddressTypeTestSuite) TestClassify() {
+	testData := map[string]string{
+		"80.80.80.80":          AddressTypePublic,
+		"2a00:1450:4001::1":    AddressTypePublic,
+		"0.0.0.0":              AddressTypeReserved,
+		"10.0.0.1":             AddressTypePrivate,
+		"172.16.5.4":           AddressTypePrivate,
+		"172.32.0.1":           AddressTypePublic,
+		"192.168.1.1":          AddressTypePrivate,
+		"100.64.0.1":           AddressTypeShared,
+		"100.128.0.1":          AddressTypePublic,
+		"127.0.0.1":            AddressTypeLoopback,
+		"169.254.1.1":          AddressTypeLinkLocal,
+		"192.0.2.10":           AddressTypeDocumentation,
+		"198.51.100.10":        AddressTypeDocumentation,
+		"203.0.113.10":         AddressTypeDocumentation,
+		"198.18.0.1":           AddressTypeReserved,
+		"224.0.0.1":            AddressTypeMulticast,
+		"255.255.255.255":      AddressTypeReserved,
+		"::":                   AddressTypeReserved,
+		"::1":                  AddressTypeLoopback,
+		"::ffff:10.0.0.1":      AddressTypePrivate,
+		"2001:db8::1":          AddressTypeDocumentation,
+		"2001::1":              AddressTypeReserved,
+		"2001:2::1":            AddressTypeReserved,
+		"2001:1::1":            AddressTypePublic,
+		"2001:1::4":            AddressTypeReserved,
+		"2001:3::1":            AddressTypePublic,
+		"2001:4:112::1":        AddressTypePublic,
+		"2001:20::1":           AddressTypePublic,
+		"2001:30::1":           AddressTypePublic,
+		"fd00::1":              AddressTypePrivate,
+		"fe80::1":              AddressTypeLinkLocal,
+		"ff02::1":              AddressTypeMulticast,
+		"100::1":               AddressTypeReserved,
+		"3fff::1":              AddressTypeDocumentation,
+		"2606:4700:4700::1111": AddressTypePublic,
+	}
+
+	for k, v := range testData {
+		ip := net.ParseIP(k)
+
+		suite.Equal(v, getAddressType(ip), k)
+		suite.Equal(v, getAddressType(ip.To16()), k)
+	}
+}
+
+func (suite *AddressTypeTestSuite) TestSpecialResult() {
+	res := newSpecialResolveResult(net.ParseIP("10.0.0.1"), AddressTypePrivate)
+
+	suite.True(res.OK())
+	suite.True(res.Special())
+	suite.NotNil(res.Details)
+	suite.Len(res.IP, net.IPv6len)
+}
+
+func TestAddressType(t *testing.T) {
+	suite.Run(t, &AddressTypeTestSuite{})
+}
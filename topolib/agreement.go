@@ -0,0 +1,216 @@
+package topolib
+
+import (
+	"sort"
+	"sync"
+)
+
+// ProviderAgreement shows how often provider agrees with a final
+// verdict of Topographer.
+//
+// Only lookups where provider has answered with some country are
+// counted. Also, both provider and verdict have to have some value: for
+// example, if provider has not answered with a city or Topographer has
+// not chosen any city, this lookup is neither agreed nor dissented for
+// a city. A city of another country is always a dissent.
+type ProviderAgreement struct {
+	// A name of the provider.
+	Name string `json:"name"`
+
+	// CountryAgreed is a number of lookups where provider has answered
+	// with the same country as the verdict.
+	CountryAgreed uint64 `json:"country_agreed"`
+
+	// CountryDissented is a number of lookups where provider has
+	// answered with a different country.
+	CountryDissented uint64 `json:"country_dissented"`
+
+	// CityAgreed is a number of lookups where provider has answered
+	// with the same city as the verdict.
+	CityAgreed uint64 `json:"city_agreed"`
+
+	// CityDissented is a number of lookups where provider has answered
+	// with a different city.
+	CityDissented uint64 `json:"city_dissented"`
+}
+
+// ProviderPairDisagreement is a cell of pairwise disagreement matrix of
+// providers. Providers are sorted: ProviderA < ProviderB.
+type ProviderPairDisagreement struct {
+	// ProviderA is a name of the first provider.
+	ProviderA string `json:"provider_a"`
+
+	// ProviderB is a name of the second provider.
+	ProviderB string `json:"provider_b"`
+
+	// Compared is a number of lookups where both providers have
+	// answered with some country.
+	Compared uint64 `json:"compared"`
+
+	// Disagreed is a number of lookups where providers have answered
+	// with different countries.
+	Disagreed uint64 `json:"disagreed"`
+}
+
+// AgreementStats is a result of Topographer.AgreementStats.
+type AgreementStats struct {
+	// Providers is a list of agreements of providers with verdicts
+	// sorted by provider name.
+	Providers []ProviderAgreement `json:"providers"`
+
+	// CountryDisagreements is an upper triangle of pairwise country
+	// disagreement matrix. Pairs are sorted by provider names. Pairs
+	// which were never compared are absent.
+	CountryDisagreements []ProviderPairDisagreement `json:"country_disagreements"`
+}
+
+type agreementPair struct {
+	a string
+	b string
+}
+
+type agreementStats struct {
+	mutex     sync.Mutex
+	providers map[string]*ProviderAgreement
+	pairs     map[agreementPair]*ProviderPairDisagreement
+}
+
+func (a *agreementStats) notifyResult(result *ResolveResult, details []ResolveResultDetail) {
+	verdictCountry := Alpha2ToCountryCode(result.Country.Alpha2Code)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for i := range details {
+		current := &details[i]
+
+		if !current.CountryCode.Known() {
+			continue
+		}
+
+		agreement := a.getProvider(current.ProviderName)
+		sameCountry := current.CountryCode == verdictCountry
+
+		switch {
+		case !verdictCountry.Known():
+		case sameCountry:
+			agreement.CountryAgreed++
+		default:
+			agreement.CountryDissented++
+		}
+
+		switch {
+		case result.City == "" || current.City == "":
+		case sameCountry && majorityMerger{}.sameName(result.City, current.City):
+			agreement.CityAgreed++
+		default:
+			agreement.CityDissented++
+		}
+
+		for j := i + 1; j < len(details); j++ {
+			another := &details[j]
+
+			if !another.CountryCode.Known() {
+				continue
+			}
+
+			pair := a.getPair(current.ProviderName, another.ProviderName)
+			pair.Compared++
+
+			if current.CountryCode != another.CountryCode {
+				pair.Disagreed++
+			}
+		}
+	}
+}
+
+func (a *agreementStats) getProvider(name string) *ProviderAgreement {
+	agreement, ok := a.providers[name]
+	if !ok {
+		agreement = &ProviderAgreement{Name: name}
+		a.providers[name] = agreement
+	}
+
+	return agreement
+}
+
+func (a *agreementStats) getPair(one, another string) *ProviderPairDisagreement {
+	key := agreementPair{a: one, b: another}
+	if another < one {
+		key = agreementPair{a: another, b: one}
+	}
+
+	pair, ok := a.pairs[key]
+	if !ok {
+		pair = &ProviderPairDisagreement{
+			ProviderA: key.a,
+			ProviderB: key.b,
+		}
+		a.pairs[key] = pair
+	}
+
+	return pair
+}
+
+// removeProvider forgets agreements of the provider and all pairs
+// where it takes part.
+func (a *agreementStats) removeProvider(name string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	delete(a.providers, name)
+
+	for k := range a.pairs {
+		if k.a == name || k.b == name {
+			delete(a.pairs, k)
+		}
+	}
+}
+
+func (a *agreementStats) snapshot() AgreementStats {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	rv := AgreementStats{
+		Providers:            make([]ProviderAgreement, 0, len(a.providers)),
+		CountryDisagreements: make([]ProviderPairDisagreement, 0, len(a.pairs)),
+	}
+
+	for _, v := range a.providers {
+		rv.Providers = append(rv.Providers, *v)
+	}
+
+	for _, v := range a.pairs {
+		rv.CountryDisagreements = append(rv.CountryDisagreements, *v)
+	}
+
+	sort.Slice(rv.Providers, func(i, j int) bool {
+		return rv.Providers[i].Name < rv.Providers[j].Name
+	})
+	sort.Slice(rv.CountryDisagreements, func(i, j int) bool {
+		left := &rv.CountryDisagreements[i]
+		right := &rv.CountryDisagreements[j]
+
+		if left.ProviderA != right.ProviderA {
+			return left.ProviderA < right.ProviderA
+		}
+
+		return left.ProviderB < right.ProviderB
+	})
+
+	return rv
+}
+
+func newAgreementStats() *agreementStats {
+	return &agreementStats{
+		providers: map[string]*ProviderAgreement{},
+		pairs:     map[agreementPair]*ProviderPairDisagreement{},
+	}
+}
+
+// AgreementStats returns how often providers agree with verdicts
+// of Topographer and with each other. This is useful to find out
+// providers which are often wrong.
+func (t *Topographer) AgreementStats() AgreementStats {
+	return t.agreementStats.snapshot()
+}
@@ -0,0 +1,78 @@
+package topolib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type AgreementStatsTestSuite struct {
+	suite.Suite
+
+	a *agreementStats
+}
+
+func (suite *AgreementStatsTestSuite) SetupTest() {
+	suite.a = newAgreementStats()
+}
+
+func (suite *AgreementStatsTestSuite) TestEmpty() {
+	snapshot := suite.a.snapshot()
+
+	suite.Empty(snapshot.Providers)
+	suite.Empty(snapshot.CountryDisagreements)
+}
+
+func (suite *AgreementStatsTestSuite) TestNotifyResult() {
+	result := &ResolveResult{City: "Moscow"}
+	result.SetCountry(Alpha2ToCountryCode("RU"))
+
+	details := []ResolveResultDetail{
+		{ProviderName: "c", CountryCode: Alpha2ToCountryCode("RU"), City: "moscow"},
+		{ProviderName: "a", CountryCode: Alpha2ToCountryCode("RU"), City: "Saint Petersburg"},
+		{ProviderName: "d"},
+		{ProviderName: "b", CountryCode: Alpha2ToCountryCode("UA")},
+	}
+
+	suite.a.notifyResult(result, details)
+	suite.a.notifyResult(&ResolveResult{}, details)
+
+	suite.Equal(AgreementStats{
+		Providers: []ProviderAgreement{
+			{Name: "a", CountryAgreed: 1, CityDissented: 1},
+			{Name: "b", CountryDissented: 1},
+			{Name: "c", CountryAgreed: 1, CityAgreed: 1},
+		},
+		CountryDisagreements: []ProviderPairDisagreement{
+			{ProviderA: "a", ProviderB: "b", Compared: 2, Disagreed: 2},
+			{ProviderA: "a", ProviderB: "c", Compared: 2},
+			{ProviderA: "b", ProviderB: "c", Compared: 2, Disagreed: 2},
+		},
+	}, suite.a.snapshot())
+}
+
+func (suite *AgreementStatsTestSuite) TestRemoveProvider() {
+	result := &ResolveResult{}
+	result.SetCountry(Alpha2ToCountryCode("RU"))
+
+	suite.a.notifyResult(result, []ResolveResultDetail{
+		{ProviderName: "a", CountryCode: Alpha2ToCountryCode("RU")},
+		{ProviderName: "b", CountryCode: Alpha2ToCountryCode("UA")},
+		{ProviderName: "c", CountryCode: Alpha2ToCountryCode("RU")},
+	})
+	suite.a.removeProvider("b")
+
+	suite.Equal(AgreementStats{
+		Providers: []ProviderAgreement{
+			{Name: "a", CountryAgreed: 1},
+			{Name: "c", CountryAgreed: 1},
+		},
+		CountryDisagreements: []ProviderPairDisagreement{
+			{ProviderA: "a", ProviderB: "c", Compared: 1},
+		},
+	}, suite.a.snapshot())
+}
+
+func TestAgreementStats(t *testing.T) {
+	suite.Run(t, &AgreementStatsTestSuite{})
+}
@@ -33,6 +33,10 @@ func (c cachingProvider) Lookup(ctx context.Context, ip net.IP) (ProviderLookupR
 	return result, nil
 }
 
+func (c cachingProvider) Capabilities() Capability {
+	return getCapabilities(c.Provider)
+}
+
 type cachingOfflineProvider struct {
 	OfflineProvider
 	cachingProvider
@@ -0,0 +1,57 @@
+package topolib
+
+import "net"
+
+// Capability is a set of things provider is able to do. It is a bit
+// mask so capabilities can be combined with | operator.
+type Capability uint16
+
+const (
+	// CapabilityIPv4 means that provider can resolve IPv4 addresses.
+	CapabilityIPv4 Capability = 1 << iota
+
+	// CapabilityIPv6 means that provider can resolve IPv6 addresses.
+	CapabilityIPv6
+
+	// CapabilityCountry means that provider can answer with a country.
+	CapabilityCountry
+
+	// CapabilityCity means that provider can answer with a city.
+	CapabilityCity
+
+	// CapabilityLocation means that provider can answer with
+	// coordinates.
+	CapabilityLocation
+
+	// CapabilityASN means that provider can answer with autonomous
+	// system number and organization.
+	CapabilityASN
+
+	// CapabilityAll is a set of all capabilities. This is what
+	// Topographer assumes for providers which do not implement
+	// CapableProvider interface.
+	CapabilityAll = CapabilityIPv4 | CapabilityIPv6 | CapabilityCountry |
+		CapabilityCity | CapabilityLocation | CapabilityASN
+)
+
+// Has checks if all given capabilities are present.
+func (c Capability) Has(other Capability) bool {
+	return c&other == other
+}
+
+// SupportsIP checks if provider can resolve a given IP address.
+func (c Capability) SupportsIP(ip net.IP) bool {
+	if ip.To4() != nil {
+		return c.Has(CapabilityIPv4)
+	}
+
+	return c.Has(CapabilityIPv6)
+}
+
+func getCapabilities(provider Provider) Capability {
+	if capable, ok := provider.(CapableProvider); ok {
+		return capable.Capabilities()
+	}
+
+	return CapabilityAll
+}
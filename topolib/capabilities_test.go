@@ -0,0 +1,40 @@
+package topolib_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/9seconds/topographer/topolib"
+	"github.com/stretchr/testify/suite"
+)
+
+type CapabilityTestSuite struct {
+	suite.Suite
+}
+
+func (suite *CapabilityTestSuite) TestHas() {
+	capability := topolib.CapabilityIPv4 | topolib.CapabilityCountry
+
+	suite.True(capability.Has(topolib.CapabilityIPv4))
+	suite.True(capability.Has(topolib.CapabilityIPv4 | topolib.CapabilityCountry))
+	suite.False(capability.Has(topolib.CapabilityCity))
+	suite.False(capability.Has(topolib.CapabilityCountry | topolib.CapabilityCity))
+	suite.True(topolib.CapabilityAll.Has(capability))
+}
+
+func (suite *CapabilityTestSuite) TestSupportsIP() {
+	ipv4 := net.ParseIP("80.80.80.80")
+	ipv6 := net.ParseIP("2001:db8::1")
+
+	suite.True(topolib.CapabilityIPv4.SupportsIP(ipv4))
+	suite.True(topolib.CapabilityIPv4.SupportsIP(ipv4.To16()))
+	suite.False(topolib.CapabilityIPv4.SupportsIP(ipv6))
+	suite.True(topolib.CapabilityIPv6.SupportsIP(ipv6))
+	suite.False(topolib.CapabilityIPv6.SupportsIP(ipv4))
+	suite.True(topolib.CapabilityAll.SupportsIP(ipv4))
+	suite.True(topolib.CapabilityAll.SupportsIP(ipv6))
+}
+
+func TestCapability(t *testing.T) {
+	suite.Run(t, &CapabilityTestSuite{})
+}
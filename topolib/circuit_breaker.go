@@ -10,6 +10,10 @@ import (
 
 type circuitBreakerCallback func(context.Context) (*http.Response, error)
 
+// circuitBreakerStateCallback is called when circuit breaker changes
+// its state. It is called under the lock so it has to be fast.
+type circuitBreakerStateCallback func(from, to uint32)
+
 const (
 	circuitBreakerStateClosed uint32 = iota
 	circuitBreakerStateHalfOpened
@@ -25,6 +29,8 @@ type circuitBreaker struct {
 
 	halfOpenAttempts uint32
 	failuresCount    uint32
+	halfOpenAt       int64
+	onStateChange    atomic.Value
 
 	openThreshold        uint32
 	halfOpenTimeout      time.Duration
@@ -42,6 +48,33 @@ func (c *circuitBreaker) Do(ctx context.Context, callback circuitBreakerCallback
 	}
 }
 
+func (c *circuitBreaker) getState() uint32 {
+	return atomic.LoadUint32(&c.state)
+}
+
+func (c *circuitBreaker) getFailuresCount() uint32 {
+	return atomic.LoadUint32(&c.failuresCount)
+}
+
+// getHalfOpenIn returns a time until opened circuit breaker goes into
+// HALF_OPEN state. If circuit breaker is not opened, it returns 0.
+func (c *circuitBreaker) getHalfOpenIn() time.Duration {
+	halfOpenAt := atomic.LoadInt64(&c.halfOpenAt)
+	if halfOpenAt == 0 {
+		return 0
+	}
+
+	if rv := time.Until(time.Unix(0, halfOpenAt)); rv > 0 {
+		return rv
+	}
+
+	return 0
+}
+
+func (c *circuitBreaker) setStateCallback(callback circuitBreakerStateCallback) {
+	c.onStateChange.Store(callback)
+}
+
 func (c *circuitBreaker) doClosed(ctx context.Context, callback circuitBreakerCallback) (*http.Response, error) {
 	resp, err := callback(ctx)
 
@@ -65,9 +98,9 @@ func (c *circuitBreaker) doClosed(ctx context.Context, callback circuitBreakerCa
 		return resp, err
 	}
 
-	c.failuresCount++
+	failuresCount := atomic.AddUint32(&c.failuresCount, 1)
 
-	if c.state == circuitBreakerStateClosed && c.failuresCount > c.openThreshold {
+	if c.state == circuitBreakerStateClosed && failuresCount > c.openThreshold {
 		c.switchState(circuitBreakerStateOpened)
 	}
 
@@ -118,13 +151,26 @@ func (c *circuitBreaker) switchState(state uint32) {
 		c.stopTimer(&c.halfOpenTimer)
 	case circuitBreakerStateOpened:
 		c.stopTimer(&c.failuresCleanupTimer)
+
+		if c.halfOpenTimer == nil {
+			atomic.StoreInt64(&c.halfOpenAt, time.Now().Add(c.halfOpenTimeout).UnixNano())
+		}
+
 		c.ensureTimer(&c.halfOpenTimer, c.halfOpenTimeout, c.tryHalfOpen)
 	}
 
-	c.failuresCount = 0
+	if state != circuitBreakerStateOpened {
+		atomic.StoreInt64(&c.halfOpenAt, 0)
+	}
 
+	atomic.StoreUint32(&c.failuresCount, 0)
 	atomic.StoreUint32(&c.halfOpenAttempts, 0)
-	atomic.StoreUint32(&c.state, state)
+
+	previousState := atomic.SwapUint32(&c.state, state)
+
+	if callback, ok := c.onStateChange.Load().(circuitBreakerStateCallback); ok && previousState != state {
+		callback(previousState, state)
+	}
 }
 
 func (c *circuitBreaker) resetFailures() {
@@ -212,6 +212,57 @@ func (suite *CircuitBreakerTestSuite) TestHalfOpenedOk() {
 	suite.EqualValues(circuitBreakerStateClosed, suite.cb.state)
 }
 
+func (suite *CircuitBreakerTestSuite) TestHalfOpenIn() {
+	suite.EqualValues(0, suite.cb.getHalfOpenIn())
+
+	suite.cb.Do(suite.ctx, suite.CallbackErr) // nolint: errcheck
+	suite.cb.Do(suite.ctx, suite.CallbackErr) // nolint: errcheck
+
+	suite.EqualValues(2, suite.cb.getFailuresCount())
+	suite.EqualValues(0, suite.cb.getHalfOpenIn())
+
+	suite.cb.Do(suite.ctx, suite.CallbackErr) // nolint: errcheck
+
+	halfOpenIn := suite.cb.getHalfOpenIn()
+
+	suite.True(halfOpenIn > 0)
+	suite.True(halfOpenIn <= 200*time.Millisecond)
+
+	time.Sleep(700 * time.Millisecond)
+
+	suite.EqualValues(circuitBreakerStateHalfOpened, suite.cb.getState())
+	suite.EqualValues(0, suite.cb.getHalfOpenIn())
+}
+
+func (suite *CircuitBreakerTestSuite) TestStateCallback() {
+	transitions := [][2]uint32{}
+
+	suite.cb.setStateCallback(func(from, to uint32) {
+		suite.cbMutex.Lock()
+		defer suite.cbMutex.Unlock()
+
+		transitions = append(transitions, [2]uint32{from, to})
+	})
+
+	suite.cb.Do(suite.ctx, suite.CallbackOk)  // nolint: errcheck
+	suite.cb.Do(suite.ctx, suite.CallbackErr) // nolint: errcheck
+	suite.cb.Do(suite.ctx, suite.CallbackErr) // nolint: errcheck
+	suite.cb.Do(suite.ctx, suite.CallbackErr) // nolint: errcheck
+
+	time.Sleep(700 * time.Millisecond)
+
+	suite.cb.Do(suite.ctx, suite.CallbackOk) // nolint: errcheck
+
+	suite.cbMutex.Lock()
+	defer suite.cbMutex.Unlock()
+
+	suite.Equal([][2]uint32{
+		{circuitBreakerStateClosed, circuitBreakerStateOpened},
+		{circuitBreakerStateOpened, circuitBreakerStateHalfOpened},
+		{circuitBreakerStateHalfOpened, circuitBreakerStateClosed},
+	}, transitions)
+}
+
 func (suite *CircuitBreakerTestSuite) TestCheckConcurrentExecutionInHalfOpened() {
 	suite.cb.Do(suite.ctx, suite.CallbackErr) // nolint: errcheck
 	suite.cb.Do(suite.ctx, suite.CallbackErr) // nolint: errcheck
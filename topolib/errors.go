@@ -1,9 +1,12 @@
 package topolib
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"net"
 	"net/http"
+	"strconv"
 )
 
 var (
@@ -15,15 +18,50 @@ var (
 	// of the method.
 	ErrContextIsClosed = errors.New("context is closed")
 
+	// ErrPrefixIncorrect returns if prefix given to ResolvePrefix has
+	// incorrect mask.
+	ErrPrefixIncorrect = errors.New("prefix is incorrect")
+
+	// ErrPrefixTooLarge returns if prefix given to ResolvePrefix is
+	// too large or is split into too many subnets.
+	ErrPrefixTooLarge = errors.New("prefix is too large")
+
 	// ErrCircuitBreakerOpened returns by http client if circuit breaker
 	// is opened.
 	ErrCircuitBreakerOpened = errors.New("circuit breaker is opened")
 
+	// ErrDatabaseIsNotReadyYet returns if you are trying to access an
+	// offline provider but it haven't opened a database yet. For
+	// example, it can be in process of downloading it.
+	ErrDatabaseIsNotReadyYet = errors.New("database is not initialized yet")
+
+	// ErrIPNotFound returns by providers which have no information
+	// about IP address.
+	ErrIPNotFound = errors.New("ip has not been found")
+
+	// ErrIncorrectResponse returns by online providers if a response of
+	// the service cannot be parsed.
+	ErrIncorrectResponse = errors.New("incorrect response")
+
+	// ErrLookupTimedOut is used if provider has not answered before
+	// lookup deadline or its soft timeout.
+	ErrLookupTimedOut = errors.New("lookup has timed out")
+
 	// ErrCircuitBreakerIgnore should be returned if it is necessary to
 	// ignore circuit breaker error in http client.
 	ErrCircuitBreakerIgnore = errors.New("this error should be ignores by circuit breaker")
 )
 
+// HTTPStatusError returns if a service has responded with unexpected
+// HTTP status code.
+type HTTPStatusError struct {
+	StatusCode int
+}
+
+func (h *HTTPStatusError) Error() string {
+	return "unexpected status code: " + strconv.Itoa(h.StatusCode)
+}
+
 type jsonHTTPError struct {
 	Error struct {
 		Message string `json:"message"`
@@ -89,3 +127,45 @@ func (h *httpError) MarshalJSON() ([]byte, error) {
 
 	return json.Marshal(&value)
 }
+
+// Classes of lookup errors which are counted in UsageStats.
+const (
+	ErrorClassCircuitOpen = "circuit_open"
+	ErrorClassTimeout     = "timeout"
+	ErrorClassNotReady    = "not_ready"
+	ErrorClassHTTPStatus  = "http_status"
+	ErrorClassParse       = "parse_error"
+	ErrorClassNotFound    = "not_found"
+	ErrorClassOther       = "other"
+)
+
+// classifyError returns one of ErrorClass* constants for the error.
+func classifyError(err error) string {
+	var (
+		netErr           net.Error
+		statusErr        *HTTPStatusError
+		jsonSyntaxErr    *json.SyntaxError
+		jsonUnmarshalErr *json.UnmarshalTypeError
+	)
+
+	switch {
+	case errors.Is(err, ErrCircuitBreakerOpened):
+		return ErrorClassCircuitOpen
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, ErrLookupTimedOut),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorClassTimeout
+	case errors.Is(err, ErrDatabaseIsNotReadyYet):
+		return ErrorClassNotReady
+	case errors.As(err, &statusErr):
+		return ErrorClassHTTPStatus
+	case errors.Is(err, ErrIncorrectResponse),
+		errors.As(err, &jsonSyntaxErr),
+		errors.As(err, &jsonUnmarshalErr):
+		return ErrorClassParse
+	case errors.Is(err, ErrIPNotFound):
+		return ErrorClassNotFound
+	}
+
+	return ErrorClassOther
+}
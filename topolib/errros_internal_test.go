@@ -1,11 +1,15 @@
 package topolib
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -117,3 +121,43 @@ func (suite *HTTPErrorTestSuite) TestJSON() {
 func TestHTTPError(t *testing.T) {
 	suite.Run(t, &HTTPErrorTestSuite{})
 }
+
+type ClassifyErrorTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ClassifyErrorTestSuite) TestClasses() {
+	jsonErr := json.Unmarshal([]byte("{"), &struct{}{})
+	testData := map[string]error{
+		ErrorClassCircuitOpen: fmt.Errorf("cannot send a request: %w", ErrCircuitBreakerOpened),
+		ErrorClassTimeout:     fmt.Errorf("cannot send a request: %w", context.DeadlineExceeded),
+		ErrorClassNotReady:    ErrDatabaseIsNotReadyYet,
+		ErrorClassHTTPStatus:  fmt.Errorf("cannot send a request: %w", &HTTPStatusError{StatusCode: 500}),
+		ErrorClassParse:       fmt.Errorf("cannot parse a response: %w", jsonErr),
+		ErrorClassNotFound:    fmt.Errorf("cannot lookup: %w", ErrIPNotFound),
+		ErrorClassOther:       io.EOF,
+	}
+
+	for class, err := range testData {
+		suite.Equal(class, classifyError(err), err.Error())
+	}
+}
+
+func (suite *ClassifyErrorTestSuite) TestNetTimeout() {
+	conn, _ := net.Pipe()
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now()) // nolint: errcheck
+
+	_, err := conn.Read(make([]byte, 1))
+
+	suite.Equal(ErrorClassTimeout, classifyError(err))
+}
+
+func (suite *ClassifyErrorTestSuite) TestHTTPStatusError() {
+	suite.EqualError(&HTTPStatusError{StatusCode: 502}, "unexpected status code: 502")
+}
+
+func TestClassifyError(t *testing.T) {
+	suite.Run(t, &ClassifyErrorTestSuite{})
+}
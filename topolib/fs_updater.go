@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -12,9 +13,15 @@ type fsUpdater struct {
 
 	ctx       context.Context
 	ctxCancel context.CancelFunc
+	bgUpdates sync.WaitGroup
 	logger    Logger
 	fs        fsDir
 	stats     *UsageStats
+	onReopen  func()
+}
+
+func (f *fsUpdater) Capabilities() Capability {
+	return getCapabilities(f.OfflineProvider)
 }
 
 func (f *fsUpdater) Start() error {
@@ -31,6 +38,8 @@ func (f *fsUpdater) Start() error {
 		if err := f.Open(targetDir); err == nil {
 			f.stats.notifyUpdated(targetTime)
 
+			f.bgUpdates.Add(1)
+
 			go f.runBgUpdate()
 
 			return nil
@@ -41,17 +50,25 @@ func (f *fsUpdater) Start() error {
 		return fmt.Errorf("cannot make full cleanup: %w", err)
 	}
 
+	f.bgUpdates.Add(1)
+
 	go f.runBgUpdate()
 
 	return nil
 }
 
+// Shutdown stops background updates and waits until an in-flight
+// update is finished, so it does not touch provider or its directory
+// after that. Then provider is shutdown.
 func (f *fsUpdater) Shutdown() {
 	f.ctxCancel()
+	f.bgUpdates.Wait()
 	f.OfflineProvider.Shutdown()
 }
 
 func (f *fsUpdater) runBgUpdate() {
+	defer f.bgUpdates.Done()
+
 	_, modTime, _ := f.fs.GetTargetDir()
 	duration := f.UpdateEvery() - time.Since(modTime)
 
@@ -73,11 +90,7 @@ func (f *fsUpdater) runBgUpdate() {
 		}
 	}
 
-	if err := f.doUpdate(); err != nil {
-		f.logger.UpdateError(f.Name(), err)
-	} else {
-		f.logger.UpdateInfo(f.Name())
-	}
+	f.update()
 
 	ticker := time.NewTicker(f.UpdateEvery())
 	defer func() {
@@ -94,15 +107,23 @@ func (f *fsUpdater) runBgUpdate() {
 		case <-f.ctx.Done():
 			return
 		case <-ticker.C:
-			if err := f.doUpdate(); err != nil {
-				f.logger.UpdateError(f.Name(), err)
-			} else {
-				f.logger.UpdateInfo(f.Name())
-			}
+			f.update()
 		}
 	}
 }
 
+func (f *fsUpdater) update() {
+	err := f.doUpdate()
+
+	f.stats.notifyUpdateResult(err)
+
+	if err != nil {
+		f.logger.UpdateError(f.Name(), err)
+	} else {
+		f.logger.UpdateInfo(f.Name())
+	}
+}
+
 func (f *fsUpdater) doUpdate() error {
 	tmpDir, err := f.fs.TempDir()
 	if err != nil {
@@ -115,6 +136,11 @@ func (f *fsUpdater) doUpdate() error {
 		return fmt.Errorf("cannot download databases: %w", err)
 	}
 
+	// updater is shutdown, provider must not be reopened.
+	if err := f.ctx.Err(); err != nil {
+		return fmt.Errorf("update is cancelled: %w", err)
+	}
+
 	newTargetDir, needToReopen, err := f.fs.Promote(tmpDir)
 	if err != nil {
 		return fmt.Errorf("cannot promote tmp dir: %w", err)
@@ -126,6 +152,10 @@ func (f *fsUpdater) doUpdate() error {
 
 			return fmt.Errorf("cannot open a new target dir: %w", err)
 		}
+
+		if f.onReopen != nil {
+			f.onReopen()
+		}
 	}
 
 	f.fs.Cleanup(newTargetDir) // nolint: errcheck
@@ -134,7 +164,12 @@ func (f *fsUpdater) doUpdate() error {
 	return nil
 }
 
-func newFsUpdater(provider OfflineProvider, logger Logger, stats *UsageStats) (OfflineProvider, error) {
+// newFsUpdater starts background updates of the offline provider.
+// onReopen is called each time when provider opens a new database.
+func newFsUpdater(provider OfflineProvider,
+	logger Logger,
+	stats *UsageStats,
+	onReopen func()) (OfflineProvider, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	updater := &fsUpdater{
@@ -144,6 +179,7 @@ func newFsUpdater(provider OfflineProvider, logger Logger, stats *UsageStats) (O
 		logger:          logger,
 		fs:              fsDir{Dir: provider.BaseDirectory()},
 		stats:           stats,
+		onReopen:        onReopen,
 	}
 
 	if err := updater.Start(); err != nil {
@@ -110,10 +110,20 @@ func (suite *FsUpdaterTestSuite) TestOk() {
 	suite.providerMock.On("Open", targetDir).Return(io.EOF)
 	suite.providerMock.On("Open", mock.Anything).Return(nil)
 
+	reopened := make(chan struct{}, 1)
+	suite.u.onReopen = func() {
+		select {
+		case reopened <- struct{}{}:
+		default:
+		}
+	}
+
 	suite.NoError(suite.u.Start())
 
 	time.Sleep(time.Second + 200*time.Millisecond)
 
+	suite.Len(reopened, 1)
+
 	infos, err := ioutil.ReadDir(suite.baseDir)
 
 	suite.NoError(err)
@@ -123,6 +133,32 @@ func (suite *FsUpdaterTestSuite) TestOk() {
 		infos[0].Name())
 }
 
+func (suite *FsUpdaterTestSuite) TestShutdownWaitsForUpdate() {
+	downloadStarted := make(chan struct{})
+	downloadFinished := make(chan struct{})
+
+	suite.providerMock.On("Download", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		close(downloadStarted)
+		<-args.Get(0).(context.Context).Done()
+		time.Sleep(100 * time.Millisecond)
+		close(downloadFinished)
+	})
+
+	suite.NoError(suite.u.Start())
+
+	<-downloadStarted
+
+	suite.u.Shutdown()
+
+	select {
+	case <-downloadFinished:
+	default:
+		suite.Fail("shutdown has not waited for update")
+	}
+
+	suite.providerMock.AssertNotCalled(suite.T(), "Open", mock.Anything)
+}
+
 func TestFsUpdater(t *testing.T) {
 	suite.Run(t, &FsUpdaterTestSuite{})
 }
@@ -0,0 +1,75 @@
+package topolib
+
+import "math"
+
+// A mean radius of the Earth in kilometers.
+const geoEarthRadius = 6371.0088
+
+// geoDistance returns a great-circle distance between 2 locations in
+// kilometers. It uses haversine formula.
+func geoDistance(a, b *Location) float64 {
+	lat1 := geoRadians(a.Latitude)
+	lat2 := geoRadians(b.Latitude)
+	deltaLat := lat2 - lat1
+	deltaLon := geoRadians(b.Longitude - a.Longitude)
+
+	h := math.Sin(deltaLat/2)*math.Sin(deltaLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(deltaLon/2)*math.Sin(deltaLon/2)
+
+	return 2 * geoEarthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// geoCentroid returns a weighted geographical center of given
+// locations. Coordinates are averaged as vectors on a unit sphere so
+// there are no problems with antimeridian.
+//
+// An accuracy radius of the result is a radius of the circle which
+// covers all accuracy circles of given locations.
+func geoCentroid(locations []*Location, weights []float64) *Location {
+	if len(locations) == 0 {
+		return nil
+	}
+
+	var x, y, z, totalWeight float64
+
+	for i, v := range locations {
+		weight := weights[i]
+		if weight <= 0 {
+			continue
+		}
+
+		lat := geoRadians(v.Latitude)
+		lon := geoRadians(v.Longitude)
+
+		x += weight * math.Cos(lat) * math.Cos(lon)
+		y += weight * math.Cos(lat) * math.Sin(lon)
+		z += weight * math.Sin(lat)
+		totalWeight += weight
+	}
+
+	rv := &Location{}
+
+	if totalWeight == 0 || (math.Hypot(x, y) == 0 && z == 0) {
+		*rv = *locations[0]
+	} else {
+		rv.Latitude = geoDegrees(math.Atan2(z, math.Hypot(x, y)))
+		rv.Longitude = geoDegrees(math.Atan2(y, x))
+	}
+
+	for _, v := range locations {
+		radius := geoDistance(rv, v) + v.AccuracyRadius
+		if radius > rv.AccuracyRadius {
+			rv.AccuracyRadius = radius
+		}
+	}
+
+	return rv
+}
+
+func geoRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func geoDegrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
@@ -0,0 +1,66 @@
+package topolib
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type GeoTestSuite struct {
+	suite.Suite
+}
+
+func (suite *GeoTestSuite) TestDistanceSame() {
+	loc := &Location{Latitude: 50.1109, Longitude: 8.6821}
+
+	suite.InDelta(0, geoDistance(loc, loc), 0.001)
+}
+
+func (suite *GeoTestSuite) TestDistance() {
+	frankfurt := &Location{Latitude: 50.1109, Longitude: 8.6821}
+	offenbach := &Location{Latitude: 50.0956, Longitude: 8.7761}
+	moscow := &Location{Latitude: 55.7558, Longitude: 37.6173}
+
+	suite.InDelta(6.9, geoDistance(frankfurt, offenbach), 0.5)
+	suite.InDelta(2024, geoDistance(frankfurt, moscow), 10)
+	suite.InDelta(geoDistance(moscow, frankfurt), geoDistance(frankfurt, moscow), 0.001)
+}
+
+func (suite *GeoTestSuite) TestCentroidEmpty() {
+	suite.Nil(geoCentroid(nil, nil))
+}
+
+func (suite *GeoTestSuite) TestCentroidSingle() {
+	loc := &Location{Latitude: 50.1109, Longitude: 8.6821, AccuracyRadius: 20}
+	centroid := geoCentroid([]*Location{loc}, []float64{1})
+
+	suite.InDelta(loc.Latitude, centroid.Latitude, 0.0001)
+	suite.InDelta(loc.Longitude, centroid.Longitude, 0.0001)
+	suite.InDelta(20, centroid.AccuracyRadius, 0.001)
+}
+
+func (suite *GeoTestSuite) TestCentroidAntimeridian() {
+	centroid := geoCentroid([]*Location{
+		{Latitude: 0, Longitude: 179},
+		{Latitude: 0, Longitude: -179},
+	}, []float64{1, 1})
+
+	suite.InDelta(0, centroid.Latitude, 0.0001)
+	suite.InDelta(180, math.Abs(centroid.Longitude), 0.0001)
+	suite.InDelta(111, centroid.AccuracyRadius, 1)
+}
+
+func (suite *GeoTestSuite) TestCentroidWeights() {
+	centroid := geoCentroid([]*Location{
+		{Latitude: 10, Longitude: 10},
+		{Latitude: 20, Longitude: 20},
+	}, []float64{1, 0})
+
+	suite.InDelta(10, centroid.Latitude, 0.0001)
+	suite.InDelta(10, centroid.Longitude, 0.0001)
+}
+
+func TestGeo(t *testing.T) {
+	suite.Run(t, &GeoTestSuite{})
+}
@@ -5,6 +5,7 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"time"
 )
 
 type httpHandler struct {
@@ -12,38 +13,68 @@ type httpHandler struct {
 }
 
 func (h httpHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	started := time.Now()
+	writer := &httpStatusWriter{ResponseWriter: w}
+	route, handler := h.getRoute(req)
+
+	handler(writer, req)
+
+	h.topo.httpStats.notifyRequest(route, req.Method, writer.StatusCode(), time.Since(started))
+}
+
+// getRoute returns a name of the route (it is used in metrics) and a
+// handler for the request.
+func (h httpHandler) getRoute(req *http.Request) (string, http.HandlerFunc) {
 	path := strings.Trim(req.URL.Path, "/")
 
 	switch req.Method {
 	case http.MethodGet, http.MethodHead:
 		switch path {
 		case "":
-			h.handleGetResolve(w, req)
+			return "/", h.handleGetResolve
 		case "stats":
-			h.handleGetStats(w, req)
-		default:
-			if ipAddr := net.ParseIP(path); ipAddr != nil {
-				h.handleGetIP(w, req, ipAddr)
-			} else {
-				h.sendError(w, nil, "URL not found", http.StatusNotFound)
+			return "/stats", h.handleGetStats
+		case "metrics":
+			return "/metrics", h.handleGetMetrics
+		case "healthz":
+			return "/healthz", h.handleGetHealthz
+		case "readyz":
+			return "/readyz", h.handleGetReadyz
+		}
+
+		if strings.HasPrefix(path, "cidr/") {
+			return "/cidr/{prefix}", func(w http.ResponseWriter, req *http.Request) {
+				h.handleGetCIDR(w, req, strings.TrimPrefix(path, "cidr/"))
+			}
+		}
+
+		if ipAddr := net.ParseIP(path); ipAddr != nil {
+			return "/{ip}", func(w http.ResponseWriter, req *http.Request) {
+				h.handleGetIP(w, req, ipAddr, nil)
 			}
 		}
 	case http.MethodPost:
 		switch path {
 		case "":
-			h.handlePost(w, req)
-		default:
-			h.sendError(w, nil, "URL not found", http.StatusNotFound)
+			return "/", h.handlePost
+		case "stream":
+			return "/stream", h.handlePostStream
 		}
 	default:
-		h.sendError(w, nil, "Method is not allowed", http.StatusMethodNotAllowed)
+		return "unknown", func(w http.ResponseWriter, req *http.Request) {
+			h.sendError(w, nil, "Method is not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+
+	return "unknown", func(w http.ResponseWriter, req *http.Request) {
+		h.sendError(w, nil, "URL not found", http.StatusNotFound)
 	}
 }
 
 func (h httpHandler) encodeJSON(w http.ResponseWriter, data interface{}) {
 	encoder := json.NewEncoder(w)
 
-	w.Header().Add("Content-Type", "application/json")
+	w.Header().Set("Content-Type", "application/json")
 	encoder.SetEscapeHTML(false)
 	encoder.Encode(data) // nolint: errcheck
 }
@@ -58,3 +89,20 @@ func (h httpHandler) sendError(w http.ResponseWriter, err error, message string,
 	w.WriteHeader(e.StatusCode())
 	h.encodeJSON(w, e)
 }
+
+// handleQueryProviders returns a list of providers from query
+// parameters. Both ?providers=a&providers=b and ?providers=a,b forms
+// are supported.
+func handleQueryProviders(req *http.Request) []string {
+	rv := []string{}
+
+	for _, v := range req.URL.Query()["providers"] {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				rv = append(rv, name)
+			}
+		}
+	}
+
+	return handlePostUniqueProviders(rv)
+}
@@ -6,21 +6,94 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/time/rate"
 )
 
+// Names of circuit breaker states reported in HTTPClientState.
+const (
+	CircuitBreakerStateClosed     = "closed"
+	CircuitBreakerStateHalfOpened = "half_opened"
+	CircuitBreakerStateOpened     = "opened"
+)
+
+var circuitBreakerStateNames = map[uint32]string{
+	circuitBreakerStateClosed:     CircuitBreakerStateClosed,
+	circuitBreakerStateHalfOpened: CircuitBreakerStateHalfOpened,
+	circuitBreakerStateOpened:     CircuitBreakerStateOpened,
+}
+
+// HTTPClientState is a snapshot of the state of HTTP client created by
+// NewHTTPClient. See InspectableHTTPClient.
+type HTTPClientState struct {
+	// CircuitBreakerState is one of CircuitBreakerState* constants.
+	CircuitBreakerState string
+
+	// CircuitBreakerFailures is a number of failures circuit breaker
+	// has counted so far. If it exceeds an open threshold, circuit
+	// breaker becomes opened.
+	CircuitBreakerFailures uint32
+
+	// CircuitBreakerHalfOpenIn is a time until opened circuit breaker
+	// goes into half-opened state. It is 0 if circuit breaker is not
+	// opened.
+	CircuitBreakerHalfOpenIn time.Duration
+
+	// RateLimiterTokens is a number of requests which could be done
+	// right now without waiting. It is negative if there are requests
+	// waiting for rate limiter.
+	RateLimiterTokens float64
+
+	// RateLimiterBurst is a maximal number of tokens of rate limiter.
+	RateLimiterBurst int
+}
+
 type httpClient struct {
 	userAgent      string
 	client         *http.Client
 	rateLimiter    *rate.Limiter
 	circuitBreaker *circuitBreaker
+	stats          *httpClientStats
+}
+
+// httpClientStats collects counters of the rate limiter.
+type httpClientStats struct {
+	waits    uint64
+	waitTime int64
+}
+
+func (h *httpClientStats) notifyRateLimiterWait(delay time.Duration) {
+	atomic.AddUint64(&h.waits, 1)
+	atomic.AddInt64(&h.waitTime, int64(delay))
+}
+
+func (h *httpClientStats) rateLimiterWaits() (uint64, time.Duration) {
+	return atomic.LoadUint64(&h.waits), time.Duration(atomic.LoadInt64(&h.waitTime))
+}
+
+// httpCancelBody cancels a context of the request when response body is
+// closed. So a timeout covers reading of the body as well.
+type httpCancelBody struct {
+	io.ReadCloser
+
+	cancel context.CancelFunc
+}
+
+func (h httpCancelBody) Close() error {
+	defer h.cancel()
+
+	return h.ReadCloser.Close()
 }
 
 func (h httpClient) Do(req *http.Request) (*http.Response, error) {
+	cancel := context.CancelFunc(func() {})
+
 	if h.client.Timeout > 0 {
-		ctx, _ := context.WithTimeout(req.Context(), h.client.Timeout) // nolint: govet
+		var ctx context.Context
+
+		ctx, cancel = context.WithTimeout(req.Context(), h.client.Timeout)
 		req = req.WithContext(ctx)
 	}
 
@@ -31,7 +104,7 @@ func (h httpClient) Do(req *http.Request) (*http.Response, error) {
 	resp, err := h.circuitBreaker.Do(ctx, func(ctx context.Context) (*http.Response, error) {
 		resp, err := h.client.Do(req.WithContext(ctx))
 
-		if err := h.rateLimiter.Wait(ctx); err != nil {
+		if err := h.waitRateLimiter(ctx); err != nil {
 			return nil, ErrCircuitBreakerIgnore
 		}
 
@@ -48,19 +121,65 @@ func (h httpClient) Do(req *http.Request) (*http.Response, error) {
 			io.Copy(ioutil.Discard, resp.Body) // nolint: errcheck
 			resp.Body.Close()
 
-			return nil, fmt.Errorf("netloc has responded with %s", resp.Status)
+			return nil, &HTTPStatusError{StatusCode: resp.StatusCode}
 		}
 
 		return resp, err
 	})
 
 	if resp == nil {
+		cancel()
+
 		return nil, err
 	}
 
+	resp.Body = httpCancelBody{
+		ReadCloser: resp.Body,
+		cancel:     cancel,
+	}
+
 	return resp, err
 }
 
+// State returns a current state of circuit breaker and rate limiter.
+func (h httpClient) State() HTTPClientState {
+	return HTTPClientState{
+		CircuitBreakerState:      circuitBreakerStateNames[h.circuitBreaker.getState()],
+		CircuitBreakerFailures:   h.circuitBreaker.getFailuresCount(),
+		CircuitBreakerHalfOpenIn: h.circuitBreaker.getHalfOpenIn(),
+		RateLimiterTokens:        h.rateLimiter.Tokens(),
+		RateLimiterBurst:         h.rateLimiter.Burst(),
+	}
+}
+
+// waitRateLimiter works like rate.Limiter.Wait but also collects
+// stats on delays.
+func (h httpClient) waitRateLimiter(ctx context.Context) error {
+	reservation := h.rateLimiter.Reserve()
+	if !reservation.OK() {
+		return fmt.Errorf("rate limiter does not allow any request")
+	}
+
+	delay := reservation.Delay()
+	if delay == 0 {
+		return nil
+	}
+
+	h.stats.notifyRateLimiterWait(delay)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		reservation.Cancel()
+
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
 // NewHTTPClient prepares a new HTTP client, wraps it with rate limiter,
 // circuit breaker, sets a user agent etc.
 //
@@ -97,5 +216,6 @@ func NewHTTPClient(client *http.Client,
 		circuitBreaker: newCircuitBreaker(circuitBreakerOpenThreshold,
 			circuitBreakerHalfOpenTimeout,
 			circuitBreakerResetFailuresTimeout),
+		stats: &httpClientStats{},
 	}
 }
@@ -75,6 +75,31 @@ func (suite *HTTPClientTestSuite) TestCannotDial() {
 	suite.Error(err)
 }
 
+func (suite *HTTPClientTestSuite) TestState() {
+	client, ok := suite.c.(topolib.InspectableHTTPClient)
+
+	suite.True(ok)
+
+	state := client.State()
+
+	suite.Equal(topolib.CircuitBreakerStateClosed, state.CircuitBreakerState)
+	suite.EqualValues(0, state.CircuitBreakerFailures)
+	suite.EqualValues(0, state.CircuitBreakerHalfOpenIn)
+	suite.InDelta(1, state.RateLimiterTokens, 0.01)
+	suite.Equal(1, state.RateLimiterBurst)
+
+	req, _ := http.NewRequest("GET", suite.httpbinEndpoint.URL+"/status/500", nil)
+	_, err := suite.c.Do(req)
+
+	suite.Error(err)
+
+	state = client.State()
+
+	suite.Equal(topolib.CircuitBreakerStateClosed, state.CircuitBreakerState)
+	suite.EqualValues(1, state.CircuitBreakerFailures)
+	suite.True(state.RateLimiterTokens < 1)
+}
+
 func TestHTTPClient(t *testing.T) {
 	suite.Run(t, &HTTPClientTestSuite{})
 }
@@ -0,0 +1,173 @@
+package topolib
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	httpHeaderForwarded     = "Forwarded"
+	httpHeaderXForwardedFor = "X-Forwarded-For"
+	httpHeaderXRealIP       = "X-Real-IP"
+)
+
+// httpClientAddress describes where an address of the client was
+// taken from. If Header is empty, this is an address of the peer.
+// Otherwise, Hop is a position of the address in the header counting
+// from the right: 1 is the address added by the nearest proxy.
+type httpClientAddress struct {
+	IP     net.IP `json:"-"`
+	Header string `json:"header"`
+	Hop    int    `json:"hop"`
+}
+
+type httpForwardedHeader struct {
+	name  string
+	parse func([]string) []string
+}
+
+// httpForwardedHeaders is a list of supported headers and functions
+// which extract a list of hops from them.
+var httpForwardedHeaders = []httpForwardedHeader{
+	{httpHeaderForwarded, httpParseForwarded},
+	{httpHeaderXForwardedFor, httpParseXForwardedFor},
+	{httpHeaderXRealIP, httpParseXRealIP},
+}
+
+// getHTTPForwardedHeader returns a supported header with a given name
+// or nil if it is unknown. Names are case-insensitive.
+func getHTTPForwardedHeader(name string) *httpForwardedHeader {
+	for i := range httpForwardedHeaders {
+		if strings.EqualFold(httpForwardedHeaders[i].name, name) {
+			return &httpForwardedHeaders[i]
+		}
+	}
+
+	return nil
+}
+
+// detectClientAddress returns an address of the client. If a peer is
+// one of trusted proxies, a header set by proxies is used (see
+// WithTrustedProxies); other headers are ignored because a client
+// could set them and proxy would pass them as is. The header is walked
+// from right to left and the first address which is not a trusted
+// proxy is taken. If all addresses are trusted, the left-most one is
+// taken.
+func (h httpHandler) detectClientAddress(req *http.Request) (httpClientAddress, error) {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return httpClientAddress{}, fmt.Errorf("cannot parse remote address: %w", err)
+	}
+
+	rv := httpClientAddress{
+		IP: net.ParseIP(host),
+	}
+
+	if rv.IP == nil {
+		return rv, errors.New("incorrect remote address")
+	}
+
+	header := getHTTPForwardedHeader(h.topo.trustedProxyHeader)
+
+	if header == nil || !h.isTrustedProxy(rv.IP) {
+		return rv, nil
+	}
+
+	values := req.Header.Values(header.name)
+	if len(values) == 0 {
+		return rv, nil
+	}
+
+	hops := header.parse(values)
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := httpParseHop(hops[i])
+		if ip == nil {
+			return rv, fmt.Errorf("incorrect address %q in %s header",
+				hops[i], header.name)
+		}
+
+		rv.IP = ip
+		rv.Header = header.name
+		rv.Hop = len(hops) - i
+
+		if !h.isTrustedProxy(ip) {
+			break
+		}
+	}
+
+	return rv, nil
+}
+
+func (h httpHandler) isTrustedProxy(ip net.IP) bool {
+	for _, v := range h.topo.trustedProxies {
+		if v.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// httpParseForwarded extracts for= parameters of RFC 7239 Forwarded
+// header. If some element has no such parameter, an empty string is
+// returned for it so it is treated as an incorrect address.
+func httpParseForwarded(values []string) []string {
+	rv := []string{}
+
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			hop := ""
+
+			for _, pair := range strings.Split(element, ";") {
+				chunks := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+
+				if len(chunks) == 2 && strings.EqualFold(chunks[0], "for") {
+					hop = strings.Trim(strings.TrimSpace(chunks[1]), `"`)
+
+					break
+				}
+			}
+
+			rv = append(rv, hop)
+		}
+	}
+
+	return rv
+}
+
+func httpParseXForwardedFor(values []string) []string {
+	rv := []string{}
+
+	for _, value := range values {
+		rv = append(rv, strings.Split(value, ",")...)
+	}
+
+	return rv
+}
+
+// httpParseXRealIP takes the last X-Real-IP header: it is set by the
+// nearest proxy.
+func httpParseXRealIP(values []string) []string {
+	return values[len(values)-1:]
+}
+
+// httpParseHop parses an address of a single hop. Ports and brackets
+// around IPv6 addresses are ignored. Obfuscated identifiers and
+// 'unknown' are not supported, nil is returned for them.
+func httpParseHop(hop string) net.IP {
+	hop = strings.TrimSpace(hop)
+
+	if ip := net.ParseIP(hop); ip != nil {
+		return ip
+	}
+
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		hop = host
+	}
+
+	return net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(hop, "["), "]"))
+}
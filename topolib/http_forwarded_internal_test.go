@@ -0,0 +1,187 @@
+package topolib
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type HTTPForwardedTestSuite struct {
+	suite.Suite
+
+	h httpHandler
+}
+
+func (suite *HTTPForwardedTestSuite) SetupTest() {
+	networks := []*net.IPNet{}
+
+	for _, v := range []string{"10.0.0.0/8", "2001:db8::/32"} {
+		_, network, _ := net.ParseCIDR(v)
+		networks = append(networks, network)
+	}
+
+	suite.h = httpHandler{
+		topo: &Topographer{
+			trustedProxies:     networks,
+			trustedProxyHeader: httpHeaderXForwardedFor,
+		},
+	}
+}
+
+func (suite *HTTPForwardedTestSuite) detect(remoteAddr string, headers map[string][]string) (httpClientAddress, error) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = remoteAddr
+
+	for k, values := range headers {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+
+	return suite.h.detectClientAddress(req)
+}
+
+func (suite *HTTPForwardedTestSuite) TestIncorrectRemoteAddr() {
+	_, err := suite.detect("lalala", nil)
+
+	suite.Error(err)
+}
+
+func (suite *HTTPForwardedTestSuite) TestUntrustedPeer() {
+	addr, err := suite.detect("80.80.80.80:1234", map[string][]string{
+		"X-Forwarded-For": {"81.2.69.142"},
+	})
+
+	suite.NoError(err)
+	suite.Equal("80.80.80.80", addr.IP.String())
+	suite.Empty(addr.Header)
+	suite.Equal(0, addr.Hop)
+}
+
+func (suite *HTTPForwardedTestSuite) TestTrustedPeerNoHeaders() {
+	addr, err := suite.detect("10.0.0.1:1234", nil)
+
+	suite.NoError(err)
+	suite.Equal("10.0.0.1", addr.IP.String())
+	suite.Empty(addr.Header)
+}
+
+func (suite *HTTPForwardedTestSuite) TestXForwardedForRightmostUntrusted() {
+	addr, err := suite.detect("10.0.0.1:1234", map[string][]string{
+		"X-Forwarded-For": {"1.1.1.1, 81.2.69.142", "10.0.0.2"},
+	})
+
+	suite.NoError(err)
+	suite.Equal("81.2.69.142", addr.IP.String())
+	suite.Equal("X-Forwarded-For", addr.Header)
+	suite.Equal(2, addr.Hop)
+}
+
+func (suite *HTTPForwardedTestSuite) TestXForwardedForAllTrusted() {
+	addr, err := suite.detect("10.0.0.1:1234", map[string][]string{
+		"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"},
+	})
+
+	suite.NoError(err)
+	suite.Equal("10.0.0.3", addr.IP.String())
+	suite.Equal(2, addr.Hop)
+}
+
+func (suite *HTTPForwardedTestSuite) TestXForwardedForIncorrect() {
+	_, err := suite.detect("10.0.0.1:1234", map[string][]string{
+		"X-Forwarded-For": {"lalala, 10.0.0.2"},
+	})
+
+	suite.Error(err)
+}
+
+func (suite *HTTPForwardedTestSuite) TestXForwardedForIncorrectBehindClient() {
+	addr, err := suite.detect("10.0.0.1:1234", map[string][]string{
+		"X-Forwarded-For": {"lalala, 81.2.69.142:5678"},
+	})
+
+	suite.NoError(err)
+	suite.Equal("81.2.69.142", addr.IP.String())
+	suite.Equal(1, addr.Hop)
+}
+
+func (suite *HTTPForwardedTestSuite) TestXForwardedForIgnoresOtherHeaders() {
+	addr, err := suite.detect("10.0.0.1:1234", map[string][]string{
+		"Forwarded":       {"for=8.8.8.8"},
+		"X-Real-Ip":       {"8.8.8.8"},
+		"X-Forwarded-For": {"8.8.4.4, 81.2.69.142"},
+	})
+
+	suite.NoError(err)
+	suite.Equal("81.2.69.142", addr.IP.String())
+	suite.Equal("X-Forwarded-For", addr.Header)
+	suite.Equal(1, addr.Hop)
+}
+
+func (suite *HTTPForwardedTestSuite) TestXForwardedForAbsent() {
+	addr, err := suite.detect("10.0.0.1:1234", map[string][]string{
+		"Forwarded": {"for=8.8.8.8"},
+	})
+
+	suite.NoError(err)
+	suite.Equal("10.0.0.1", addr.IP.String())
+	suite.Empty(addr.Header)
+}
+
+func (suite *HTTPForwardedTestSuite) TestXRealIP() {
+	suite.h.topo.trustedProxyHeader = "x-real-ip"
+
+	addr, err := suite.detect("[2001:db8::1]:1234", map[string][]string{
+		"X-Real-Ip": {"80.80.80.80", "81.2.69.142"},
+	})
+
+	suite.NoError(err)
+	suite.Equal("81.2.69.142", addr.IP.String())
+	suite.Equal("X-Real-IP", addr.Header)
+	suite.Equal(1, addr.Hop)
+}
+
+func (suite *HTTPForwardedTestSuite) TestForwarded() {
+	suite.h.topo.trustedProxyHeader = httpHeaderForwarded
+
+	addr, err := suite.detect("10.0.0.1:1234", map[string][]string{
+		"Forwarded": {
+			`for=80.80.80.80;proto=http, For="[2a00:1450:4001::1]:4711"`,
+			"for=10.0.0.2;by=10.0.0.1",
+		},
+		"X-Forwarded-For": {"81.2.69.142"},
+	})
+
+	suite.NoError(err)
+	suite.Equal("2a00:1450:4001::1", addr.IP.String())
+	suite.Equal("Forwarded", addr.Header)
+	suite.Equal(2, addr.Hop)
+}
+
+func (suite *HTTPForwardedTestSuite) TestForwardedUnknown() {
+	suite.h.topo.trustedProxyHeader = httpHeaderForwarded
+
+	_, err := suite.detect("10.0.0.1:1234", map[string][]string{
+		"Forwarded": {"for=unknown, proto=https"},
+	})
+
+	suite.Error(err)
+}
+
+func (suite *HTTPForwardedTestSuite) TestUnknownHeader() {
+	suite.h.topo.trustedProxyHeader = "X-Client-IP"
+
+	addr, err := suite.detect("10.0.0.1:1234", map[string][]string{
+		"X-Client-Ip": {"81.2.69.142"},
+	})
+
+	suite.NoError(err)
+	suite.Equal("10.0.0.1", addr.IP.String())
+	suite.Empty(addr.Header)
+}
+
+func TestHTTPForwarded(t *testing.T) {
+	suite.Run(t, &HTTPForwardedTestSuite{})
+}
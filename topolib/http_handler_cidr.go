@@ -0,0 +1,52 @@
+package topolib
+
+import (
+	"errors"
+	"net"
+	"net/http"
+)
+
+// httpCIDRResult is a result of resolving of a single subnet of the
+// requested prefix.
+type httpCIDRResult struct {
+	ResolveResult
+
+	Prefix string `json:"prefix"`
+}
+
+func (h httpHandler) handleGetCIDR(w http.ResponseWriter, req *http.Request, cidr string) {
+	_, prefix, err := net.ParseCIDR(cidr)
+	if err != nil {
+		h.sendError(w, err, "Incorrect prefix", http.StatusBadRequest)
+
+		return
+	}
+
+	resolved, err := h.topo.ResolvePrefix(req.Context(), prefix, handleQueryProviders(req))
+
+	switch {
+	case errors.Is(err, ErrPrefixTooLarge), errors.Is(err, ErrPrefixIncorrect):
+		h.sendError(w, err, "Cannot resolve prefix", http.StatusBadRequest)
+
+		return
+	case err != nil:
+		h.sendError(w, err, "Cannot resolve prefix", 0)
+
+		return
+	}
+
+	response := struct {
+		Results []httpCIDRResult `json:"results"`
+	}{
+		Results: make([]httpCIDRResult, len(resolved)),
+	}
+
+	for i, v := range resolved {
+		response.Results[i] = httpCIDRResult{
+			ResolveResult: v.ResolveResult,
+			Prefix:        v.Prefix.String(),
+		}
+	}
+
+	h.encodeJSON(w, response)
+}
@@ -6,24 +6,20 @@ import (
 )
 
 func (h httpHandler) handleGetResolve(w http.ResponseWriter, req *http.Request) {
-	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	clientAddress, err := h.detectClientAddress(req)
 	if err != nil {
 		h.sendError(w, err, "Cannot detect your IP address", 0)
 
 		return
 	}
 
-	ipAddr := net.ParseIP(host)
-	if ipAddr == nil {
-		h.sendError(w, nil, "Address was detected incorrectly", 0)
-
-		return
-	}
-
-	h.handleGetIP(w, req, ipAddr)
+	h.handleGetIP(w, req, clientAddress.IP, &clientAddress)
 }
 
-func (h httpHandler) handleGetIP(w http.ResponseWriter, req *http.Request, ipAddr net.IP) {
+func (h httpHandler) handleGetIP(w http.ResponseWriter,
+	req *http.Request,
+	ipAddr net.IP,
+	clientAddress *httpClientAddress) {
 	resolved, err := h.topo.Resolve(req.Context(), ipAddr, nil)
 	if err != nil {
 		h.sendError(w, err, "Cannot resolve IP address", 0)
@@ -38,9 +34,11 @@ func (h httpHandler) handleGetIP(w http.ResponseWriter, req *http.Request, ipAdd
 	}
 
 	response := struct {
-		Result ResolveResult `json:"result"`
+		Result        ResolveResult      `json:"result"`
+		ClientAddress *httpClientAddress `json:"client_address,omitempty"`
 	}{
-		Result: resolved,
+		Result:        resolved,
+		ClientAddress: clientAddress,
 	}
 
 	h.encodeJSON(w, response)
@@ -48,10 +46,50 @@ func (h httpHandler) handleGetIP(w http.ResponseWriter, req *http.Request, ipAdd
 
 func (h httpHandler) handleGetStats(w http.ResponseWriter, req *http.Request) {
 	response := struct {
-		Results []*UsageStats `json:"results"`
+		Results   []*UsageStats  `json:"results"`
+		Agreement AgreementStats `json:"agreement"`
 	}{
-		Results: h.topo.UsageStats(),
+		Results:   h.topo.UsageStats(),
+		Agreement: h.topo.AgreementStats(),
 	}
 
 	h.encodeJSON(w, response)
 }
+
+func (h httpHandler) handleGetMetrics(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", metricsContentType)
+	h.topo.WriteMetrics(w) // nolint: errcheck
+}
+
+// handleGetHealthz is a liveness probe: it succeeds while Topographer
+// is not shut down, regardless of provider state.
+func (h httpHandler) handleGetHealthz(w http.ResponseWriter, req *http.Request) {
+	alive := !h.topo.isClosed()
+
+	if !alive {
+		h.sendError(w, nil, "Topographer is shut down", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	response := struct {
+		Alive bool `json:"alive"`
+	}{
+		Alive: alive,
+	}
+
+	h.encodeJSON(w, response)
+}
+
+func (h httpHandler) handleGetReadyz(w http.ResponseWriter, req *http.Request) {
+	readiness := h.topo.Readiness()
+
+	if !readiness.Ready {
+		// encodeJSON sets a content type but it is too late after
+		// WriteHeader.
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	h.encodeJSON(w, readiness)
+}
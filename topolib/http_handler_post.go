@@ -5,11 +5,27 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/qri-io/jsonschema"
 )
 
+const (
+	httpPostStatusOK    = "ok"
+	httpPostStatusError = "error"
+)
+
+// httpPostResult is a result of a single IP resolving in a batch. Each
+// result has its own status so a single failure does not break the
+// whole batch.
+type httpPostResult struct {
+	ResolveResult
+
+	Status string `json:"status"`
+	Error  string `json:"error"`
+}
+
 var handlePostRequestJSONSchema = func() *jsonschema.Schema {
 	data := `{
         "type": "object",
@@ -106,34 +122,94 @@ func (h httpHandler) handlePost(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	for i := range resolved {
-		if !resolved[i].OK() {
-			h.sendError(w, nil, "Cannot resolve IP address yet", http.StatusServiceUnavailable)
+	results := handlePostResults(parsedRequest.IPs, resolved)
+
+	if handlePostStrict(req) {
+		for i := range results {
+			if results[i].Status != httpPostStatusOK {
+				h.sendError(w, nil, "Cannot resolve IP address yet", http.StatusServiceUnavailable)
 
-			return
+				return
+			}
 		}
 	}
 
 	response := struct {
-		Results []ResolveResult `json:"results"`
+		Results []httpPostResult `json:"results"`
 	}{
-		Results: resolved,
+		Results: results,
 	}
 
 	h.encodeJSON(w, response)
 }
 
+// handlePostResults matches resolved results with requested IPs. The
+// order of results is the same as the order of requested IPs, and
+// duplicates are repeated. If some IP was not resolved at all (for
+// example, request was cancelled), it gets an error result.
+func handlePostResults(ips []net.IP, resolved []ResolveResult) []httpPostResult {
+	resolvedMap := make(map[string]ResolveResult, len(resolved))
+
+	for _, v := range resolved {
+		resolvedMap[string(v.IP.To16())] = v
+	}
+
+	rv := make([]httpPostResult, 0, len(ips))
+
+	for _, ip := range ips {
+		if result, ok := resolvedMap[string(ip.To16())]; ok {
+			rv = append(rv, handlePostResult(result))
+		} else {
+			rv = append(rv, httpPostResult{
+				ResolveResult: ResolveResult{
+					IP:      ip.To16(),
+					Details: []ResolveResultDetail{},
+				},
+				Status: httpPostStatusError,
+				Error:  "IP address was not resolved",
+			})
+		}
+	}
+
+	return rv
+}
+
+func handlePostResult(result ResolveResult) httpPostResult {
+	if !result.OK() {
+		return httpPostResult{
+			ResolveResult: result,
+			Status:        httpPostStatusError,
+			Error:         "Cannot resolve IP address yet",
+		}
+	}
+
+	return httpPostResult{
+		ResolveResult: result,
+		Status:        httpPostStatusOK,
+	}
+}
+
+// handlePostStrict checks if client wants all-or-nothing behaviour:
+// if any IP cannot be resolved, the whole batch fails.
+func handlePostStrict(req *http.Request) bool {
+	strict, _ := strconv.ParseBool(req.URL.Query().Get("strict"))
+
+	return strict
+}
+
+// handlePostUniqueIPs removes duplicates from the list of IPs. The
+// order of the first occurrences is preserved.
 func handlePostUniqueIPs(ips []net.IP) []net.IP {
 	uniques := map[string]bool{}
+	rv := make([]net.IP, 0, len(ips))
 
 	for _, v := range ips {
-		uniques[string(v)] = true
-	}
+		key := string(v.To16())
 
-	rv := make([]net.IP, 0, len(uniques))
-
-	for k := range uniques {
-		rv = append(rv, net.IP(k))
+		if !uniques[key] {
+			uniques[key] = true
+			rv = append(rv, v)
+		}
 	}
 
 	return rv
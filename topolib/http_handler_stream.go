@@ -0,0 +1,191 @@
+package topolib
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	httpStreamContentType = "application/x-ndjson"
+
+	// A time given to the client to read a single result. Each written
+	// result extends a deadline, so the whole stream is not limited
+	// by server write timeout.
+	httpStreamWriteTimeout = 10 * time.Second
+
+	// Limits of the single line of request body. A line which is
+	// longer than that stops reading of the body.
+	httpStreamInitialLineLength = 4 * 1024
+	httpStreamMaxLineLength     = 64 * 1024
+)
+
+// These interfaces are implemented by http.ResponseWriter of
+// net/http server since go 1.20/1.21. We check them dynamically so
+// streaming works with any writer.
+type httpStreamFullDuplexEnabler interface {
+	EnableFullDuplex() error
+}
+
+type httpStreamDeadlineSetter interface {
+	SetReadDeadline(time.Time) error
+	SetWriteDeadline(time.Time) error
+}
+
+func (h httpHandler) handlePostStream(w http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+
+	ips := make(chan net.IP)
+	readErrors := make(chan string)
+
+	results, err := h.topo.ResolveStream(ctx, ips, handleQueryProviders(req))
+	if err != nil {
+		h.sendError(w, err, "Cannot resolve given IPs", http.StatusInternalServerError)
+
+		return
+	}
+
+	baseWriter := httpUnwrapResponseWriter(w)
+
+	if enabler, ok := baseWriter.(httpStreamFullDuplexEnabler); ok {
+		enabler.EnableFullDuplex() // nolint: errcheck
+	}
+
+	deadlineSetter, _ := baseWriter.(httpStreamDeadlineSetter)
+	if deadlineSetter != nil {
+		deadlineSetter.SetReadDeadline(time.Time{}) // nolint: errcheck
+	}
+
+	go handleStreamReadBody(ctx, req, ips, readErrors)
+
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+
+	encoder.SetEscapeHTML(false)
+	w.Header().Set("Content-Type", httpStreamContentType)
+	w.WriteHeader(http.StatusOK)
+
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	for {
+		var item httpPostResult
+
+		select {
+		case message := <-readErrors:
+			item = httpPostResult{
+				ResolveResult: ResolveResult{
+					Details: []ResolveResultDetail{},
+				},
+				Status: httpPostStatusError,
+				Error:  message,
+			}
+		case result, ok := <-results:
+			if !ok {
+				return
+			}
+
+			item = handlePostResult(result)
+		}
+
+		if deadlineSetter != nil {
+			deadlineSetter.SetWriteDeadline(time.Now().Add(httpStreamWriteTimeout)) // nolint: errcheck
+		}
+
+		if err := encoder.Encode(item); err != nil {
+			return
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// handleStreamReadBody reads a request body line by line and sends
+// parsed IPs into ips channel. Each line is either a plain IP address,
+// a JSON string or a JSON object with 'ip' field.
+//
+// Invalid lines are reported into readErrors channel. If body cannot
+// be read till the end (for example, a line is too long), this is
+// reported there as well, so client knows that the output is truncated.
+func handleStreamReadBody(ctx context.Context,
+	req *http.Request,
+	ips chan<- net.IP,
+	readErrors chan<- string) {
+	defer close(ips)
+
+	scanner := bufio.NewScanner(req.Body)
+
+	scanner.Buffer(make([]byte, 0, httpStreamInitialLineLength), httpStreamMaxLineLength)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if ip := handleStreamParseLine(line); ip != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case ips <- ip:
+			}
+
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case readErrors <- "Incorrect IP address: " + line:
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		select {
+		case <-ctx.Done():
+		case readErrors <- "Cannot read request body, stream is truncated: " + err.Error():
+		}
+	}
+}
+
+func handleStreamParseLine(line string) net.IP {
+	switch line[0] {
+	case '{':
+		value := struct {
+			IP string `json:"ip"`
+		}{}
+
+		if err := json.Unmarshal([]byte(line), &value); err != nil {
+			return nil
+		}
+
+		line = value.IP
+	case '"':
+		if err := json.Unmarshal([]byte(line), &line); err != nil {
+			return nil
+		}
+	}
+
+	return net.ParseIP(strings.TrimSpace(line))
+}
+
+// httpUnwrapResponseWriter returns an original response writer which
+// could be hidden behind wrappers (like httpStatusWriter).
+func httpUnwrapResponseWriter(w http.ResponseWriter) http.ResponseWriter {
+	for {
+		wrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return w
+		}
+
+		w = wrapper.Unwrap()
+	}
+}
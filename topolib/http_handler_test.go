@@ -3,11 +3,13 @@ package topolib_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/9seconds/topographer/topolib"
 	"github.com/qri-io/jsonschema"
@@ -24,12 +26,39 @@ var (
           ],
           "additionalProperties": false,
           "properties": {
+            "client_address": {
+              "type": "object",
+              "required": [
+                "header",
+                "hop"
+              ],
+              "additionalProperties": false,
+              "properties": {
+                "header": {
+                  "type": "string",
+                  "enum": ["", "Forwarded", "X-Forwarded-For", "X-Real-IP"]
+                },
+                "hop": {
+                  "type": "integer",
+                  "minimum": 0
+                }
+              }
+            },
             "result": {
               "type": "object",
               "required": [
                 "ip",
+                "address_type",
                 "country",
                 "city",
+                "region",
+                "location",
+                "country_confidence",
+                "city_confidence",
+                "voters",
+                "abstainers",
+                "asn",
+                "organization",
                 "details"
               ],
               "additionalProperties": false,
@@ -50,6 +79,10 @@ var (
                     }
                   ]
                 },
+                "address_type": {
+                  "type": "string",
+                  "minLength": 1
+                },
                 "country": {
                   "type": "object",
                   "additionalProperties": false,
@@ -97,6 +130,66 @@ var (
                 "city": {
                   "type": "string"
                 },
+                "region": {
+                  "type": "string"
+                },
+                "location": {
+                  "anyOf": [
+                    {
+                      "type": "null"
+                    },
+                    {
+                      "type": "object",
+                      "required": [
+                        "latitude",
+                        "longitude",
+                        "accuracy_radius"
+                      ],
+                      "additionalProperties": false,
+                      "properties": {
+                        "latitude": {
+                          "type": "number",
+                          "minimum": -90,
+                          "maximum": 90
+                        },
+                        "longitude": {
+                          "type": "number",
+                          "minimum": -180,
+                          "maximum": 180
+                        },
+                        "accuracy_radius": {
+                          "type": "number",
+                          "minimum": 0
+                        }
+                      }
+                    }
+                  ]
+                },
+                "country_confidence": {
+                  "type": "number",
+                  "minimum": 0,
+                  "maximum": 1
+                },
+                "city_confidence": {
+                  "type": "number",
+                  "minimum": 0,
+                  "maximum": 1
+                },
+                "asn": {
+                  "type": "integer",
+                  "minimum": 0
+                },
+                "organization": {
+                  "type": "string"
+                },
+                "voters": {
+                  "type": "integer",
+                  "minimum": 0
+                },
+                "abstainers": {
+                  "type": "integer",
+                  "minimum": 0
+                },
                 "details": {
                   "type": "array",
                   "items": {
@@ -104,7 +197,15 @@ var (
                     "required": [
                       "provider_name",
                       "country_code",
-                      "city"
+                      "city",
+                      "region",
+                      "location",
+                      "time_zone",
+                      "postal_code",
+                      "asn",
+                      "organization",
+                      "vote_weight",
+                      "timed_out"
                     ],
                     "additionalProperties": false,
                     "properties": {
@@ -127,6 +228,61 @@ var (
                       },
                       "city": {
                         "type": "string"
+                      },
+                      "region": {
+                        "type": "string"
+                      },
+                      "location": {
+                        "anyOf": [
+                          {
+                            "type": "null"
+                          },
+                          {
+                            "type": "object",
+                            "required": [
+                              "latitude",
+                              "longitude",
+                              "accuracy_radius"
+                            ],
+                            "additionalProperties": false,
+                            "properties": {
+                              "latitude": {
+                                "type": "number",
+                                "minimum": -90,
+                                "maximum": 90
+                              },
+                              "longitude": {
+                                "type": "number",
+                                "minimum": -180,
+                                "maximum": 180
+                              },
+                              "accuracy_radius": {
+                                "type": "number",
+                                "minimum": 0
+                              }
+                            }
+                          }
+                        ]
+                      },
+                      "time_zone": {
+                        "type": "string"
+                      },
+                      "postal_code": {
+                        "type": "string"
+                      },
+                      "asn": {
+                        "type": "integer",
+                        "minimum": 0
+                      },
+                      "organization": {
+                        "type": "string"
+                      },
+                      "vote_weight": {
+                        "type": "number",
+                        "minimum": 0
+                      },
+                      "timed_out": {
+                        "type": "boolean"
                       }
                     }
                   }
@@ -148,20 +304,74 @@ var (
 		data := `{
           "type": "object",
           "required": [
-            "results"
+            "results",
+            "agreement"
           ],
           "additionalProperties": false,
           "properties": {
+            "agreement": {
+              "type": "object",
+              "required": [
+                "providers",
+                "country_disagreements"
+              ],
+              "additionalProperties": false,
+              "properties": {
+                "providers": {
+                  "type": "array",
+                  "items": {
+                    "type": "object",
+                    "required": [
+                      "name",
+                      "country_agreed",
+                      "country_dissented",
+                      "city_agreed",
+                      "city_dissented"
+                    ],
+                    "additionalProperties": false,
+                    "properties": {
+                      "name": {"type": "string", "minLength": 1},
+                      "country_agreed": {"type": "integer", "minimum": 0},
+                      "country_dissented": {"type": "integer", "minimum": 0},
+                      "city_agreed": {"type": "integer", "minimum": 0},
+                      "city_dissented": {"type": "integer", "minimum": 0}
+                    }
+                  }
+                },
+                "country_disagreements": {
+                  "type": "array",
+                  "items": {
+                    "type": "object",
+                    "required": [
+                      "provider_a",
+                      "provider_b",
+                      "compared",
+                      "disagreed"
+                    ],
+                    "additionalProperties": false,
+                    "properties": {
+                      "provider_a": {"type": "string", "minLength": 1},
+                      "provider_b": {"type": "string", "minLength": 1},
+                      "compared": {"type": "integer", "minimum": 0},
+                      "disagreed": {"type": "integer", "minimum": 0}
+                    }
+                  }
+                }
+              }
+            },
             "results": {
               "type": "array",
               "items": {
                 "type": "object",
                 "required": [
                   "name",
+                  "vote_weight",
                   "last_updated",
                   "last_used",
                   "success_count",
-                  "failure_count"
+                  "failure_count",
+                  "failures_by_class",
+                  "latency"
                 ],
                 "additionalProperties": false,
                 "properties": {
@@ -169,6 +379,10 @@ var (
                     "type": "string",
                     "minLength": 1
                   },
+                  "vote_weight": {
+                    "type": "number",
+                    "minimum": 0
+                  },
                   "last_used": {
                     "type": "integer",
                     "minimum": 0
@@ -184,6 +398,134 @@ var (
                   "failure_count": {
                     "type": "integer",
                     "minimum": 0
+                  },
+                  "failures_by_class": {
+                    "type": "object",
+                    "additionalProperties": false,
+                    "properties": {
+                      "circuit_open": {"type": "integer", "minimum": 1},
+                      "timeout": {"type": "integer", "minimum": 1},
+                      "not_ready": {"type": "integer", "minimum": 1},
+                      "http_status": {"type": "integer", "minimum": 1},
+                      "parse_error": {"type": "integer", "minimum": 1},
+                      "not_found": {"type": "integer", "minimum": 1},
+                      "other": {"type": "integer", "minimum": 1}
+                    }
+                  },
+                  "latency": {
+                    "type": "object",
+                    "required": [
+                      "p50",
+                      "p95",
+                      "p99"
+                    ],
+                    "additionalProperties": false,
+                    "properties": {
+                      "p50": {"type": "number", "minimum": 0},
+                      "p95": {"type": "number", "minimum": 0},
+                      "p99": {"type": "number", "minimum": 0}
+                    }
+                  },
+                  "http_client": {
+                    "type": "object",
+                    "required": [
+                      "circuit_breaker_state",
+                      "circuit_breaker_failures",
+                      "circuit_breaker_half_open_in",
+                      "rate_limiter_tokens",
+                      "rate_limiter_burst"
+                    ],
+                    "additionalProperties": false,
+                    "properties": {
+                      "circuit_breaker_state": {
+                        "type": "string",
+                        "enum": ["closed", "half_opened", "opened"]
+                      },
+                      "circuit_breaker_failures": {
+                        "type": "integer",
+                        "minimum": 0
+                      },
+                      "circuit_breaker_half_open_in": {
+                        "type": "number",
+                        "minimum": 0
+                      },
+                      "rate_limiter_tokens": {
+                        "type": "number"
+                      },
+                      "rate_limiter_burst": {
+                        "type": "integer",
+                        "minimum": 0
+                      }
+                    }
+                  }
+                }
+              }
+            }
+          }
+        }`
+
+		rv := &jsonschema.Schema{}
+		if err := json.Unmarshal([]byte(data), rv); err != nil {
+			panic(err)
+		}
+
+		return rv
+	}()
+
+	jsonSchemaGETReadyz = func() *jsonschema.Schema {
+		data := `{
+          "type": "object",
+          "required": [
+            "ready",
+            "min_providers",
+            "ready_providers",
+            "providers"
+          ],
+          "additionalProperties": false,
+          "properties": {
+            "ready": {
+              "type": "boolean"
+            },
+            "min_providers": {
+              "type": "integer",
+              "minimum": 0
+            },
+            "ready_providers": {
+              "type": "integer",
+              "minimum": 0
+            },
+            "providers": {
+              "type": "array",
+              "items": {
+                "type": "object",
+                "required": [
+                  "name",
+                  "ready",
+                  "required",
+                  "status"
+                ],
+                "additionalProperties": false,
+                "properties": {
+                  "name": {
+                    "type": "string",
+                    "minLength": 1
+                  },
+                  "ready": {
+                    "type": "boolean"
+                  },
+                  "required": {
+                    "type": "boolean"
+                  },
+                  "status": {
+                    "type": "string",
+                    "enum": [
+                      "database_opened",
+                      "database_not_ready",
+                      "circuit_breaker_closed",
+                      "circuit_breaker_half_opened",
+                      "circuit_breaker_opened",
+                      "available"
+                    ]
                   }
                 }
               }
@@ -213,12 +555,30 @@ var (
                 "type": "object",
                 "required": [
                   "ip",
+                  "address_type",
                   "country",
                   "city",
-                  "details"
+                  "region",
+                  "location",
+                  "country_confidence",
+                  "city_confidence",
+                  "voters",
+                  "abstainers",
+                  "asn",
+                  "organization",
+                  "details",
+                  "status",
+                  "error"
                 ],
                 "additionalProperties": false,
                 "properties": {
+                  "status": {
+                    "type": "string",
+                    "enum": ["ok", "error"]
+                  },
+                  "error": {
+                    "type": "string"
+                  },
                   "ip": {
                     "anyOf": [
                       {
@@ -235,6 +595,10 @@ var (
                       }
                     ]
                   },
+                  "address_type": {
+                    "type": "string",
+                    "minLength": 1
+                  },
                   "country": {
                     "type": "object",
                     "additionalProperties": false,
@@ -282,6 +646,66 @@ var (
                   "city": {
                     "type": "string"
                   },
+                  "region": {
+                    "type": "string"
+                  },
+                  "location": {
+                    "anyOf": [
+                      {
+                        "type": "null"
+                      },
+                      {
+                        "type": "object",
+                        "required": [
+                          "latitude",
+                          "longitude",
+                          "accuracy_radius"
+                        ],
+                        "additionalProperties": false,
+                        "properties": {
+                          "latitude": {
+                            "type": "number",
+                            "minimum": -90,
+                            "maximum": 90
+                          },
+                          "longitude": {
+                            "type": "number",
+                            "minimum": -180,
+                            "maximum": 180
+                          },
+                          "accuracy_radius": {
+                            "type": "number",
+                            "minimum": 0
+                          }
+                        }
+                      }
+                    ]
+                  },
+                  "country_confidence": {
+                    "type": "number",
+                    "minimum": 0,
+                    "maximum": 1
+                  },
+                  "city_confidence": {
+                    "type": "number",
+                    "minimum": 0,
+                    "maximum": 1
+                  },
+                  "asn": {
+                    "type": "integer",
+                    "minimum": 0
+                  },
+                  "organization": {
+                    "type": "string"
+                  },
+                  "voters": {
+                    "type": "integer",
+                    "minimum": 0
+                  },
+                  "abstainers": {
+                    "type": "integer",
+                    "minimum": 0
+                  },
                   "details": {
                     "type": "array",
                     "items": {
@@ -289,7 +713,15 @@ var (
                       "required": [
                         "provider_name",
                         "country_code",
-                        "city"
+                        "city",
+                        "region",
+                        "location",
+                        "time_zone",
+                        "postal_code",
+                        "asn",
+                        "organization",
+                        "vote_weight",
+                        "timed_out"
                       ],
                       "additionalProperties": false,
                       "properties": {
@@ -312,6 +744,61 @@ var (
                         },
                         "city": {
                           "type": "string"
+                        },
+                        "region": {
+                          "type": "string"
+                        },
+                        "location": {
+                          "anyOf": [
+                            {
+                              "type": "null"
+                            },
+                            {
+                              "type": "object",
+                              "required": [
+                                "latitude",
+                                "longitude",
+                                "accuracy_radius"
+                              ],
+                              "additionalProperties": false,
+                              "properties": {
+                                "latitude": {
+                                  "type": "number",
+                                  "minimum": -90,
+                                  "maximum": 90
+                                },
+                                "longitude": {
+                                  "type": "number",
+                                  "minimum": -180,
+                                  "maximum": 180
+                                },
+                                "accuracy_radius": {
+                                  "type": "number",
+                                  "minimum": 0
+                                }
+                              }
+                            }
+                          ]
+                        },
+                        "time_zone": {
+                          "type": "string"
+                        },
+                        "postal_code": {
+                          "type": "string"
+                        },
+                        "asn": {
+                          "type": "integer",
+                          "minimum": 0
+                        },
+                        "organization": {
+                          "type": "string"
+                        },
+                        "vote_weight": {
+                          "type": "number",
+                          "minimum": 0
+                        },
+                        "timed_out": {
+                          "type": "boolean"
                         }
                       }
                     }
@@ -375,9 +862,16 @@ func (suite *HTTPHandlerTestSuite) TestGetIPOk() {
 	result := topolib.ProviderLookupResult{
 		CountryCode: topolib.Alpha2ToCountryCode("RU"),
 		City:        "Nizhniy Novgorod",
+		Region:      "Nizhny Novgorod Oblast",
+		TimeZone:    "Europe/Moscow",
+		Location: &topolib.Location{
+			Latitude:       56.3287,
+			Longitude:      44.002,
+			AccuracyRadius: 5,
+		},
 	}
-	ip := net.ParseIP("192.168.1.1").To16()
-	req := httptest.NewRequest("GET", "/192.168.1.1/", nil)
+	ip := net.ParseIP("81.2.69.142").To16()
+	req := httptest.NewRequest("GET", "/81.2.69.142/", nil)
 
 	suite.providerMock.On("Lookup", mock.Anything, ip).Return(result, nil).Once()
 
@@ -390,10 +884,12 @@ func (suite *HTTPHandlerTestSuite) TestGetIPOk() {
 
 	suite.NoError(err)
 	suite.Empty(errs)
-	suite.Contains(suite.resp.Body.String(), "192.168.1.1")
+	suite.Contains(suite.resp.Body.String(), "81.2.69.142")
 	suite.Contains(suite.resp.Body.String(), "RU")
 	suite.Contains(suite.resp.Body.String(), "RUS")
 	suite.Contains(suite.resp.Body.String(), "Nizhniy Novgorod")
+	suite.Contains(suite.resp.Body.String(), `"country_confidence":1`)
+	suite.Contains(suite.resp.Body.String(), `"city_confidence":1`)
 }
 
 func (suite *HTTPHandlerTestSuite) TestGetOk() {
@@ -401,9 +897,9 @@ func (suite *HTTPHandlerTestSuite) TestGetOk() {
 		CountryCode: topolib.Alpha2ToCountryCode("RU"),
 		City:        "Nizhniy Novgorod",
 	}
-	ip := net.ParseIP("192.168.1.1").To16()
+	ip := net.ParseIP("81.2.69.142").To16()
 	req := httptest.NewRequest("GET", "/", nil)
-	req.RemoteAddr = "192.168.1.1:5678"
+	req.RemoteAddr = "81.2.69.142:5678"
 
 	suite.providerMock.On("Lookup", mock.Anything, ip).Return(result, nil).Once()
 
@@ -416,24 +912,168 @@ func (suite *HTTPHandlerTestSuite) TestGetOk() {
 
 	suite.NoError(err)
 	suite.Empty(errs)
-	suite.Contains(suite.resp.Body.String(), "192.168.1.1")
+	suite.Contains(suite.resp.Body.String(), "81.2.69.142")
 	suite.Contains(suite.resp.Body.String(), "RU")
 	suite.Contains(suite.resp.Body.String(), "RUS")
 	suite.Contains(suite.resp.Body.String(), "Nizhniy Novgorod")
+	suite.Contains(suite.resp.Body.String(), `"country_confidence":1`)
+	suite.Contains(suite.resp.Body.String(), `"city_confidence":1`)
+}
+
+func (suite *HTTPHandlerTestSuite) TestGetTrustedProxy() {
+	_, network, _ := net.ParseCIDR("10.0.0.0/8")
+	topo, err := topolib.NewTopographer([]topolib.Provider{suite.providerMock},
+		suite.loggerMock, 10, topolib.WithTrustedProxies("X-Forwarded-For", network))
+
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	result := topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("GB"),
+		City:        "London",
+	}
+	ip := net.ParseIP("81.2.69.142").To16()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:5678"
+	req.Header.Set("X-Forwarded-For", "81.2.69.142, 10.0.0.2")
+
+	suite.providerMock.On("Lookup", mock.Anything, ip).Return(result, nil).Once()
+
+	topo.ServeHTTP(suite.resp, req)
+
+	suite.Equal(http.StatusOK, suite.resp.Code)
+
+	errs, err := jsonSchemaGETResolve.ValidateBytes(context.Background(),
+		suite.resp.Body.Bytes())
+
+	suite.NoError(err)
+	suite.Empty(errs)
+	suite.Contains(suite.resp.Body.String(), "81.2.69.142")
+	suite.Contains(suite.resp.Body.String(), `"client_address":{"header":"X-Forwarded-For","hop":2}`)
+}
+
+func (suite *HTTPHandlerTestSuite) TestGetTrustedProxyIncorrectHeader() {
+	_, network, _ := net.ParseCIDR("10.0.0.0/8")
+	topo, err := topolib.NewTopographer([]topolib.Provider{suite.providerMock},
+		suite.loggerMock, 10, topolib.WithTrustedProxies("X-Forwarded-For", network))
+
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:5678"
+	req.Header.Set("X-Forwarded-For", "lalala")
+
+	topo.ServeHTTP(suite.resp, req)
+
+	suite.Equal(http.StatusInternalServerError, suite.resp.Code)
+}
+
+func (suite *HTTPHandlerTestSuite) TestGetTrustedProxyUnknownHeader() {
+	_, network, _ := net.ParseCIDR("10.0.0.0/8")
+	_, err := topolib.NewTopographer([]topolib.Provider{suite.providerMock},
+		suite.loggerMock, 10, topolib.WithTrustedProxies("X-Client-IP", network))
+
+	suite.Error(err)
+}
+
+func (suite *HTTPHandlerTestSuite) TestGetUntrustedProxy() {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.168.1.1:5678"
+	req.Header.Set("X-Forwarded-For", "81.2.69.142")
+
+	suite.h.ServeHTTP(suite.resp, req)
+
+	suite.Equal(http.StatusOK, suite.resp.Code)
+	suite.Contains(suite.resp.Body.String(), `"client_address":{"header":"","hop":0}`)
+	suite.providerMock.AssertNotCalled(suite.T(), "Lookup", mock.Anything, mock.Anything)
+}
+
+func (suite *HTTPHandlerTestSuite) TestGetPrivate() {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.168.1.1:5678"
+
+	suite.h.ServeHTTP(suite.resp, req)
+
+	suite.Equal(http.StatusOK, suite.resp.Code)
+
+	errs, err := jsonSchemaGETResolve.ValidateBytes(context.Background(),
+		suite.resp.Body.Bytes())
+
+	suite.NoError(err)
+	suite.Empty(errs)
+	suite.Contains(suite.resp.Body.String(), "192.168.1.1")
+	suite.Contains(suite.resp.Body.String(), `"address_type":"private"`)
+	suite.providerMock.AssertNotCalled(suite.T(), "Lookup", mock.Anything, mock.Anything)
 }
 
 func (suite *HTTPHandlerTestSuite) TestGetUnkownPath() {
 	req := httptest.NewRequest("GET", "/lalala", nil)
-	req.RemoteAddr = "192.168.1.1:5678"
+	req.RemoteAddr = "81.2.69.142:5678"
 
 	suite.h.ServeHTTP(suite.resp, req)
 
 	suite.Equal(http.StatusNotFound, suite.resp.Code)
 }
 
+func (suite *HTTPHandlerTestSuite) TestGetCIDR() {
+	req := httptest.NewRequest("GET", "/cidr/192.0.0.0/23", nil)
+
+	suite.h.ServeHTTP(suite.resp, req)
+
+	suite.Equal(http.StatusOK, suite.resp.Code)
+
+	response := struct {
+		Results []struct {
+			Prefix      string        `json:"prefix"`
+			IP          string        `json:"ip"`
+			AddressType string        `json:"address_type"`
+			Details     []interface{} `json:"details"`
+		} `json:"results"`
+	}{}
+
+	suite.NoError(json.Unmarshal(suite.resp.Body.Bytes(), &response))
+	suite.Len(response.Results, 2)
+	suite.Equal("192.0.0.0/24", response.Results[0].Prefix)
+	suite.Equal("192.0.0.0", response.Results[0].IP)
+	suite.Equal(topolib.AddressTypeReserved, response.Results[0].AddressType)
+	suite.Equal("192.0.1.0/24", response.Results[1].Prefix)
+	suite.Equal("192.0.1.0", response.Results[1].IP)
+	suite.Equal(topolib.AddressTypePublic, response.Results[1].AddressType)
+	suite.Empty(response.Results[1].Details)
+	suite.providerMock.AssertNotCalled(suite.T(), "Lookup", mock.Anything, mock.Anything)
+}
+
+func (suite *HTTPHandlerTestSuite) TestGetCIDRIncorrect() {
+	req := httptest.NewRequest("GET", "/cidr/192.0.0.0", nil)
+
+	suite.h.ServeHTTP(suite.resp, req)
+
+	suite.Equal(http.StatusBadRequest, suite.resp.Code)
+}
+
+func (suite *HTTPHandlerTestSuite) TestGetCIDRTooLarge() {
+	req := httptest.NewRequest("GET", "/cidr/80.0.0.0/8", nil)
+
+	suite.h.ServeHTTP(suite.resp, req)
+
+	suite.Equal(http.StatusBadRequest, suite.resp.Code)
+	suite.Contains(suite.resp.Body.String(), "prefix is too large")
+}
+
+func (suite *HTTPHandlerTestSuite) TestGetCIDRUnknownProvider() {
+	req := httptest.NewRequest("GET", "/cidr/80.80.80.0/24?providers=unknown", nil)
+
+	suite.h.ServeHTTP(suite.resp, req)
+
+	suite.Equal(http.StatusInternalServerError, suite.resp.Code)
+}
+
 func (suite *HTTPHandlerTestSuite) TestGetStats() {
 	req := httptest.NewRequest("GET", "/stats/", nil)
-	req.RemoteAddr = "192.168.1.1:5678"
+	req.RemoteAddr = "81.2.69.142:5678"
 
 	suite.h.ServeHTTP(suite.resp, req)
 
@@ -446,9 +1086,148 @@ func (suite *HTTPHandlerTestSuite) TestGetStats() {
 	suite.Empty(errs)
 }
 
+func (suite *HTTPHandlerTestSuite) TestGetStatsAgreement() {
+	ip := net.ParseIP("81.2.69.142").To16()
+
+	suite.providerMock.
+		On("Lookup", mock.Anything, ip).
+		Return(topolib.ProviderLookupResult{CountryCode: topolib.Alpha2ToCountryCode("GB")}, nil).
+		Once()
+
+	suite.h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/81.2.69.142", nil))
+	suite.h.ServeHTTP(suite.resp, httptest.NewRequest("GET", "/stats", nil))
+
+	suite.Equal(http.StatusOK, suite.resp.Code)
+
+	errs, err := jsonSchemaGETStats.ValidateBytes(context.Background(),
+		suite.resp.Body.Bytes())
+
+	suite.NoError(err)
+	suite.Empty(errs)
+	suite.Contains(suite.resp.Body.String(),
+		`{"name":"providerMock","country_agreed":1,"country_dissented":0,"city_agreed":0,"city_dissented":0}`)
+}
+
+func (suite *HTTPHandlerTestSuite) TestGetStatsFailures() {
+	ip := net.ParseIP("81.2.69.142").To16()
+
+	suite.providerMock.
+		On("Lookup", mock.Anything, ip).
+		Return(topolib.ProviderLookupResult{}, &topolib.HTTPStatusError{StatusCode: 502}).
+		Once()
+
+	suite.h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/81.2.69.142", nil))
+	suite.h.ServeHTTP(suite.resp, httptest.NewRequest("GET", "/stats", nil))
+
+	suite.Equal(http.StatusOK, suite.resp.Code)
+
+	errs, err := jsonSchemaGETStats.ValidateBytes(context.Background(),
+		suite.resp.Body.Bytes())
+
+	suite.NoError(err)
+	suite.Empty(errs)
+	suite.Contains(suite.resp.Body.String(), `"failures_by_class":{"http_status":1}`)
+}
+
+func (suite *HTTPHandlerTestSuite) TestGetStatsHTTPClient() {
+	client := topolib.NewHTTPClient(&http.Client{}, "test", time.Second, 1,
+		1, time.Minute, time.Minute)
+	topo, err := topolib.NewTopographer([]topolib.Provider{suite.providerMock},
+		suite.loggerMock, 10,
+		topolib.WithProviderHTTPClient("providerMock", client))
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	topo.ServeHTTP(suite.resp, httptest.NewRequest("GET", "/stats", nil))
+
+	suite.Equal(http.StatusOK, suite.resp.Code)
+
+	errs, err := jsonSchemaGETStats.ValidateBytes(context.Background(),
+		suite.resp.Body.Bytes())
+
+	suite.NoError(err)
+	suite.Empty(errs)
+	suite.Contains(suite.resp.Body.String(), `"circuit_breaker_state":"closed"`)
+}
+
+func (suite *HTTPHandlerTestSuite) TestGetMetrics() {
+	result := topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("RU"),
+		City:        "Nizhniy Novgorod",
+	}
+	ip := net.ParseIP("81.2.69.142").To16()
+	req := httptest.NewRequest("GET", "/81.2.69.142", nil)
+
+	suite.providerMock.On("Lookup", mock.Anything, ip).Return(result, nil).Once()
+
+	suite.h.ServeHTTP(suite.resp, req)
+	suite.Equal(http.StatusOK, suite.resp.Code)
+
+	req = httptest.NewRequest("GET", "/metrics", nil)
+	resp := httptest.NewRecorder()
+
+	suite.h.ServeHTTP(resp, req)
+
+	suite.Equal(http.StatusOK, resp.Code)
+	suite.Contains(resp.Header().Get("Content-Type"), "text/plain")
+
+	body := resp.Body.String()
+
+	suite.Contains(body,
+		`topographer_provider_lookups_total{provider="providerMock",outcome="success"} 1`+"\n")
+	suite.Contains(body,
+		`topographer_http_requests_total{route="/{ip}",method="GET",code="200"} 1`+"\n")
+	suite.Contains(body, "# TYPE topographer_provider_lookup_duration_seconds histogram\n")
+	suite.Contains(body, "topographer_worker_pool_capacity ")
+}
+
+func (suite *HTTPHandlerTestSuite) TestGetHealthz() {
+	suite.h.ServeHTTP(suite.resp, httptest.NewRequest("GET", "/healthz", nil))
+
+	suite.Equal(http.StatusOK, suite.resp.Code)
+	suite.JSONEq(`{"alive":true}`, suite.resp.Body.String())
+}
+
+func (suite *HTTPHandlerTestSuite) TestGetHealthzShutdown() {
+	suite.h.(*topolib.Topographer).Shutdown()
+	suite.h.ServeHTTP(suite.resp, httptest.NewRequest("GET", "/healthz", nil))
+
+	suite.Equal(http.StatusServiceUnavailable, suite.resp.Code)
+}
+
+func (suite *HTTPHandlerTestSuite) TestGetReadyz() {
+	suite.h.ServeHTTP(suite.resp, httptest.NewRequest("GET", "/readyz", nil))
+
+	suite.Equal(http.StatusOK, suite.resp.Code)
+
+	errs, err := jsonSchemaGETReadyz.ValidateBytes(context.Background(),
+		suite.resp.Body.Bytes())
+
+	suite.NoError(err)
+	suite.Empty(errs)
+	suite.Contains(suite.resp.Body.String(), `"ready":true`)
+	suite.Contains(suite.resp.Body.String(), `"status":"available"`)
+}
+
+func (suite *HTTPHandlerTestSuite) TestGetReadyzNotReady() {
+	suite.h.(*topolib.Topographer).Shutdown()
+	suite.h.ServeHTTP(suite.resp, httptest.NewRequest("GET", "/readyz", nil))
+
+	suite.Equal(http.StatusServiceUnavailable, suite.resp.Code)
+	suite.Equal([]string{"application/json"}, suite.resp.Result().Header.Values("Content-Type"))
+
+	errs, err := jsonSchemaGETReadyz.ValidateBytes(context.Background(),
+		suite.resp.Body.Bytes())
+
+	suite.NoError(err)
+	suite.Empty(errs)
+	suite.Contains(suite.resp.Body.String(), `"ready":false`)
+}
+
 func (suite *HTTPHandlerTestSuite) TestPostUnknownPath() {
 	req := httptest.NewRequest("POST", "/lalala", nil)
-	req.RemoteAddr = "192.168.1.1:5678"
+	req.RemoteAddr = "81.2.69.142:5678"
 
 	suite.h.ServeHTTP(suite.resp, req)
 
@@ -475,12 +1254,12 @@ func (suite *HTTPHandlerTestSuite) TestPostBadRequest() {
 func (suite *HTTPHandlerTestSuite) TestPostOk() {
 	req := httptest.NewRequest("POST",
 		"/",
-		strings.NewReader(`{"ips": ["192.168.1.1"]}`))
+		strings.NewReader(`{"ips": ["81.2.69.142"]}`))
 	result := topolib.ProviderLookupResult{
 		CountryCode: topolib.Alpha2ToCountryCode("RU"),
 		City:        "Nizhniy Novgorod",
 	}
-	ip := net.ParseIP("192.168.1.1").To16()
+	ip := net.ParseIP("81.2.69.142").To16()
 
 	req.Header.Add("Content-Type", "application/json")
 
@@ -494,10 +1273,207 @@ func (suite *HTTPHandlerTestSuite) TestPostOk() {
 
 	suite.NoError(err)
 	suite.Empty(errs)
-	suite.Contains(suite.resp.Body.String(), "192.168.1.1")
+	suite.Contains(suite.resp.Body.String(), "81.2.69.142")
 	suite.Contains(suite.resp.Body.String(), "RU")
 	suite.Contains(suite.resp.Body.String(), "RUS")
 	suite.Contains(suite.resp.Body.String(), "Nizhniy Novgorod")
+	suite.Contains(suite.resp.Body.String(), `"country_confidence":1`)
+	suite.Contains(suite.resp.Body.String(), `"city_confidence":1`)
+	suite.Contains(suite.resp.Body.String(), `"status":"ok"`)
+}
+
+func (suite *HTTPHandlerTestSuite) TestPostPartial() {
+	req := httptest.NewRequest("POST",
+		"/",
+		strings.NewReader(`{"ips": ["81.2.69.142", "80.80.80.80"]}`))
+	result := topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("RU"),
+		City:        "Nizhniy Novgorod",
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+
+	suite.providerMock.On("Lookup", mock.Anything, net.ParseIP("81.2.69.142").To16()).
+		Return(result, nil).
+		Once()
+	suite.providerMock.On("Lookup", mock.Anything, net.ParseIP("80.80.80.80").To16()).
+		Return(topolib.ProviderLookupResult{}, errors.New("not found")).
+		Once()
+
+	suite.h.ServeHTTP(suite.resp, req)
+
+	suite.Equal(http.StatusOK, suite.resp.Code)
+
+	errs, err := jsonSchemaPOST.ValidateBytes(context.Background(), suite.resp.Body.Bytes())
+
+	suite.NoError(err)
+	suite.Empty(errs)
+	suite.Contains(suite.resp.Body.String(), "Nizhniy Novgorod")
+	suite.Contains(suite.resp.Body.String(), `"status":"ok"`)
+	suite.Contains(suite.resp.Body.String(), `"status":"error"`)
+	suite.Contains(suite.resp.Body.String(), "Cannot resolve IP address yet")
+}
+
+func (suite *HTTPHandlerTestSuite) TestPostOrderAndDuplicates() {
+	req := httptest.NewRequest("POST",
+		"/",
+		strings.NewReader(`{"ips": ["81.2.69.142", "80.80.80.80", "10.0.0.1", "81.2.69.142"]}`))
+
+	req.Header.Add("Content-Type", "application/json")
+
+	suite.providerMock.On("Lookup", mock.Anything, net.ParseIP("81.2.69.142").To16()).
+		Return(topolib.ProviderLookupResult{
+			CountryCode: topolib.Alpha2ToCountryCode("GB"),
+			City:        "London",
+		}, nil).
+		Once()
+	suite.providerMock.On("Lookup", mock.Anything, net.ParseIP("80.80.80.80").To16()).
+		Return(topolib.ProviderLookupResult{
+			CountryCode: topolib.Alpha2ToCountryCode("NL"),
+			City:        "Amsterdam",
+		}, nil).
+		Once()
+
+	suite.h.ServeHTTP(suite.resp, req)
+
+	suite.Equal(http.StatusOK, suite.resp.Code)
+
+	errs, err := jsonSchemaPOST.ValidateBytes(context.Background(), suite.resp.Body.Bytes())
+
+	suite.NoError(err)
+	suite.Empty(errs)
+
+	response := struct {
+		Results []struct {
+			IP   net.IP `json:"ip"`
+			City string `json:"city"`
+		} `json:"results"`
+	}{}
+
+	suite.NoError(json.Unmarshal(suite.resp.Body.Bytes(), &response))
+	suite.Len(response.Results, 4)
+	suite.Equal("81.2.69.142", response.Results[0].IP.String())
+	suite.Equal("London", response.Results[0].City)
+	suite.Equal("80.80.80.80", response.Results[1].IP.String())
+	suite.Equal("Amsterdam", response.Results[1].City)
+	suite.Equal("10.0.0.1", response.Results[2].IP.String())
+	suite.Empty(response.Results[2].City)
+	suite.Equal("81.2.69.142", response.Results[3].IP.String())
+	suite.Equal("London", response.Results[3].City)
+}
+
+func (suite *HTTPHandlerTestSuite) TestPostPartialStrict() {
+	req := httptest.NewRequest("POST",
+		"/?strict=true",
+		strings.NewReader(`{"ips": ["81.2.69.142", "80.80.80.80"]}`))
+	result := topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("RU"),
+		City:        "Nizhniy Novgorod",
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+
+	suite.providerMock.On("Lookup", mock.Anything, net.ParseIP("81.2.69.142").To16()).
+		Return(result, nil).
+		Once()
+	suite.providerMock.On("Lookup", mock.Anything, net.ParseIP("80.80.80.80").To16()).
+		Return(topolib.ProviderLookupResult{}, errors.New("not found")).
+		Once()
+
+	suite.h.ServeHTTP(suite.resp, req)
+
+	suite.Equal(http.StatusServiceUnavailable, suite.resp.Code)
+}
+
+func (suite *HTTPHandlerTestSuite) TestPostStream() {
+	req := httptest.NewRequest("POST",
+		"/stream?providers=providerMock",
+		strings.NewReader("81.2.69.142\n\n\"10.0.0.1\"\n{\"ip\": \"80.80.80.80\"}\nlalala\n"))
+
+	suite.providerMock.On("Lookup", mock.Anything, net.ParseIP("81.2.69.142").To16()).
+		Return(topolib.ProviderLookupResult{
+			CountryCode: topolib.Alpha2ToCountryCode("GB"),
+			City:        "London",
+		}, nil).
+		Once()
+	suite.providerMock.On("Lookup", mock.Anything, net.ParseIP("80.80.80.80").To16()).
+		Return(topolib.ProviderLookupResult{}, errors.New("not found")).
+		Once()
+
+	suite.h.ServeHTTP(suite.resp, req)
+
+	suite.Equal(http.StatusOK, suite.resp.Code)
+	suite.Equal("application/x-ndjson", suite.resp.Header().Get("Content-Type"))
+	suite.True(suite.resp.Flushed)
+
+	lines := strings.Split(strings.TrimSpace(suite.resp.Body.String()), "\n")
+	statuses := map[string]string{}
+
+	suite.Len(lines, 4)
+
+	for _, line := range lines {
+		item := struct {
+			IP     net.IP `json:"ip"`
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		}{}
+
+		suite.NoError(json.Unmarshal([]byte(line), &item))
+
+		if item.IP == nil {
+			statuses[item.Error] = item.Status
+		} else {
+			statuses[item.IP.String()] = item.Status
+		}
+	}
+
+	suite.Equal(map[string]string{
+		"81.2.69.142":                  "ok",
+		"10.0.0.1":                     "ok",
+		"80.80.80.80":                  "error",
+		"Incorrect IP address: lalala": "error",
+	}, statuses)
+}
+
+func (suite *HTTPHandlerTestSuite) TestPostStreamLineTooLong() {
+	req := httptest.NewRequest("POST",
+		"/stream?providers=providerMock",
+		strings.NewReader("10.0.0.1\n"+strings.Repeat("1", 100*1024)+"\n10.0.0.2\n"))
+
+	suite.h.ServeHTTP(suite.resp, req)
+
+	suite.Equal(http.StatusOK, suite.resp.Code)
+
+	lines := strings.Split(strings.TrimSpace(suite.resp.Body.String()), "\n")
+
+	suite.Len(lines, 2)
+
+	items := map[string]string{}
+
+	for _, line := range lines {
+		item := struct {
+			IP     net.IP `json:"ip"`
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		}{}
+
+		suite.NoError(json.Unmarshal([]byte(line), &item))
+
+		items[item.IP.String()+"|"+item.Status] = item.Error
+	}
+
+	suite.Contains(items, "10.0.0.1|ok")
+	suite.Contains(items["<nil>|error"], "stream is truncated")
+}
+
+func (suite *HTTPHandlerTestSuite) TestPostStreamUnknownProvider() {
+	req := httptest.NewRequest("POST",
+		"/stream?providers=providerMock,unknown",
+		strings.NewReader("81.2.69.142\n"))
+
+	suite.h.ServeHTTP(suite.resp, req)
+
+	suite.Equal(http.StatusInternalServerError, suite.resp.Code)
 }
 
 func TestHTTPHandler(t *testing.T) {
@@ -0,0 +1,147 @@
+package topolib
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type httpStatsKey struct {
+	route  string
+	method string
+	code   int
+}
+
+// httpStats collects counters and latencies of HTTP requests served by
+// Topographer. Requests are grouped by route, not by path, so a number
+// of series is bounded.
+type httpStats struct {
+	mutex     sync.Mutex
+	requests  map[httpStatsKey]uint64
+	durations map[string]*metricsHistogram
+}
+
+func (h *httpStats) notifyRequest(route, method string, code int, duration time.Duration) {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost:
+	default:
+		method = "other"
+	}
+
+	h.mutex.Lock()
+
+	h.requests[httpStatsKey{route: route, method: method, code: code}]++
+
+	histogram, ok := h.durations[route]
+	if !ok {
+		histogram = &metricsHistogram{}
+		h.durations[route] = histogram
+	}
+
+	h.mutex.Unlock()
+
+	histogram.observe(duration)
+}
+
+func (h *httpStats) writeMetrics(writer metricsWriter) {
+	h.mutex.Lock()
+
+	keys := make([]httpStatsKey, 0, len(h.requests))
+	counts := make(map[httpStatsKey]uint64, len(h.requests))
+	routes := make([]string, 0, len(h.durations))
+	durations := make(map[string]metricsHistogramSnapshot, len(h.durations))
+
+	for k, v := range h.requests {
+		keys = append(keys, k)
+		counts[k] = v
+	}
+
+	for k, v := range h.durations {
+		routes = append(routes, k)
+		durations[k] = v.snapshot()
+	}
+
+	h.mutex.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		switch {
+		case keys[i].route != keys[j].route:
+			return keys[i].route < keys[j].route
+		case keys[i].method != keys[j].method:
+			return keys[i].method < keys[j].method
+		}
+
+		return keys[i].code < keys[j].code
+	})
+	sort.Strings(routes)
+
+	writer.family("topographer_http_requests_total", "counter",
+		"A number of served HTTP requests.")
+
+	for _, v := range keys {
+		writer.sample("topographer_http_requests_total", float64(counts[v]),
+			metricsLabel{name: "route", value: v.route},
+			metricsLabel{name: "method", value: v.method},
+			metricsLabel{name: "code", value: strconv.Itoa(v.code)})
+	}
+
+	writer.family("topographer_http_request_duration_seconds", "histogram",
+		"A time spent on serving of HTTP requests.")
+
+	for _, v := range routes {
+		writer.histogram("topographer_http_request_duration_seconds", durations[v],
+			metricsLabel{name: "route", value: v})
+	}
+}
+
+func newHTTPStats() *httpStats {
+	return &httpStats{
+		requests:  map[httpStatsKey]uint64{},
+		durations: map[string]*metricsHistogram{},
+	}
+}
+
+// httpStatusWriter remembers a status code of the response.
+type httpStatusWriter struct {
+	http.ResponseWriter
+
+	code int
+}
+
+func (h *httpStatusWriter) WriteHeader(code int) {
+	if h.code == 0 {
+		h.code = code
+	}
+
+	h.ResponseWriter.WriteHeader(code)
+}
+
+func (h *httpStatusWriter) Write(data []byte) (int, error) {
+	if h.code == 0 {
+		h.code = http.StatusOK
+	}
+
+	return h.ResponseWriter.Write(data)
+}
+
+func (h *httpStatusWriter) Flush() {
+	if flusher, ok := h.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns an original response writer. This is the same
+// convention http.ResponseController uses.
+func (h *httpStatusWriter) Unwrap() http.ResponseWriter {
+	return h.ResponseWriter
+}
+
+func (h *httpStatusWriter) StatusCode() int {
+	if h.code == 0 {
+		return http.StatusOK
+	}
+
+	return h.code
+}
@@ -23,6 +23,24 @@ func (m *ProviderMock) Name() string {
 	return m.Called().String(0)
 }
 
+type CapableProviderMock struct {
+	ProviderMock
+}
+
+func (m *CapableProviderMock) Capabilities() topolib.Capability {
+	return m.Called().Get(0).(topolib.Capability)
+}
+
+type PrefixProviderMock struct {
+	ProviderMock
+}
+
+func (m *PrefixProviderMock) Networks(ctx context.Context, prefix *net.IPNet) ([]*net.IPNet, error) {
+	args := m.Called(ctx, prefix)
+
+	return args.Get(0).([]*net.IPNet), args.Error(1)
+}
+
 type OfflineProviderMock struct {
 	ProviderMock
 }
@@ -62,3 +80,19 @@ func (m *LoggerMock) UpdateInfo(name string) {
 func (m *LoggerMock) UpdateError(name string, err error) {
 	m.Called(name, err)
 }
+
+type CircuitBreakerLoggerMock struct {
+	LoggerMock
+}
+
+func (m *CircuitBreakerLoggerMock) CircuitBreakerStateChanged(name string, from, to string) {
+	m.Called(name, from, to)
+}
+
+type MergerMock struct {
+	mock.Mock
+}
+
+func (m *MergerMock) Merge(ip net.IP, results []topolib.ResolveResultDetail) topolib.ResolveResult {
+	return m.Called(ip, results).Get(0).(topolib.ResolveResult)
+}
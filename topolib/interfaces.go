@@ -67,6 +67,60 @@ type OfflineProvider interface {
 	Download(context.Context, string) error
 }
 
+// CapableProvider is an optional interface for Provider which declares
+// what this provider is able to do.
+//
+// Topographer does not ask providers to resolve IP addresses they
+// cannot resolve (for example, IPv6 addresses if provider works only
+// with IPv4) and does not count them as voters for the fields they
+// cannot answer with. If provider does not implement this interface,
+// it is assumed that it can do everything (see CapabilityAll).
+type CapableProvider interface {
+	Provider
+
+	// Capabilities returns a set of address families and fields this
+	// provider supports.
+	Capabilities() Capability
+}
+
+// PrefixProvider is an optional interface for Provider which is able
+// to enumerate networks of its database. Usually these are offline
+// providers.
+//
+// Topographer uses this interface to resolve whole prefixes (see
+// Topographer.ResolvePrefix): it splits a prefix into subnets which are
+// uniform for all providers and resolves each subnet only once.
+type PrefixProvider interface {
+	Provider
+
+	// Networks returns a list of networks from the database which
+	// overlap with a given prefix. It is ok to return networks which
+	// are larger than the prefix: for example, if the prefix is
+	// 1.1.1.0/24 and database has 1.1.0.0/16, it is expected to return
+	// 1.1.0.0/16.
+	//
+	// A prefix is always canonical: IPv4 prefixes have 4 byte IP
+	// address. If there are too many networks, provider may return
+	// ErrPrefixTooLarge.
+	Networks(ctx context.Context, prefix *net.IPNet) ([]*net.IPNet, error)
+}
+
+// Merger consolidates results of providers into a single verdict.
+//
+// Topographer collects results from all providers which were used to
+// resolve an IP address and passes them to Merger. Merger should decide
+// which country and city are the most probable ones. A default
+// implementation is returned by NewMajorityMerger.
+//
+// If you want to have your own consensus logic, implement this
+// interface and pass it with WithMerger option.
+type Merger interface {
+	// Merge takes an IP address and a list of results produced by
+	// providers and returns a consolidated verdict. Results are sorted
+	// by provider names.
+	Merge(ip net.IP, results []ResolveResultDetail) ResolveResult
+}
+
 // Logger is a logger interface used by Topographer.
 //
 // Each method accepts name parameter. name is a name of the provider.
@@ -95,3 +149,27 @@ type Logger interface {
 type HTTPClient interface {
 	Do(*http.Request) (*http.Response, error)
 }
+
+// InspectableHTTPClient is an optional interface for HTTPClient which
+// is able to report a state of its circuit breaker and rate limiter.
+// Clients created by NewHTTPClient implement it.
+//
+// If such client is set with WithProviderHTTPClient, its state is
+// reported in UsageStats of the provider.
+type InspectableHTTPClient interface {
+	HTTPClient
+
+	// State returns a current state of the client.
+	State() HTTPClientState
+}
+
+// CircuitBreakerLogger is an optional interface for Logger. If Logger
+// implements it, Topographer reports changes of circuit breaker state
+// of HTTP clients which were created by NewHTTPClient and set with
+// WithProviderHTTPClient.
+type CircuitBreakerLogger interface {
+	// CircuitBreakerStateChanged notifies that circuit breaker of the
+	// provider has switched from one state to another. States are
+	// CircuitBreakerState* constants.
+	CircuitBreakerStateChanged(name string, from, to string)
+}
@@ -0,0 +1,340 @@
+package topolib
+
+import (
+	"net"
+
+	"github.com/antzucaro/matchr"
+)
+
+type majorityMerger struct{}
+
+func (m majorityMerger) Merge(ip net.IP, results []ResolveResultDetail) ResolveResult {
+	rv := m.mergeCountry(ip, results)
+	group := m.countryGroup(rv.Country.Alpha2Code, results)
+
+	rv.City = m.mergeNames(m.capableGroup(CapabilityCity, group), func(v *ResolveResultDetail) string {
+		return v.City
+	})
+	rv.CityConfidence = m.cityConfidence(rv.City, results)
+	rv.Region = m.mergeRegion(rv.City, group)
+	rv.Location = m.mergeLocation(rv.City, group)
+	rv.ASN, rv.Organization = m.mergeASN(results)
+
+	return rv
+}
+
+// mergeCountry chooses a country and returns a result with filled
+// country-related fields.
+func (m majorityMerger) mergeCountry(ip net.IP, results []ResolveResultDetail) ResolveResult {
+	weights := map[CountryCode]float64{}
+	totalWeight := 0.0
+	abstainers := 0
+
+	voters := 0
+
+	for i := range results {
+		current := &results[i]
+
+		if !current.Can(CapabilityCountry) {
+			continue
+		}
+
+		voters++
+
+		if !current.CountryCode.Known() {
+			abstainers++
+
+			continue
+		}
+
+		weights[current.CountryCode] += current.VoteWeight
+		totalWeight += current.VoteWeight
+	}
+
+	var selectedCountry CountryCode
+
+	for country, weight := range weights {
+		if !selectedCountry.Known() || m.countryWins(country, weight,
+			selectedCountry, weights[selectedCountry]) {
+			selectedCountry = country
+		}
+	}
+
+	rv := ResolveResult{
+		IP:         ip.To16(),
+		Details:    results,
+		Voters:     voters - abstainers,
+		Abstainers: abstainers,
+	}
+
+	rv.SetCountry(selectedCountry)
+
+	if totalWeight > 0 {
+		rv.CountryConfidence = weights[selectedCountry] / totalWeight
+	}
+
+	return rv
+}
+
+// mergeASN chooses the most popular autonomous system among all
+// results and the most popular name of organization among those
+// results which have voted for it. Ties are resolved in favor of
+// the smaller number.
+func (m majorityMerger) mergeASN(results []ResolveResultDetail) (uint32, string) {
+	weights := map[uint32]float64{}
+
+	for i := range results {
+		if results[i].ASN != 0 {
+			weights[results[i].ASN] += results[i].VoteWeight
+		}
+	}
+
+	var selectedASN uint32
+
+	for asn, weight := range weights {
+		if selectedASN == 0 || weight > weights[selectedASN] ||
+			(weight == weights[selectedASN] && asn < selectedASN) {
+			selectedASN = asn
+		}
+	}
+
+	if selectedASN == 0 {
+		return 0, ""
+	}
+
+	group := []*ResolveResultDetail{}
+
+	for i := range results {
+		if results[i].ASN == selectedASN {
+			group = append(group, &results[i])
+		}
+	}
+
+	return selectedASN, m.mergeNames(group, func(v *ResolveResultDetail) string {
+		return v.Organization
+	})
+}
+
+// countryGroup returns those results which have voted for a given
+// country.
+func (m majorityMerger) countryGroup(alpha2 string, results []ResolveResultDetail) []*ResolveResultDetail {
+	rv := []*ResolveResultDetail{}
+
+	if alpha2 == "" {
+		return rv
+	}
+
+	for i := range results {
+		if results[i].CountryCode.String() == alpha2 {
+			rv = append(rv, &results[i])
+		}
+	}
+
+	return rv
+}
+
+// capableGroup returns those results which are able to answer with
+// given fields.
+func (m majorityMerger) capableGroup(capability Capability, results []*ResolveResultDetail) []*ResolveResultDetail {
+	rv := make([]*ResolveResultDetail, 0, len(results))
+
+	for _, v := range results {
+		if v.Can(capability) {
+			rv = append(rv, v)
+		}
+	}
+
+	return rv
+}
+
+// cityConfidence calculates a share of votes for the chosen city among
+// all providers which have answered with some city, regardless of
+// the country they have chosen.
+func (m majorityMerger) cityConfidence(city string, results []ResolveResultDetail) float64 {
+	if city == "" {
+		return 0
+	}
+
+	totalWeight := 0.0
+	cityWeight := 0.0
+
+	for i := range results {
+		if results[i].City == "" || !results[i].Can(CapabilityCity) {
+			continue
+		}
+
+		totalWeight += results[i].VoteWeight
+
+		if m.sameName(city, results[i].City) {
+			cityWeight += results[i].VoteWeight
+		}
+	}
+
+	if totalWeight == 0 {
+		return 0
+	}
+
+	return cityWeight / totalWeight
+}
+
+// mergeRegion chooses the most popular region among those results
+// which have voted for the chosen city. If there are no such results,
+// the whole country group is used.
+func (m majorityMerger) mergeRegion(city string, results []*ResolveResultDetail) string {
+	getter := func(v *ResolveResultDetail) string {
+		return v.Region
+	}
+
+	if region := m.mergeNames(m.cityGroup(city, results), getter); region != "" {
+		return region
+	}
+
+	return m.mergeNames(results, getter)
+}
+
+// mergeLocation averages coordinates of those results which have voted
+// for the chosen city. If there are no such results, coordinates of
+// the whole country group are used.
+func (m majorityMerger) mergeLocation(city string, results []*ResolveResultDetail) *Location {
+	if location := m.averageLocation(m.cityGroup(city, results)); location != nil {
+		return location
+	}
+
+	return m.averageLocation(results)
+}
+
+func (m majorityMerger) averageLocation(results []*ResolveResultDetail) *Location {
+	locations := []*Location{}
+	weights := []float64{}
+
+	for _, v := range results {
+		if v.Location != nil {
+			locations = append(locations, v.Location)
+			weights = append(weights, v.VoteWeight)
+		}
+	}
+
+	return geoCentroid(locations, weights)
+}
+
+// cityGroup returns those results which have voted for a given city.
+func (m majorityMerger) cityGroup(city string, results []*ResolveResultDetail) []*ResolveResultDetail {
+	rv := []*ResolveResultDetail{}
+
+	if city == "" {
+		return rv
+	}
+
+	for _, v := range results {
+		if v.City != "" && m.sameName(city, v.City) {
+			rv = append(rv, v)
+		}
+	}
+
+	return rv
+}
+
+// mergeNames chooses the most popular name among given results. Names
+// are compared by their DoubleMetaphone codes.
+func (m majorityMerger) mergeNames(results []*ResolveResultDetail,
+	getter func(*ResolveResultDetail) string) string {
+	counters := map[string]float64{}
+	spellings := map[string]map[string]float64{}
+
+	for _, v := range results {
+		value := getter(v)
+		if value == "" {
+			continue
+		}
+
+		normalizedName, _ := matchr.DoubleMetaphone(value)
+
+		if _, ok := spellings[normalizedName]; !ok {
+			spellings[normalizedName] = map[string]float64{}
+		}
+
+		counters[normalizedName] += v.VoteWeight
+		spellings[normalizedName][value] += v.VoteWeight
+	}
+
+	maxWeight := 0.0
+	selectedName := ""
+
+	for normalized, weight := range counters {
+		name := m.mostPopularName(spellings[normalized])
+
+		if selectedName == "" || weight > maxWeight || (weight == maxWeight && name < selectedName) {
+			selectedName = name
+			maxWeight = weight
+		}
+	}
+
+	return selectedName
+}
+
+func (m majorityMerger) mostPopularName(spellings map[string]float64) string {
+	maxWeight := 0.0
+	name := ""
+
+	for k, v := range spellings {
+		if name == "" || v > maxWeight || (v == maxWeight && k < name) {
+			name = k
+			maxWeight = v
+		}
+	}
+
+	return name
+}
+
+func (m majorityMerger) sameName(one, another string) bool {
+	oneNormalized, _ := matchr.DoubleMetaphone(one)
+	anotherNormalized, _ := matchr.DoubleMetaphone(another)
+
+	return oneNormalized == anotherNormalized
+}
+
+// countryWins checks if candidate country beats a current one. If they
+// have the same weight of votes, we choose the one which goes first in
+// alphabetical order. It is not really smart, but stable.
+func (m majorityMerger) countryWins(candidate CountryCode, candidateWeight float64,
+	current CountryCode, currentWeight float64) bool {
+	if candidateWeight != currentWeight {
+		return candidateWeight > currentWeight
+	}
+
+	return candidate.String() < current.String()
+}
+
+// NewMajorityMerger returns a default merger used by Topographer.
+//
+// This merger does a simple majority vote: it chooses the most popular
+// country and the most popular city among those providers which voted
+// for this country. Votes are summed by their weights (see
+// WithVoteWeight), so a vote of the provider with a weight of 3 is
+// the same as 3 votes of providers with a weight of 1. City names are
+// compared by their DoubleMetaphone codes so different spellings of the
+// same city are counted together. Region is chosen in the same way
+// among providers which have voted for the chosen city.
+//
+// Ties are resolved deterministically: in case of equal weight of
+// votes, the country (or city) which goes first in alphabetical order
+// wins. So, the same input always produces the same verdict.
+//
+// Confidence of the country is a share of the weight of votes for
+// the chosen country among all providers which have answered with
+// some country. Confidence of the city is calculated in the same way
+// among providers which have answered with some city. Providers which
+// have declared that they cannot answer with a country or a city (see
+// CapableProvider) do not take part in the corresponding vote.
+//
+// Location is an average of coordinates given by providers which
+// have voted for the chosen city (or the chosen country if none of
+// them knows coordinates). Its accuracy radius covers accuracy circles
+// of all these providers.
+//
+// ASN and organization are chosen by the same weighted vote among all
+// providers regardless of the country they have voted for: usually
+// providers which know ASN know nothing about geolocation.
+func NewMajorityMerger() Merger {
+	return majorityMerger{}
+}
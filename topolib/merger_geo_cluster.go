@@ -0,0 +1,176 @@
+package topolib
+
+import "net"
+
+// DefaultGeoClusterRadius is a radius of the cluster in kilometers
+// which is used by NewGeoClusterMerger if no other value is given.
+const DefaultGeoClusterRadius = 50.0
+
+type geoClusterMerger struct {
+	majorityMerger
+
+	radius float64
+}
+
+func (m geoClusterMerger) Merge(ip net.IP, results []ResolveResultDetail) ResolveResult {
+	rv := m.mergeCountry(ip, results)
+	group := m.countryGroup(rv.Country.Alpha2Code, results)
+	cluster := m.largestCluster(group)
+
+	if len(cluster) == 0 {
+		return m.majorityMerger.Merge(ip, results)
+	}
+
+	getter := func(v *ResolveResultDetail) string {
+		return v.City
+	}
+
+	rv.City = m.mergeNames(m.capableGroup(CapabilityCity, m.clusterSupporters(cluster, group)), getter)
+	if rv.City == "" {
+		rv.City = m.mergeNames(m.capableGroup(CapabilityCity, group), getter)
+	}
+
+	rv.CityConfidence = m.clusterConfidence(rv.City, cluster, results)
+
+	rv.Region = m.mergeNames(cluster, func(v *ResolveResultDetail) string {
+		return v.Region
+	})
+	if rv.Region == "" {
+		rv.Region = m.mergeRegion(rv.City, group)
+	}
+
+	rv.Location = m.averageLocation(cluster)
+	rv.ASN, rv.Organization = m.mergeASN(results)
+
+	return rv
+}
+
+// largestCluster returns results which form the heaviest cluster of
+// coordinates. A cluster is a set of locations which are not further
+// than radius from its core. Each location is tried as a core so
+// the result does not depend on the order of providers, except of
+// ties: here the first core (by provider name) wins.
+func (m geoClusterMerger) largestCluster(results []*ResolveResultDetail) []*ResolveResultDetail {
+	var (
+		rv        []*ResolveResultDetail
+		maxWeight float64
+	)
+
+	for _, core := range results {
+		if core.Location == nil {
+			continue
+		}
+
+		cluster := []*ResolveResultDetail{}
+		weight := 0.0
+
+		for _, v := range results {
+			if v.Location != nil && geoDistance(core.Location, v.Location) <= m.radius {
+				cluster = append(cluster, v)
+				weight += v.VoteWeight
+			}
+		}
+
+		if rv == nil || weight > maxWeight {
+			rv = cluster
+			maxWeight = weight
+		}
+	}
+
+	return rv
+}
+
+// clusterSupporters extends a cluster with those results which have no
+// coordinates but have voted for one of the cluster cities.
+func (m geoClusterMerger) clusterSupporters(cluster []*ResolveResultDetail,
+	results []*ResolveResultDetail) []*ResolveResultDetail {
+	rv := append([]*ResolveResultDetail{}, cluster...)
+
+	for _, v := range results {
+		if v.Location != nil || v.City == "" {
+			continue
+		}
+
+		for _, member := range cluster {
+			if member.City != "" && m.sameName(member.City, v.City) {
+				rv = append(rv, v)
+
+				break
+			}
+		}
+	}
+
+	return rv
+}
+
+// clusterConfidence calculates a share of votes for the chosen area
+// among all providers which have answered with some city. A provider
+// agrees if it belongs to the cluster or has voted for the chosen city
+// even without coordinates.
+func (m geoClusterMerger) clusterConfidence(city string,
+	cluster []*ResolveResultDetail,
+	results []ResolveResultDetail) float64 {
+	if city == "" {
+		return 0
+	}
+
+	inCluster := map[*ResolveResultDetail]bool{}
+
+	for _, v := range cluster {
+		inCluster[v] = true
+	}
+
+	totalWeight := 0.0
+	agreedWeight := 0.0
+
+	for i := range results {
+		current := &results[i]
+
+		if current.City == "" || !current.Can(CapabilityCity) {
+			continue
+		}
+
+		totalWeight += current.VoteWeight
+
+		if inCluster[current] || m.sameName(city, current.City) {
+			agreedWeight += current.VoteWeight
+		}
+	}
+
+	if totalWeight == 0 {
+		return 0
+	}
+
+	return agreedWeight / totalWeight
+}
+
+// NewGeoClusterMerger returns a merger which relies on coordinates
+// rather than on city names.
+//
+// A country is chosen in the same way as NewMajorityMerger does.
+// After that, coordinates of providers which have voted for this
+// country are clustered by great-circle distance: locations which
+// are not further than clusterRadius kilometers from some core
+// location form a cluster. The heaviest cluster (by weights of votes)
+// wins. Location is a centroid of this cluster and its accuracy radius
+// covers accuracy circles of all cluster members. City and region are
+// the most popular names among cluster members (providers without
+// coordinates support the city they have named if it is in the
+// cluster).
+//
+// So, if one provider says Frankfurt am Main and another one says
+// Offenbach, which are a couple of kilometers away, they do not split
+// votes anymore.
+//
+// If nobody in the winning country knows coordinates, this merger
+// works exactly as NewMajorityMerger. If clusterRadius is not
+// positive, DefaultGeoClusterRadius is used.
+func NewGeoClusterMerger(clusterRadius float64) Merger {
+	if clusterRadius <= 0 {
+		clusterRadius = DefaultGeoClusterRadius
+	}
+
+	return geoClusterMerger{
+		radius: clusterRadius,
+	}
+}
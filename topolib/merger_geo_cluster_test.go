@@ -0,0 +1,193 @@
+package topolib_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/9seconds/topographer/topolib"
+	"github.com/stretchr/testify/suite"
+)
+
+type GeoClusterMergerTestSuite struct {
+	suite.Suite
+
+	m  topolib.Merger
+	ip net.IP
+}
+
+func (suite *GeoClusterMergerTestSuite) SetupTest() {
+	suite.m = topolib.NewGeoClusterMerger(20)
+	suite.ip = net.ParseIP("80.80.80.80")
+}
+
+func (suite *GeoClusterMergerTestSuite) TestEmpty() {
+	res := suite.m.Merge(suite.ip, nil)
+
+	suite.True(suite.ip.Equal(res.IP))
+	suite.Empty(res.Country.Alpha2Code)
+	suite.Empty(res.City)
+	suite.Nil(res.Location)
+}
+
+func (suite *GeoClusterMergerTestSuite) TestNearbyCities() {
+	res := suite.m.Merge(suite.ip, []topolib.ResolveResultDetail{
+		{
+			ProviderName: "p0",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Frankfurt am Main",
+			Region:       "Hesse",
+			Location: &topolib.Location{
+				Latitude:  50.1109,
+				Longitude: 8.6821,
+			},
+		},
+		{
+			ProviderName: "p1",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Offenbach",
+			Region:       "Hesse",
+			Location: &topolib.Location{
+				Latitude:  50.0956,
+				Longitude: 8.7761,
+			},
+		},
+		{
+			ProviderName: "p2",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Berlin",
+			Region:       "Berlin",
+			Location: &topolib.Location{
+				Latitude:  52.52,
+				Longitude: 13.405,
+			},
+		},
+		{
+			ProviderName: "p3",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Offenbach",
+		},
+	})
+
+	suite.Equal("DE", res.Country.Alpha2Code)
+	suite.Equal("Offenbach", res.City)
+	suite.Equal("Hesse", res.Region)
+	suite.InDelta(0.75, res.CityConfidence, 0.001)
+	suite.InDelta(50.1, res.Location.Latitude, 0.05)
+	suite.InDelta(8.73, res.Location.Longitude, 0.05)
+	suite.InDelta(3.5, res.Location.AccuracyRadius, 0.5)
+}
+
+func (suite *GeoClusterMergerTestSuite) TestOtherCountryIgnored() {
+	res := suite.m.Merge(suite.ip, []topolib.ResolveResultDetail{
+		{
+			ProviderName: "p0",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("NL"),
+			City:         "Amsterdam",
+			Location: &topolib.Location{
+				Latitude:  52.37,
+				Longitude: 4.89,
+			},
+		},
+		{
+			ProviderName: "p1",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("NL"),
+			City:         "Amstelveen",
+			Location: &topolib.Location{
+				Latitude:  52.30,
+				Longitude: 4.86,
+			},
+		},
+		{
+			ProviderName: "p2",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Berlin",
+			Location: &topolib.Location{
+				Latitude:  52.52,
+				Longitude: 13.405,
+			},
+		},
+	})
+
+	suite.Equal("NL", res.Country.Alpha2Code)
+	suite.Equal("Amstelveen", res.City)
+	suite.InDelta(52.335, res.Location.Latitude, 0.05)
+	suite.InDelta(4.875, res.Location.Longitude, 0.05)
+}
+
+func (suite *GeoClusterMergerTestSuite) TestWeights() {
+	res := suite.m.Merge(suite.ip, []topolib.ResolveResultDetail{
+		{
+			ProviderName: "p0",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Frankfurt am Main",
+			Location: &topolib.Location{
+				Latitude:  50.1109,
+				Longitude: 8.6821,
+			},
+		},
+		{
+			ProviderName: "p1",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Offenbach",
+			Location: &topolib.Location{
+				Latitude:  50.0956,
+				Longitude: 8.7761,
+			},
+		},
+		{
+			ProviderName: "p2",
+			VoteWeight:   3,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Berlin",
+			Location: &topolib.Location{
+				Latitude:  52.52,
+				Longitude: 13.405,
+			},
+		},
+	})
+
+	suite.Equal("Berlin", res.City)
+	suite.InDelta(52.52, res.Location.Latitude, 0.001)
+	suite.InDelta(13.405, res.Location.Longitude, 0.001)
+}
+
+func (suite *GeoClusterMergerTestSuite) TestNoLocations() {
+	details := []topolib.ResolveResultDetail{
+		{
+			ProviderName: "p0",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Offenbach",
+		},
+		{
+			ProviderName: "p1",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Frankfurt am Main",
+		},
+		{
+			ProviderName: "p2",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Frankfurt am Main",
+		},
+	}
+
+	res := suite.m.Merge(suite.ip, details)
+	expected := topolib.NewMajorityMerger().Merge(suite.ip, details)
+
+	suite.Equal(expected, res)
+}
+
+func TestGeoClusterMerger(t *testing.T) {
+	suite.Run(t, &GeoClusterMergerTestSuite{})
+}
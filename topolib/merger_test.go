@@ -0,0 +1,379 @@
+package topolib_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/9seconds/topographer/topolib"
+	"github.com/stretchr/testify/suite"
+)
+
+type MajorityMergerTestSuite struct {
+	suite.Suite
+
+	m  topolib.Merger
+	ip net.IP
+}
+
+func (suite *MajorityMergerTestSuite) SetupTest() {
+	suite.m = topolib.NewMajorityMerger()
+	suite.ip = net.ParseIP("80.80.80.80")
+}
+
+func (suite *MajorityMergerTestSuite) TestEmpty() {
+	res := suite.m.Merge(suite.ip, nil)
+
+	suite.True(suite.ip.Equal(res.IP))
+	suite.Empty(res.Country.Alpha2Code)
+	suite.Empty(res.City)
+	suite.False(res.OK())
+	suite.Zero(res.CountryConfidence)
+	suite.Zero(res.CityConfidence)
+	suite.Zero(res.Voters)
+	suite.Zero(res.Abstainers)
+}
+
+func (suite *MajorityMergerTestSuite) TestMajority() {
+	res := suite.m.Merge(suite.ip, []topolib.ResolveResultDetail{
+		{
+			ProviderName: "p0",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Berlin",
+		},
+		{
+			ProviderName: "p1",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("NL"),
+			City:         "Amsterdam",
+		},
+		{
+			ProviderName: "p2",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("NL"),
+			City:         "Rotterdam",
+		},
+		{
+			ProviderName: "p3",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("NL"),
+			City:         "Amsterdam",
+		},
+	})
+
+	suite.Equal("NL", res.Country.Alpha2Code)
+	suite.Equal("NLD", res.Country.Alpha3Code)
+	suite.Equal("Amsterdam", res.City)
+	suite.Len(res.Details, 4)
+	suite.InDelta(0.75, res.CountryConfidence, 0.001)
+	suite.InDelta(0.5, res.CityConfidence, 0.001)
+	suite.Equal(4, res.Voters)
+	suite.Equal(0, res.Abstainers)
+}
+
+func (suite *MajorityMergerTestSuite) TestCountryTie() {
+	details := []topolib.ResolveResultDetail{
+		{
+			ProviderName: "p0",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("NL"),
+			City:         "Amsterdam",
+		},
+		{
+			ProviderName: "p1",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Berlin",
+		},
+	}
+
+	for i := 0; i < 100; i++ {
+		res := suite.m.Merge(suite.ip, details)
+
+		suite.Equal("DE", res.Country.Alpha2Code)
+		suite.Equal("Berlin", res.City)
+	}
+}
+
+func (suite *MajorityMergerTestSuite) TestCityTie() {
+	details := []topolib.ResolveResultDetail{
+		{
+			ProviderName: "p0",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Offenbach",
+		},
+		{
+			ProviderName: "p1",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Frankfurt am Main",
+		},
+		{
+			ProviderName: "p2",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+		},
+	}
+
+	for i := 0; i < 100; i++ {
+		res := suite.m.Merge(suite.ip, details)
+
+		suite.Equal("DE", res.Country.Alpha2Code)
+		suite.Equal("Frankfurt am Main", res.City)
+	}
+}
+
+func (suite *MajorityMergerTestSuite) TestCitySpellings() {
+	res := suite.m.Merge(suite.ip, []topolib.ResolveResultDetail{
+		{
+			ProviderName: "p0",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("RU"),
+			City:         "Nizhniy Novgorod",
+		},
+		{
+			ProviderName: "p1",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("RU"),
+			City:         "Nizhny Novgorod",
+		},
+		{
+			ProviderName: "p2",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("RU"),
+			City:         "Moscow",
+		},
+	})
+
+	suite.Equal("Nizhniy Novgorod", res.City)
+}
+
+func (suite *MajorityMergerTestSuite) TestWeights() {
+	res := suite.m.Merge(suite.ip, []topolib.ResolveResultDetail{
+		{
+			ProviderName: "p0",
+			VoteWeight:   3,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Berlin",
+		},
+		{
+			ProviderName: "p1",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("NL"),
+			City:         "Amsterdam",
+		},
+		{
+			ProviderName: "p2",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("NL"),
+			City:         "Amsterdam",
+		},
+	})
+
+	suite.Equal("DE", res.Country.Alpha2Code)
+	suite.Equal("Berlin", res.City)
+	suite.InDelta(0.6, res.CountryConfidence, 0.001)
+	suite.InDelta(0.6, res.CityConfidence, 0.001)
+}
+
+func (suite *MajorityMergerTestSuite) TestAbstainers() {
+	res := suite.m.Merge(suite.ip, []topolib.ResolveResultDetail{
+		{
+			ProviderName: "p0",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Berlin",
+		},
+		{
+			ProviderName: "p1",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+		},
+		{
+			ProviderName: "p2",
+			VoteWeight:   1,
+		},
+	})
+
+	suite.Equal("DE", res.Country.Alpha2Code)
+	suite.Equal("Berlin", res.City)
+	suite.Equal(2, res.Voters)
+	suite.Equal(1, res.Abstainers)
+	suite.InDelta(1.0, res.CountryConfidence, 0.001)
+	suite.InDelta(1.0, res.CityConfidence, 0.001)
+}
+
+func (suite *MajorityMergerTestSuite) TestRegionAndLocation() {
+	res := suite.m.Merge(suite.ip, []topolib.ResolveResultDetail{
+		{
+			ProviderName: "p0",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Frankfurt am Main",
+			Region:       "Hesse",
+			Location: &topolib.Location{
+				Latitude:       50.1,
+				Longitude:      8.7,
+				AccuracyRadius: 10,
+			},
+		},
+		{
+			ProviderName: "p1",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Frankfurt am Main",
+			Region:       "Hessen",
+			Location: &topolib.Location{
+				Latitude:  50.1,
+				Longitude: 8.7,
+			},
+		},
+		{
+			ProviderName: "p2",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Berlin",
+			Region:       "Berlin",
+			Location: &topolib.Location{
+				Latitude:  52.5,
+				Longitude: 13.4,
+			},
+		},
+		{
+			ProviderName: "p3",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("NL"),
+			Region:       "North Holland",
+			Location: &topolib.Location{
+				Latitude:  52.4,
+				Longitude: 4.9,
+			},
+		},
+	})
+
+	suite.Equal("Frankfurt am Main", res.City)
+	suite.Equal("Hesse", res.Region)
+	suite.InDelta(50.1, res.Location.Latitude, 0.001)
+	suite.InDelta(8.7, res.Location.Longitude, 0.001)
+	suite.InDelta(10, res.Location.AccuracyRadius, 0.001)
+}
+
+func (suite *MajorityMergerTestSuite) TestLocationNoCity() {
+	res := suite.m.Merge(suite.ip, []topolib.ResolveResultDetail{
+		{
+			ProviderName: "p0",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			Location: &topolib.Location{
+				Latitude:  51,
+				Longitude: 9,
+			},
+		},
+		{
+			ProviderName: "p1",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+		},
+	})
+
+	suite.Empty(res.City)
+	suite.InDelta(51, res.Location.Latitude, 0.001)
+	suite.InDelta(9, res.Location.Longitude, 0.001)
+}
+
+func (suite *MajorityMergerTestSuite) TestNoLocation() {
+	res := suite.m.Merge(suite.ip, []topolib.ResolveResultDetail{
+		{
+			ProviderName: "p0",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Berlin",
+		},
+	})
+
+	suite.Nil(res.Location)
+}
+
+func (suite *MajorityMergerTestSuite) TestASN() {
+	res := suite.m.Merge(suite.ip, []topolib.ResolveResultDetail{
+		{
+			ProviderName: "p0",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("US"),
+			City:         "Mountain View",
+		},
+		{
+			ProviderName: "p1",
+			VoteWeight:   1,
+			ASN:          15169,
+			Organization: "GOOGLE",
+		},
+		{
+			ProviderName: "p2",
+			VoteWeight:   1,
+			ASN:          15169,
+			Organization: "Google LLC",
+		},
+		{
+			ProviderName: "p3",
+			VoteWeight:   1,
+			ASN:          36040,
+			Organization: "YOUTUBE",
+		},
+	})
+
+	suite.Equal("US", res.Country.Alpha2Code)
+	suite.EqualValues(15169, res.ASN)
+	suite.Equal("GOOGLE", res.Organization)
+	suite.Equal(1, res.Voters)
+	suite.Equal(3, res.Abstainers)
+}
+
+func (suite *MajorityMergerTestSuite) TestNoASN() {
+	res := suite.m.Merge(suite.ip, []topolib.ResolveResultDetail{
+		{
+			ProviderName: "p0",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("US"),
+		},
+	})
+
+	suite.Zero(res.ASN)
+	suite.Empty(res.Organization)
+}
+
+func (suite *MajorityMergerTestSuite) TestCapabilities() {
+	res := suite.m.Merge(suite.ip, []topolib.ResolveResultDetail{
+		{
+			ProviderName: "p0",
+			VoteWeight:   1,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Berlin",
+		},
+		{
+			ProviderName: "p1",
+			VoteWeight:   1,
+			Capabilities: topolib.CapabilityIPv4 | topolib.CapabilityASN,
+			ASN:          3320,
+		},
+		{
+			ProviderName: "p2",
+			VoteWeight:   1,
+			Capabilities: topolib.CapabilityIPv4 | topolib.CapabilityCountry,
+			CountryCode:  topolib.Alpha2ToCountryCode("DE"),
+			City:         "Munich",
+		},
+	})
+
+	suite.Equal("DE", res.Country.Alpha2Code)
+	suite.Equal("Berlin", res.City)
+	suite.Equal(2, res.Voters)
+	suite.Equal(0, res.Abstainers)
+	suite.InDelta(1.0, res.CityConfidence, 0.001)
+	suite.EqualValues(3320, res.ASN)
+}
+
+func TestMajorityMerger(t *testing.T) {
+	suite.Run(t, &MajorityMergerTestSuite{})
+}
@@ -0,0 +1,289 @@
+package topolib
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metricsDefaultBuckets are upper bounds of histogram buckets in
+// seconds. These are the same buckets Prometheus client libraries use
+// by default.
+var metricsDefaultBuckets = []float64{
+	0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10,
+}
+
+// metricsHistogram is a cumulative histogram of durations. A zero value
+// is ready to use.
+type metricsHistogram struct {
+	mutex  sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (m *metricsHistogram) observe(value time.Duration) {
+	seconds := value.Seconds()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.counts == nil {
+		m.counts = make([]uint64, len(metricsDefaultBuckets))
+	}
+
+	for i, v := range metricsDefaultBuckets {
+		if seconds <= v {
+			m.counts[i]++
+		}
+	}
+
+	m.sum += seconds
+	m.count++
+}
+
+func (m *metricsHistogram) snapshot() metricsHistogramSnapshot {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	rv := metricsHistogramSnapshot{
+		counts: make([]uint64, len(metricsDefaultBuckets)),
+		sum:    m.sum,
+		count:  m.count,
+	}
+
+	copy(rv.counts, m.counts)
+
+	return rv
+}
+
+type metricsHistogramSnapshot struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// metricsLabel is a pair of label name and its value.
+type metricsLabel struct {
+	name  string
+	value string
+}
+
+// metricsWriter writes metrics in Prometheus text exposition format.
+// The first error stops writing and is returned by flush.
+type metricsWriter struct {
+	w *bufio.Writer
+}
+
+func (m metricsWriter) family(name, metricType, help string) {
+	m.w.WriteString("# HELP " + name + " " + help + "\n")       // nolint: errcheck
+	m.w.WriteString("# TYPE " + name + " " + metricType + "\n") // nolint: errcheck
+}
+
+func (m metricsWriter) sample(name string, value float64, labels ...metricsLabel) {
+	m.w.WriteString(name) // nolint: errcheck
+	m.writeLabels(labels)
+	m.w.WriteString(" " + metricsFormatFloat(value) + "\n") // nolint: errcheck
+}
+
+func (m metricsWriter) histogram(name string, value metricsHistogramSnapshot, labels ...metricsLabel) {
+	bucketLabels := append(append([]metricsLabel{}, labels...), metricsLabel{name: "le"})
+
+	for i, v := range metricsDefaultBuckets {
+		bucketLabels[len(bucketLabels)-1].value = metricsFormatFloat(v)
+		m.sample(name+"_bucket", float64(value.counts[i]), bucketLabels...)
+	}
+
+	bucketLabels[len(bucketLabels)-1].value = "+Inf"
+	m.sample(name+"_bucket", float64(value.count), bucketLabels...)
+	m.sample(name+"_sum", value.sum, labels...)
+	m.sample(name+"_count", float64(value.count), labels...)
+}
+
+func (m metricsWriter) writeLabels(labels []metricsLabel) {
+	if len(labels) == 0 {
+		return
+	}
+
+	chunks := make([]string, len(labels))
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+	for i, v := range labels {
+		chunks[i] = v.name + `="` + replacer.Replace(v.value) + `"`
+	}
+
+	m.w.WriteString("{" + strings.Join(chunks, ",") + "}") // nolint: errcheck
+}
+
+func (m metricsWriter) flush() error {
+	return m.w.Flush()
+}
+
+func metricsFormatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// WriteMetrics writes metrics of Topographer in Prometheus text
+// exposition format. These metrics are also available on /metrics
+// endpoint of the HTTP handler.
+//
+// Metrics include lookup counters and latencies of each provider, age
+// and update results of offline databases, state of circuit breakers
+// and rate limiters (if HTTP clients were set with
+// WithProviderHTTPClient), utilization of the worker pool and HTTP
+// requests served by Topographer.
+func (t *Topographer) WriteMetrics(w io.Writer) error {
+	// metrics are rendered into memory so slow reader does not hold
+	// a lock.
+	buf := &bytes.Buffer{}
+
+	if err := t.writeMetrics(buf); err != nil {
+		return err
+	}
+
+	_, err := buf.WriteTo(w)
+
+	return err
+}
+
+func (t *Topographer) writeMetrics(w io.Writer) error {
+	t.rwmutex.RLock()
+	defer t.rwmutex.RUnlock()
+
+	writer := metricsWriter{w: bufio.NewWriter(w)}
+	stats := t.usageStats()
+	now := time.Now()
+
+	writer.family("topographer_provider_lookups_total", "counter",
+		"A number of lookups made by provider.")
+
+	for _, v := range stats {
+		successes, failures := v.lookupCounts()
+		label := metricsLabel{name: "provider", value: v.Name}
+
+		writer.sample("topographer_provider_lookups_total", float64(successes),
+			label, metricsLabel{name: "outcome", value: "success"})
+		writer.sample("topographer_provider_lookups_total", float64(failures),
+			label, metricsLabel{name: "outcome", value: "failure"})
+	}
+
+	writer.family("topographer_provider_lookup_duration_seconds", "histogram",
+		"A time spent on lookups by provider.")
+
+	for _, v := range stats {
+		writer.histogram("topographer_provider_lookup_duration_seconds",
+			v.lookupDuration.snapshot(),
+			metricsLabel{name: "provider", value: v.Name})
+	}
+
+	writer.family("topographer_database_age_seconds", "gauge",
+		"Time since the database of offline provider was updated.")
+
+	for _, v := range stats {
+		if _, ok := t.providers[v.Name].(OfflineProvider); !ok {
+			continue
+		}
+
+		if lastUpdated := v.lastUpdatedTime(); !lastUpdated.IsZero() {
+			writer.sample("topographer_database_age_seconds",
+				now.Sub(lastUpdated).Seconds(),
+				metricsLabel{name: "provider", value: v.Name})
+		}
+	}
+
+	writer.family("topographer_database_updates_total", "counter",
+		"A number of database updates of offline provider.")
+
+	for _, v := range stats {
+		if _, ok := t.providers[v.Name].(OfflineProvider); !ok {
+			continue
+		}
+
+		successes, failures := v.updateCounts()
+		label := metricsLabel{name: "provider", value: v.Name}
+
+		writer.sample("topographer_database_updates_total", float64(successes),
+			label, metricsLabel{name: "outcome", value: "success"})
+		writer.sample("topographer_database_updates_total", float64(failures),
+			label, metricsLabel{name: "outcome", value: "failure"})
+	}
+
+	t.writeHTTPClientMetrics(writer)
+
+	capacity := t.workerPool.Cap()
+	running := t.workerPool.Running()
+
+	writer.family("topographer_worker_pool_capacity", "gauge",
+		"A size of the worker pool.")
+	writer.sample("topographer_worker_pool_capacity", float64(capacity))
+	writer.family("topographer_worker_pool_running", "gauge",
+		"A number of workers which are busy with resolving.")
+	writer.sample("topographer_worker_pool_running", float64(running))
+	writer.family("topographer_worker_pool_utilization_ratio", "gauge",
+		"A share of busy workers in the worker pool.")
+
+	if capacity > 0 {
+		writer.sample("topographer_worker_pool_utilization_ratio",
+			float64(running)/float64(capacity))
+	}
+
+	t.httpStats.writeMetrics(writer)
+
+	return writer.flush()
+}
+
+func (t *Topographer) writeHTTPClientMetrics(writer metricsWriter) {
+	names := make([]string, 0, len(t.httpClients))
+
+	for k, v := range t.httpClients {
+		if _, ok := v.(httpClient); ok {
+			names = append(names, k)
+		}
+	}
+
+	sort.Strings(names)
+
+	writer.family("topographer_circuit_breaker_state", "gauge",
+		"A state of circuit breaker of provider: 0 is closed, 1 is half-opened, 2 is opened.")
+
+	for _, v := range names {
+		writer.sample("topographer_circuit_breaker_state",
+			float64(t.httpClients[v].(httpClient).circuitBreaker.getState()),
+			metricsLabel{name: "provider", value: v})
+	}
+
+	writer.family("topographer_rate_limiter_waits_total", "counter",
+		"A number of times when request of provider was delayed by rate limiter.")
+
+	for _, v := range names {
+		waits, _ := t.httpClients[v].(httpClient).stats.rateLimiterWaits()
+
+		writer.sample("topographer_rate_limiter_waits_total", float64(waits),
+			metricsLabel{name: "provider", value: v})
+	}
+
+	writer.family("topographer_rate_limiter_wait_seconds_total", "counter",
+		"A time requests of provider were delayed by rate limiter.")
+
+	for _, v := range names {
+		_, waitTime := t.httpClients[v].(httpClient).stats.rateLimiterWaits()
+
+		writer.sample("topographer_rate_limiter_wait_seconds_total", waitTime.Seconds(),
+			metricsLabel{name: "provider", value: v})
+	}
+}
@@ -0,0 +1,78 @@
+package topolib
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type MetricsTestSuite struct {
+	suite.Suite
+
+	buf    *bytes.Buffer
+	writer metricsWriter
+}
+
+func (suite *MetricsTestSuite) SetupTest() {
+	suite.buf = &bytes.Buffer{}
+	suite.writer = metricsWriter{w: bufio.NewWriter(suite.buf)}
+}
+
+func (suite *MetricsTestSuite) TestFormatFloat() {
+	suite.Equal("+Inf", metricsFormatFloat(math.Inf(1)))
+	suite.Equal("-Inf", metricsFormatFloat(math.Inf(-1)))
+	suite.Equal("0.025", metricsFormatFloat(0.025))
+	suite.Equal("10", metricsFormatFloat(10))
+}
+
+func (suite *MetricsTestSuite) TestSample() {
+	suite.writer.family("test_total", "counter", "Test counter.")
+	suite.writer.sample("test_total", 1)
+	suite.writer.sample("test_total", 2, metricsLabel{name: "a", value: `x"y\z` + "\n"},
+		metricsLabel{name: "b", value: "c"})
+
+	suite.NoError(suite.writer.flush())
+	suite.Equal(`# HELP test_total Test counter.
+# TYPE test_total counter
+test_total 1
+test_total{a="x\"y\\z\n",b="c"} 2
+`, suite.buf.String())
+}
+
+func (suite *MetricsTestSuite) TestHistogram() {
+	histogram := &metricsHistogram{}
+
+	histogram.observe(3 * time.Millisecond)
+	histogram.observe(200 * time.Millisecond)
+	histogram.observe(time.Minute)
+
+	suite.writer.histogram("test_seconds", histogram.snapshot(),
+		metricsLabel{name: "a", value: "b"})
+	suite.NoError(suite.writer.flush())
+
+	output := suite.buf.String()
+
+	suite.Contains(output, `test_seconds_bucket{a="b",le="0.005"} 1`+"\n")
+	suite.Contains(output, `test_seconds_bucket{a="b",le="0.1"} 1`+"\n")
+	suite.Contains(output, `test_seconds_bucket{a="b",le="0.25"} 2`+"\n")
+	suite.Contains(output, `test_seconds_bucket{a="b",le="10"} 2`+"\n")
+	suite.Contains(output, `test_seconds_bucket{a="b",le="+Inf"} 3`+"\n")
+	suite.Contains(output, `test_seconds_sum{a="b"} 60.203`+"\n")
+	suite.Contains(output, `test_seconds_count{a="b"} 3`+"\n")
+}
+
+func (suite *MetricsTestSuite) TestEmptyHistogram() {
+	suite.writer.histogram("test_seconds", (&metricsHistogram{}).snapshot())
+	suite.NoError(suite.writer.flush())
+
+	suite.Contains(suite.buf.String(), `test_seconds_bucket{le="+Inf"} 0`+"\n")
+	suite.Contains(suite.buf.String(), "test_seconds_count 0\n")
+}
+
+func TestMetrics(t *testing.T) {
+	suite.Run(t, &MetricsTestSuite{})
+}
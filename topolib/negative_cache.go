@@ -0,0 +1,74 @@
+package topolib
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+)
+
+// negativeCache remembers errors of providers which mean that provider
+// knows nothing about the IP (like ErrIPNotFound). These errors are
+// not going to change until provider updates its data, so there is no
+// need to ask provider again for some time. It is invalidated in the
+// same way as resultCache.
+type negativeCache struct {
+	// generation is accessed atomically so it goes first to be aligned
+	// on 32-bit platforms.
+	generation uint64
+
+	cache *ristretto.Cache
+	ttl   time.Duration
+}
+
+// get returns a cached error of the provider or nil if nothing is
+// cached.
+func (n *negativeCache) get(ip net.IP, name string) error {
+	if value, ok := n.cache.Get(n.makeKey(ip, name)); ok {
+		return value.(error)
+	}
+
+	return nil
+}
+
+func (n *negativeCache) set(ip net.IP, name string, err error) {
+	if isNegativeCacheable(err) {
+		n.cache.SetWithTTL(n.makeKey(ip, name), err, 1, n.ttl)
+	}
+}
+
+func (n *negativeCache) invalidate() {
+	atomic.AddUint64(&n.generation, 1)
+}
+
+func (n *negativeCache) makeKey(ip net.IP, name string) string {
+	return strconv.FormatUint(atomic.LoadUint64(&n.generation), 10) +
+		"|" + ip.String() +
+		"|" + name
+}
+
+func newNegativeCache(itemsCount uint, ttl time.Duration) *negativeCache {
+	return &negativeCache{
+		cache: newRistrettoCache(itemsCount),
+		ttl:   ttl,
+	}
+}
+
+// isNegativeCacheable checks if provider error means that provider has
+// no information about the IP.
+func isNegativeCacheable(err error) bool {
+	var statusErr *HTTPStatusError
+
+	switch {
+	case errors.Is(err, ErrIPNotFound):
+		return true
+	case errors.As(err, &statusErr):
+		return statusErr.StatusCode == http.StatusNotFound
+	}
+
+	return false
+}
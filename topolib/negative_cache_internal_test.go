@@ -0,0 +1,60 @@
+package topolib
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type NegativeCacheTestSuite struct {
+	suite.Suite
+
+	c *negativeCache
+}
+
+func (suite *NegativeCacheTestSuite) SetupTest() {
+	suite.c = newNegativeCache(100, time.Minute)
+}
+
+func (suite *NegativeCacheTestSuite) TestIsNegativeCacheable() {
+	testData := map[error]bool{
+		ErrIPNotFound:                                            true,
+		fmt.Errorf("wrapped: %w", ErrIPNotFound):                 true,
+		&HTTPStatusError{StatusCode: http.StatusNotFound}:        true,
+		&HTTPStatusError{StatusCode: http.StatusTooManyRequests}: false,
+		ErrDatabaseIsNotReadyYet:                                 false,
+		ErrCircuitBreakerOpened:                                  false,
+		errors.New("error"):                                      false,
+	}
+
+	for k, v := range testData {
+		suite.Equal(v, isNegativeCacheable(k), k.Error())
+	}
+}
+
+func (suite *NegativeCacheTestSuite) TestGetSet() {
+	ip := net.ParseIP("80.80.80.80")
+
+	suite.c.set(ip, "p0", ErrDatabaseIsNotReadyYet)
+	suite.c.set(ip, "p1", ErrIPNotFound)
+
+	// ristretto is eventually consistent
+	time.Sleep(100 * time.Millisecond)
+
+	suite.NoError(suite.c.get(ip, "p0"))
+	suite.Equal(ErrIPNotFound, suite.c.get(ip, "p1"))
+	suite.NoError(suite.c.get(net.ParseIP("80.80.80.81"), "p1"))
+
+	suite.c.invalidate()
+
+	suite.NoError(suite.c.get(ip, "p1"))
+}
+
+func TestNegativeCache(t *testing.T) {
+	suite.Run(t, &NegativeCacheTestSuite{})
+}
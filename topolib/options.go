@@ -0,0 +1,230 @@
+package topolib
+
+import (
+	"net"
+	"time"
+)
+
+// Option is a functional option for NewTopographer. Options are applied
+// in the order they were given.
+type Option func(*Topographer)
+
+// WithMerger sets a custom merger which makes a consolidated verdict
+// out of provider results. By default, Topographer uses a merger
+// returned by NewMajorityMerger.
+func WithMerger(merger Merger) Option {
+	return func(t *Topographer) {
+		t.merger = merger
+	}
+}
+
+// WithVoteWeight sets a weight of votes for the provider with a given
+// name. Votes of providers are summed by their weights so if you trust
+// some provider more than others, give it a bigger weight. A default
+// weight is DefaultVoteWeight.
+func WithVoteWeight(name string, weight float64) Option {
+	return func(t *Topographer) {
+		t.voteWeights[name] = weight
+	}
+}
+
+// WithTrustedProxies sets a list of networks of reverse proxies which
+// are trusted to report a client address. If HTTP handler of
+// Topographer is accessed by such proxy, a client address is taken
+// from a given header. header is one of Forwarded, X-Forwarded-For or
+// X-Real-IP: this has to be the header which proxies write, other
+// headers are ignored because they could be set by the client. By
+// default, no proxies are trusted and the address of the peer is used.
+func WithTrustedProxies(header string, networks ...*net.IPNet) Option {
+	return func(t *Topographer) {
+		t.trustedProxyHeader = header
+		t.trustedProxies = append(t.trustedProxies, networks...)
+	}
+}
+
+// WithProviderHTTPClient tells Topographer which HTTP client is used
+// by the provider with a given name. If this client was created by
+// NewHTTPClient, a state of its circuit breaker and rate limiter is
+// exposed in metrics (see Topographer.WriteMetrics).
+func WithProviderHTTPClient(name string, client HTTPClient) Option {
+	return func(t *Topographer) {
+		t.httpClients[name] = client
+	}
+}
+
+// WithReadiness sets when Topographer is ready (see
+// Topographer.Readiness): at least minProviders providers have to be
+// ready and each of required providers has to be ready. If you want
+// to rely only on a list of required providers, set minProviders to 0.
+// By default, minProviders is DefaultReadyMinProviders and no providers
+// are required.
+func WithReadiness(minProviders int, required ...string) Option {
+	return func(t *Topographer) {
+		t.readyMinProviders = minProviders
+
+		for _, v := range required {
+			t.readyRequiredProviders[v] = struct{}{}
+		}
+	}
+}
+
+// WithResultCache enables a cache of final verdicts of Topographer.
+// Cache keeps up to itemsCount results for ttl. Results are cached
+// only if they have some country and none of providers has timed out
+// (see WithLookupDeadline, WithProviderTimeout and WithEarlyVerdict);
+// they are also keyed by a set of used providers.
+//
+// ipv4PrefixLength and ipv6PrefixLength enable prefix aggregation: all
+// addresses of the same prefix share the same cache entry (for example,
+// 24 and 48 cache by /24 for IPv4 and /48 for IPv6). Please remember
+// that this trades accuracy for a hit rate: providers can have
+// different data for addresses of the same prefix. If prefix length is
+// 0, the whole address is used.
+//
+// Cache is invalidated each time when some offline provider reloads its
+// database. If itemsCount is 0, cache is disabled.
+func WithResultCache(itemsCount uint,
+	ttl time.Duration,
+	ipv4PrefixLength, ipv6PrefixLength int) Option {
+	return func(t *Topographer) {
+		if itemsCount == 0 {
+			t.resultCache = nil
+
+			return
+		}
+
+		t.resultCache = newResultCache(itemsCount, ttl,
+			ipv4PrefixLength, ipv6PrefixLength)
+	}
+}
+
+// WithNegativeCache enables a cache of provider errors which mean that
+// provider has no information about the IP (like ErrIPNotFound or 404
+// responses of online services). Such errors are cached for ttl, up
+// to itemsCount of them. Please use short TTLs here: this cache is
+// to protect quotas of online services from repeated lookups, not to
+// hide problems. Like result cache, it is invalidated when some offline
+// provider reloads its database. If itemsCount is 0, cache is
+// disabled.
+func WithNegativeCache(itemsCount uint, ttl time.Duration) Option {
+	return func(t *Topographer) {
+		if itemsCount == 0 {
+			t.negativeCache = nil
+
+			return
+		}
+
+		t.negativeCache = newNegativeCache(itemsCount, ttl)
+	}
+}
+
+// WithLookupDeadline sets an overall deadline of resolving a single IP.
+// If some providers have not answered before the deadline, a verdict
+// is made without them and they are marked as timed out in details.
+// By default, Topographer waits for all providers.
+func WithLookupDeadline(deadline time.Duration) Option {
+	return func(t *Topographer) {
+		t.lookupDeadline = deadline
+	}
+}
+
+// WithProviderTimeout sets a soft timeout of the provider with a given
+// name. If provider has not answered in time, Topographer does not
+// wait for it and makes a verdict without it; a context of its lookup
+// is cancelled. Such lookups are counted in UsageStats as failures of
+// timeout class, even if provider answers later. If timeout is 0, soft
+// timeout is removed.
+func WithProviderTimeout(name string, timeout time.Duration) Option {
+	return func(t *Topographer) {
+		if timeout <= 0 {
+			delete(t.providerTimeouts, name)
+		} else {
+			t.providerTimeouts[name] = timeout
+		}
+	}
+}
+
+// WithEarlyVerdict enables early verdicts: Topographer stops waiting
+// for providers once quorum of them agree on a country or once all
+// offline providers have answered. Providers which have not answered
+// yet are marked as timed out in details and counted in UsageStats as
+// failures of timeout class. If quorum is 0, only offline providers
+// are taken into account.
+//
+// This is useful if you have slow online providers: usually offline
+// databases are enough to make a verdict.
+func WithEarlyVerdict(quorum int) Option {
+	return func(t *Topographer) {
+		t.earlyVerdict = true
+		t.earlyVerdictQuorum = quorum
+	}
+}
+
+// WithProviderTier sets a tier of the provider with a given name.
+// Providers are asked tier by tier in ascending order: if a verdict
+// made by providers of previous tiers is confident enough (see
+// WithTierConfidence), the next tiers are not asked at all. For
+// example, you can put offline databases into tier 0 and paid online
+// services into tier 1, so online services are asked only if offline
+// databases disagree or know nothing about the IP. By default, all
+// providers are in tier 0.
+func WithProviderTier(name string, tier int) Option {
+	return func(t *Topographer) {
+		t.providerTiers[name] = tier
+	}
+}
+
+// WithTierConfidence sets a country confidence of the verdict (see
+// ResolveResult.CountryConfidence) which is enough to skip the next
+// tiers of providers. A default value is DefaultTierConfidence.
+func WithTierConfidence(confidence float64) Option {
+	return func(t *Topographer) {
+		t.tierConfidence = confidence
+	}
+}
+
+// ProviderOption is a functional option for Topographer.AddProvider
+// and Topographer.ReplaceProvider. Unlike Option, it sets up only the
+// provider which is being added, so it has no name argument.
+type ProviderOption func(*providerSettings)
+
+// providerSettings are settings of a single provider which can be set
+// with ProviderOption.
+type providerSettings struct {
+	voteWeight float64
+	httpClient HTTPClient
+	timeout    time.Duration
+	tier       int
+}
+
+// ProviderVoteWeight is the same as WithVoteWeight but for AddProvider
+// and ReplaceProvider.
+func ProviderVoteWeight(weight float64) ProviderOption {
+	return func(s *providerSettings) {
+		s.voteWeight = weight
+	}
+}
+
+// ProviderHTTPClient is the same as WithProviderHTTPClient but for
+// AddProvider and ReplaceProvider.
+func ProviderHTTPClient(client HTTPClient) ProviderOption {
+	return func(s *providerSettings) {
+		s.httpClient = client
+	}
+}
+
+// ProviderTimeout is the same as WithProviderTimeout but for
+// AddProvider and ReplaceProvider.
+func ProviderTimeout(timeout time.Duration) ProviderOption {
+	return func(s *providerSettings) {
+		s.timeout = timeout
+	}
+}
+
+// ProviderTier is the same as WithProviderTier but for AddProvider and
+// ReplaceProvider.
+func ProviderTier(tier int) ProviderOption {
+	return func(s *providerSettings) {
+		s.tier = tier
+	}
+}
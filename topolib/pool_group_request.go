@@ -15,6 +15,7 @@ type resolveIPRequest struct {
 	providers     []Provider
 	resultChannel chan<- ResolveResult
 	wg            *sync.WaitGroup
+	exactCacheKey bool
 }
 
 type poolGroupRequest struct {
@@ -24,6 +25,10 @@ type poolGroupRequest struct {
 	providers     []Provider
 	wg            *sync.WaitGroup
 	pool          *ants.PoolWithFunc
+
+	// exactCacheKey disables prefix aggregation of result cache keys
+	// (see WithResultCache) for this group.
+	exactCacheKey bool
 }
 
 func (p *poolGroupRequest) Do(ctx context.Context, ip net.IP) error {
@@ -43,6 +48,7 @@ func (p *poolGroupRequest) Do(ctx context.Context, ip net.IP) error {
 		providers:     p.providers,
 		resultChannel: p.resultChannel,
 		wg:            p.wg,
+		exactCacheKey: p.exactCacheKey,
 	}
 
 	if err := p.pool.Invoke(req); err != nil {
@@ -0,0 +1,283 @@
+package topolib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+)
+
+const (
+	// MinPrefixLengthIPv4 is the shortest IPv4 prefix which is accepted
+	// by Topographer.ResolvePrefix.
+	MinPrefixLengthIPv4 = 16
+
+	// MinPrefixLengthIPv6 is the shortest IPv6 prefix which is accepted
+	// by Topographer.ResolvePrefix.
+	MinPrefixLengthIPv6 = 32
+
+	// MaxPrefixSubnets is the maximal number of subnets
+	// Topographer.ResolvePrefix can split a prefix into. Each subnet is
+	// resolved separately so this number limits an amount of work done
+	// for a single prefix.
+	MaxPrefixSubnets = 4096
+)
+
+// PrefixResolveResult is a result of resolving of a subnet of the
+// prefix. Resolve result is made for the first address of the subnet,
+// all other addresses are resolved in the same way.
+type PrefixResolveResult struct {
+	ResolveResult
+
+	Prefix *net.IPNet `json:"-"`
+}
+
+// ResolvePrefix resolves geolocation of the whole prefix (like
+// 1.2.3.0/24).
+//
+// Providers which implement PrefixProvider enumerate networks of their
+// databases which overlap with a prefix. These networks are used to
+// split the prefix into subnets: each subnet is uniform for every
+// provider, so it is enough to resolve its first address. Special
+// subnets (private, reserved etc) are not resolved by providers, the
+// same way as Resolve does. Providers which do not implement
+// PrefixProvider (usually online ones) cannot tell where their networks
+// end, so they are not used here.
+//
+// Subnets are returned in ascending order and cover the whole prefix.
+// If prefix is shorter than MinPrefixLengthIPv4 or
+// MinPrefixLengthIPv6 or it is split into more than MaxPrefixSubnets
+// subnets, ErrPrefixTooLarge is returned.
+//
+// 'providers' argument contains names of the providers to use. If you
+// want to use all providers, simply pass nil here.
+func (t *Topographer) ResolvePrefix(ctx context.Context,
+	prefix *net.IPNet,
+	providers []string) ([]PrefixResolveResult, error) {
+	providersToUse, err := t.selectProviders(providers)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix = normalizePrefix(prefix)
+	if prefix == nil {
+		return nil, ErrPrefixIncorrect
+	}
+
+	minLength := MinPrefixLengthIPv6
+	if len(prefix.IP) == net.IPv4len {
+		minLength = MinPrefixLengthIPv4
+	}
+
+	if ones, _ := prefix.Mask.Size(); ones < minLength {
+		return nil, fmt.Errorf("prefix %v is shorter than /%d: %w",
+			prefix, minLength, ErrPrefixTooLarge)
+	}
+
+	networks := make([]*net.IPNet, 0, len(addressTypeNetworks))
+	prefixProviders := make([]Provider, 0, len(providersToUse))
+
+	for _, v := range addressTypeNetworks {
+		networks = append(networks, v.network)
+	}
+
+	lookups, release := t.acquireProviders(t.getCapableProviders(prefix.IP, providersToUse))
+	defer release()
+
+	for _, lookup := range lookups {
+		v := lookup.provider
+
+		prefixProvider, ok := getPrefixProvider(v)
+		if !ok {
+			continue
+		}
+
+		providerNetworks, err := prefixProvider.Networks(ctx, prefix)
+
+		switch {
+		case errors.Is(err, ErrPrefixTooLarge):
+			return nil, err
+		case err != nil:
+			t.logger.LookupError(prefix.IP, v.Name(), err)
+
+			continue
+		}
+
+		networks = append(networks, providerNetworks...)
+		prefixProviders = append(prefixProviders, v)
+	}
+
+	subnets, err := splitPrefix(prefix, networks)
+	if err != nil {
+		return nil, err
+	}
+
+	ips := make([]net.IP, len(subnets))
+
+	for i, v := range subnets {
+		ips[i] = v.IP
+	}
+
+	// subnets are usually narrower than prefixes used by result
+	// cache aggregation so results are cached for exact addresses.
+	// Otherwise, a verdict for one subnet is taken for its neighbours.
+	results := t.resolveAll(ctx, ips, prefixProviders, true)
+
+	if ctx.Err() != nil || len(results) != len(subnets) {
+		return nil, ErrContextIsClosed
+	}
+
+	rv := make([]PrefixResolveResult, len(subnets))
+
+	for i := range results {
+		rv[i] = PrefixResolveResult{
+			ResolveResult: results[i],
+			Prefix:        subnets[i],
+		}
+	}
+
+	return rv, nil
+}
+
+// getPrefixProvider returns PrefixProvider which is hidden behind
+// Topographer wrappers like background updater or cache.
+func getPrefixProvider(provider Provider) (PrefixProvider, bool) {
+	switch value := provider.(type) {
+	case PrefixProvider:
+		return value, true
+	case *fsUpdater:
+		return getPrefixProvider(value.OfflineProvider)
+	case cachingOfflineProvider:
+		return getPrefixProvider(value.OfflineProvider)
+	case cachingProvider:
+		return getPrefixProvider(value.Provider)
+	}
+
+	return nil, false
+}
+
+// normalizePrefix returns a canonical form of the network: IPv4
+// networks (including IPv4-mapped IPv6 ones) have 4 byte IP and 32 bit
+// mask, IPv6 networks have 16 byte IP and 128 bit mask. Host bits are
+// zeroed. It returns nil if network is incorrect.
+func normalizePrefix(network *net.IPNet) *net.IPNet {
+	if network == nil {
+		return nil
+	}
+
+	ones, bits := network.Mask.Size()
+	ip := network.IP
+
+	switch ip4 := ip.To4(); {
+	case bits == 8*net.IPv4len && ip4 != nil:
+		ip = ip4
+	case bits == 8*net.IPv6len && ip4 != nil && ones >= 96:
+		ip = ip4
+		ones -= 96
+		bits = 8 * net.IPv4len
+	case bits == 8*net.IPv6len && len(ip) == net.IPv6len:
+	default:
+		return nil
+	}
+
+	mask := net.CIDRMask(ones, bits)
+
+	return &net.IPNet{
+		IP:   ip.Mask(mask),
+		Mask: mask,
+	}
+}
+
+// splitPrefix splits a prefix into the minimal list of CIDR subnets so
+// none of them crosses a boundary of any given network. Networks of
+// other address family are ignored.
+func splitPrefix(prefix *net.IPNet, networks []*net.IPNet) ([]*net.IPNet, error) {
+	ones, bits := prefix.Mask.Size()
+	start := new(big.Int).SetBytes(prefix.IP)
+	end := prefixLastAddress(start, bits-ones)
+	cuts := map[string]*big.Int{
+		start.String(): start,
+	}
+
+	for _, v := range networks {
+		network := normalizePrefix(v)
+		if network == nil || len(network.IP) != len(prefix.IP) {
+			continue
+		}
+
+		networkOnes, _ := network.Mask.Size()
+		networkStart := new(big.Int).SetBytes(network.IP)
+		networkEnd := prefixLastAddress(networkStart, bits-networkOnes)
+
+		if networkEnd.Cmp(start) < 0 || networkStart.Cmp(end) > 0 {
+			continue
+		}
+
+		if networkStart.Cmp(start) > 0 {
+			cuts[networkStart.String()] = networkStart
+		}
+
+		if networkEnd.Cmp(end) < 0 {
+			next := new(big.Int).Add(networkEnd, big.NewInt(1))
+			cuts[next.String()] = next
+		}
+
+		if len(cuts) > MaxPrefixSubnets {
+			return nil, fmt.Errorf("prefix %v has more than %d subnets: %w",
+				prefix, MaxPrefixSubnets, ErrPrefixTooLarge)
+		}
+	}
+
+	points := make([]*big.Int, 0, len(cuts)+1)
+
+	for _, v := range cuts {
+		points = append(points, v)
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Cmp(points[j]) < 0
+	})
+
+	points = append(points, new(big.Int).Add(end, big.NewInt(1)))
+	rv := []*net.IPNet{}
+	one := big.NewInt(1)
+
+	for i := 0; i < len(points)-1; i++ {
+		current := new(big.Int).Set(points[i])
+
+		for current.Cmp(points[i+1]) < 0 {
+			hostBits := bits - ones
+			if current.Sign() != 0 && int(current.TrailingZeroBits()) < hostBits {
+				hostBits = int(current.TrailingZeroBits())
+			}
+
+			for prefixLastAddress(current, hostBits).Cmp(points[i+1]) >= 0 {
+				hostBits--
+			}
+
+			if len(rv) == MaxPrefixSubnets {
+				return nil, fmt.Errorf("prefix %v has more than %d subnets: %w",
+					prefix, MaxPrefixSubnets, ErrPrefixTooLarge)
+			}
+
+			rv = append(rv, &net.IPNet{
+				IP:   current.FillBytes(make([]byte, len(prefix.IP))),
+				Mask: net.CIDRMask(bits-hostBits, bits),
+			})
+
+			current.Add(current, new(big.Int).Lsh(one, uint(hostBits)))
+		}
+	}
+
+	return rv, nil
+}
+
+// prefixLastAddress returns the last address of the subnet which
+// starts with a given address and has hostBits bits for hosts.
+func prefixLastAddress(start *big.Int, hostBits int) *big.Int {
+	size := new(big.Int).Lsh(big.NewInt(1), uint(hostBits))
+
+	return size.Add(size, start).Sub(size, big.NewInt(1))
+}
@@ -0,0 +1,124 @@
+package topolib
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PrefixTestSuite struct {
+	suite.Suite
+}
+
+func (suite *PrefixTestSuite) parseCIDR(value string) *net.IPNet {
+	_, rv, err := net.ParseCIDR(value)
+	if err != nil {
+		panic(err)
+	}
+
+	return rv
+}
+
+func (suite *PrefixTestSuite) split(prefix string, networks ...string) []string {
+	nets := make([]*net.IPNet, len(networks))
+
+	for i, v := range networks {
+		nets[i] = suite.parseCIDR(v)
+	}
+
+	subnets, err := splitPrefix(normalizePrefix(suite.parseCIDR(prefix)), nets)
+	suite.NoError(err)
+
+	rv := make([]string, len(subnets))
+
+	for i, v := range subnets {
+		rv[i] = v.String()
+	}
+
+	return rv
+}
+
+func (suite *PrefixTestSuite) TestNormalize() {
+	testData := map[string]string{
+		"1.2.3.4/24":           "1.2.3.0/24",
+		"::ffff:1.2.3.4/120":   "1.2.3.0/24",
+		"2a00:1450:4001::1/48": "2a00:1450:4001::/48",
+	}
+
+	for k, v := range testData {
+		value := k
+		expected := v
+
+		suite.T().Run(value, func(t *testing.T) {
+			network := normalizePrefix(suite.parseCIDR(value))
+
+			suite.Equal(expected, network.String())
+		})
+	}
+}
+
+func (suite *PrefixTestSuite) TestNormalizeIncorrect() {
+	suite.Nil(normalizePrefix(nil))
+	suite.Nil(normalizePrefix(&net.IPNet{
+		IP:   net.ParseIP("1.2.3.4"),
+		Mask: net.IPMask{255, 0, 255, 0},
+	}))
+}
+
+func (suite *PrefixTestSuite) TestSplitNoNetworks() {
+	suite.Equal([]string{"1.2.3.0/24"}, suite.split("1.2.3.0/24"))
+}
+
+func (suite *PrefixTestSuite) TestSplitLargerNetwork() {
+	suite.Equal([]string{"1.2.3.0/24"}, suite.split("1.2.3.0/24", "1.2.0.0/16"))
+}
+
+func (suite *PrefixTestSuite) TestSplitOtherFamily() {
+	suite.Equal([]string{"1.2.3.0/24"}, suite.split("1.2.3.0/24", "2a00::/16"))
+}
+
+func (suite *PrefixTestSuite) TestSplitInner() {
+	suite.Equal([]string{
+		"1.2.3.0/26",
+		"1.2.3.64/28",
+		"1.2.3.80/28",
+		"1.2.3.96/27",
+		"1.2.3.128/25",
+	}, suite.split("1.2.3.0/24", "1.2.3.64/28"))
+}
+
+func (suite *PrefixTestSuite) TestSplitOverlapped() {
+	suite.Equal([]string{
+		"1.2.3.0/25",
+		"1.2.3.128/25",
+	}, suite.split("1.2.3.0/24", "1.2.3.128/25", "1.2.3.128/25", "1.2.2.0/23"))
+}
+
+func (suite *PrefixTestSuite) TestSplitIPv6() {
+	suite.Equal([]string{
+		"2a00::/17",
+		"2a00:8000::/18",
+		"2a00:c000::/18",
+	}, suite.split("2a00::/16", "2a00:c000::/18"))
+}
+
+func (suite *PrefixTestSuite) TestSplitTooMany() {
+	networks := make([]*net.IPNet, 0, MaxPrefixSubnets+1)
+
+	for i := 0; i <= MaxPrefixSubnets; i++ {
+		networks = append(networks, &net.IPNet{
+			IP:   net.IPv4(10, byte(i>>8), byte(i), 0),
+			Mask: net.CIDRMask(24, 32),
+		})
+	}
+
+	_, err := splitPrefix(normalizePrefix(suite.parseCIDR("10.0.0.0/8")), networks)
+
+	suite.True(errors.Is(err, ErrPrefixTooLarge))
+}
+
+func TestPrefix(t *testing.T) {
+	suite.Run(t, &PrefixTestSuite{})
+}
@@ -0,0 +1,145 @@
+package topolib
+
+import "sort"
+
+// DefaultReadyMinProviders is a number of providers which have to be
+// ready so Topographer is ready. It is used if nothing else was set
+// with WithReadiness.
+const DefaultReadyMinProviders = 1
+
+// Statuses of providers reported by Topographer.Readiness.
+const (
+	// ProviderStatusDatabaseOpened means that offline provider has
+	// opened its database.
+	ProviderStatusDatabaseOpened = "database_opened"
+
+	// ProviderStatusDatabaseNotReady means that offline provider has
+	// not opened any database yet: it is still being downloaded.
+	ProviderStatusDatabaseNotReady = "database_not_ready"
+
+	// ProviderStatusCircuitBreakerClosed means that circuit breaker of
+	// online provider is closed and requests go through.
+	ProviderStatusCircuitBreakerClosed = "circuit_breaker_closed"
+
+	// ProviderStatusCircuitBreakerHalfOpened means that circuit breaker
+	// of online provider is probing if upstream has recovered.
+	ProviderStatusCircuitBreakerHalfOpened = "circuit_breaker_half_opened"
+
+	// ProviderStatusCircuitBreakerOpened means that circuit breaker of
+	// online provider is opened and all requests fail fast.
+	ProviderStatusCircuitBreakerOpened = "circuit_breaker_opened"
+
+	// ProviderStatusAvailable means that online provider has no known
+	// circuit breaker (HTTP client was not set with
+	// WithProviderHTTPClient) so it is considered as ready.
+	ProviderStatusAvailable = "available"
+)
+
+// ProviderReadiness is a status of a single provider.
+type ProviderReadiness struct {
+	// A name of the provider.
+	Name string `json:"name"`
+
+	// Ready is true if provider can serve lookups.
+	Ready bool `json:"ready"`
+
+	// Required is true if provider was set as required with
+	// WithReadiness.
+	Required bool `json:"required"`
+
+	// Status is one of ProviderStatus* constants.
+	Status string `json:"status"`
+}
+
+// Readiness is a result of Topographer.Readiness.
+type Readiness struct {
+	// Ready is true if enough providers are ready and all required
+	// providers are ready.
+	Ready bool `json:"ready"`
+
+	// MinProviders is a number of providers which have to be ready.
+	MinProviders int `json:"min_providers"`
+
+	// ReadyProviders is a number of providers which are ready.
+	ReadyProviders int `json:"ready_providers"`
+
+	// Providers is a list of provider statuses sorted by name.
+	Providers []ProviderReadiness `json:"providers"`
+}
+
+// Readiness reports if Topographer is ready to serve lookups. Offline
+// provider is ready if it has opened a database. Online provider is
+// ready if its circuit breaker is closed.
+//
+// Topographer is ready if at least MinProviders providers are ready
+// and each required provider is ready. Both are set with
+// WithReadiness. If required provider was removed with RemoveProvider,
+// Topographer is not ready.
+func (t *Topographer) Readiness() Readiness {
+	t.rwmutex.RLock()
+	defer t.rwmutex.RUnlock()
+
+	rv := Readiness{
+		Ready:        !t.isClosed(),
+		MinProviders: t.readyMinProviders,
+		Providers:    make([]ProviderReadiness, 0, len(t.providers)),
+	}
+
+	for name, provider := range t.providers {
+		status := ProviderReadiness{
+			Name:   name,
+			Status: t.getProviderStatus(name, provider),
+		}
+
+		switch status.Status {
+		case ProviderStatusDatabaseOpened, ProviderStatusCircuitBreakerClosed, ProviderStatusAvailable:
+			status.Ready = true
+			rv.ReadyProviders++
+		}
+
+		if _, ok := t.readyRequiredProviders[name]; ok {
+			status.Required = true
+			rv.Ready = rv.Ready && status.Ready
+		}
+
+		rv.Providers = append(rv.Providers, status)
+	}
+
+	for name := range t.readyRequiredProviders {
+		if _, ok := t.providers[name]; !ok {
+			rv.Ready = false
+		}
+	}
+
+	rv.Ready = rv.Ready && rv.ReadyProviders >= rv.MinProviders
+
+	sort.Slice(rv.Providers, func(i, j int) bool {
+		return rv.Providers[i].Name < rv.Providers[j].Name
+	})
+
+	return rv
+}
+
+func (t *Topographer) getProviderStatus(name string, provider Provider) string {
+	if _, ok := provider.(OfflineProvider); ok {
+		if t.providerStats[name].lastUpdatedTime().IsZero() {
+			return ProviderStatusDatabaseNotReady
+		}
+
+		return ProviderStatusDatabaseOpened
+	}
+
+	client, ok := t.httpClients[name].(httpClient)
+	if !ok {
+		return ProviderStatusAvailable
+	}
+
+	switch client.circuitBreaker.getState() {
+	case circuitBreakerStateClosed:
+		return ProviderStatusCircuitBreakerClosed
+	case circuitBreakerStateHalfOpened:
+		return ProviderStatusCircuitBreakerHalfOpened
+	}
+
+	return ProviderStatusCircuitBreakerOpened
+}
@@ -0,0 +1,91 @@
+package topolib
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// resolveCall is an in-flight resolving of the IP. ok is false if
+// resolving was interrupted (for example, context of the request which
+// started it was closed), so its result cannot be shared.
+type resolveCall struct {
+	done   chan struct{}
+	result ResolveResult
+	ok     bool
+}
+
+// resolveGroup coalesces concurrent resolvings of the same IP with the
+// same set of providers: only the first one goes to providers, others
+// wait for its result. This is the same idea singleflight has.
+type resolveGroup struct {
+	mutex sync.Mutex
+	calls map[string]*resolveCall
+}
+
+// do executes fn if there is no in-flight call with the same key.
+// Otherwise, it waits for a result of this call. If call was
+// interrupted or ctx is closed, ok is false.
+func (r *resolveGroup) do(ctx context.Context,
+	key string,
+	fn func() (ResolveResult, bool)) (ResolveResult, bool) {
+	r.mutex.Lock()
+
+	if call, ok := r.calls[key]; ok {
+		r.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ResolveResult{}, false
+		case <-call.done:
+			return call.result, call.ok
+		}
+	}
+
+	call := &resolveCall{
+		done: make(chan struct{}),
+	}
+	r.calls[key] = call
+
+	r.mutex.Unlock()
+
+	defer func() {
+		r.mutex.Lock()
+		delete(r.calls, key)
+		r.mutex.Unlock()
+
+		close(call.done)
+	}()
+
+	call.result, call.ok = fn()
+
+	return call.result, call.ok
+}
+
+func newResolveGroup() *resolveGroup {
+	return &resolveGroup{
+		calls: map[string]*resolveCall{},
+	}
+}
+
+// makeResolveKey makes a key of the resolving of the IP with a given
+// set of providers.
+func makeResolveKey(ip net.IP, providers []Provider) string {
+	return ip.String() + "|" + makeProvidersKey(providers)
+}
+
+// makeProvidersKey returns sorted names of providers joined into a
+// single string.
+func makeProvidersKey(providers []Provider) string {
+	names := make([]string, len(providers))
+
+	for i, v := range providers {
+		names[i] = v.Name()
+	}
+
+	sort.Strings(names)
+
+	return strings.Join(names, ",")
+}
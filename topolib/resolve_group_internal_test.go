@@ -0,0 +1,146 @@
+package topolib
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ResolveGroupTestSuite struct {
+	suite.Suite
+
+	g *resolveGroup
+}
+
+func (suite *ResolveGroupTestSuite) SetupTest() {
+	suite.g = newResolveGroup()
+}
+
+func (suite *ResolveGroupTestSuite) TestCoalesce() {
+	var calls int32
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fn := func() (ResolveResult, bool) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+
+		<-release
+
+		return ResolveResult{City: "Moscow"}, true
+	}
+
+	results := make(chan ResolveResult, 5)
+	wg := &sync.WaitGroup{}
+
+	wg.Add(5)
+
+	for i := 0; i < 5; i++ {
+		go func() {
+			defer wg.Done()
+
+			result, ok := suite.g.do(context.Background(), "key", fn)
+
+			suite.True(ok)
+
+			results <- result
+		}()
+
+		if i == 0 {
+			<-started
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	suite.EqualValues(1, atomic.LoadInt32(&calls))
+	suite.Empty(suite.g.calls)
+
+	for v := range results {
+		suite.Equal("Moscow", v.City)
+	}
+}
+
+func (suite *ResolveGroupTestSuite) TestInterrupted() {
+	release := make(chan struct{})
+	done := make(chan bool)
+
+	go func() {
+		_, ok := suite.g.do(context.Background(), "key", func() (ResolveResult, bool) {
+			<-release
+
+			return ResolveResult{}, false
+		})
+
+		done <- ok
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	go func() {
+		_, ok := suite.g.do(context.Background(), "key", func() (ResolveResult, bool) {
+			return ResolveResult{}, true
+		})
+
+		done <- ok
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	suite.False(<-done)
+	suite.False(<-done)
+}
+
+func (suite *ResolveGroupTestSuite) TestContextClosed() {
+	release := make(chan struct{})
+
+	defer close(release)
+
+	go suite.g.do(context.Background(), "key", func() (ResolveResult, bool) {
+		<-release
+
+		return ResolveResult{}, true
+	})
+
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+
+	defer cancel()
+
+	_, ok := suite.g.do(ctx, "key", func() (ResolveResult, bool) {
+		return ResolveResult{}, true
+	})
+
+	suite.False(ok)
+}
+
+func (suite *ResolveGroupTestSuite) TestMakeResolveKey() {
+	providers := []Provider{}
+
+	for _, v := range []string{"b", "a"} {
+		provider := &ProviderMock{}
+		provider.On("Name").Return(v)
+
+		providers = append(providers, provider)
+	}
+
+	suite.Equal("80.80.80.80|a,b",
+		makeResolveKey(net.ParseIP("80.80.80.80"), providers))
+	suite.Equal("2a00:1450:4001::1|b",
+		makeResolveKey(net.ParseIP("2a00:1450:4001::1"), providers[:1]))
+}
+
+func TestResolveGroup(t *testing.T) {
+	suite.Run(t, &ResolveGroupTestSuite{})
+}
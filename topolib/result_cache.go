@@ -0,0 +1,116 @@
+package topolib
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+)
+
+// resultCache caches final verdicts of Topographer. A key is made of
+// the address (or its prefix if aggregation is enabled and exact key
+// is not requested) and names of used providers. Each key also contains a generation: when some
+// offline provider reloads its database, generation is increased so
+// all previous entries become unreachable and eventually evicted.
+type resultCache struct {
+	// generation is accessed atomically so it goes first to be aligned
+	// on 32-bit platforms.
+	generation uint64
+
+	cache            *ristretto.Cache
+	ttl              time.Duration
+	ipv4PrefixLength int
+	ipv6PrefixLength int
+}
+
+func (r *resultCache) get(ip net.IP, providers []Provider, exact bool) (ResolveResult, bool) {
+	value, ok := r.cache.Get(r.makeKey(ip, providers, exact))
+	if !ok {
+		return ResolveResult{}, false
+	}
+
+	rv := value.(ResolveResult)
+	rv.IP = ip
+
+	return rv, true
+}
+
+func (r *resultCache) set(ip net.IP, providers []Provider, exact bool, result ResolveResult) {
+	// it makes no sense to cache results without a verdict: usually
+	// this means that providers have failed or databases are not ready
+	// yet.
+	if result.Country.Alpha2Code == "" {
+		return
+	}
+
+	r.cache.SetWithTTL(r.makeKey(ip, providers, exact), result, 1, r.ttl)
+}
+
+func (r *resultCache) invalidate() {
+	atomic.AddUint64(&r.generation, 1)
+}
+
+func (r *resultCache) makeKey(ip net.IP, providers []Provider, exact bool) string {
+	builder := strings.Builder{}
+
+	builder.WriteString(strconv.FormatUint(atomic.LoadUint64(&r.generation), 10))
+	builder.WriteByte('|')
+
+	if exact {
+		// exact keys are separated from aggregated ones: otherwise
+		// a verdict for the first address of the prefix could be
+		// taken from a verdict for another address of this prefix.
+		builder.WriteString("=")
+		builder.WriteString(ip.String())
+	} else {
+		builder.WriteString(r.maskIP(ip).String())
+	}
+	builder.WriteByte('|')
+	builder.WriteString(makeProvidersKey(providers))
+
+	return builder.String()
+}
+
+func (r *resultCache) maskIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(r.ipv4PrefixLength, 8*net.IPv4len))
+	}
+
+	return ip.Mask(net.CIDRMask(r.ipv6PrefixLength, 8*net.IPv6len))
+}
+
+func newResultCache(itemsCount uint,
+	ttl time.Duration,
+	ipv4PrefixLength, ipv6PrefixLength int) *resultCache {
+	if ipv4PrefixLength <= 0 || ipv4PrefixLength > 8*net.IPv4len {
+		ipv4PrefixLength = 8 * net.IPv4len
+	}
+
+	if ipv6PrefixLength <= 0 || ipv6PrefixLength > 8*net.IPv6len {
+		ipv6PrefixLength = 8 * net.IPv6len
+	}
+
+	return &resultCache{
+		cache:            newRistrettoCache(itemsCount),
+		ttl:              ttl,
+		ipv4PrefixLength: ipv4PrefixLength,
+		ipv6PrefixLength: ipv6PrefixLength,
+	}
+}
+
+// newRistrettoCache makes a cache which keeps up to itemsCount items.
+func newRistrettoCache(itemsCount uint) *ristretto.Cache {
+	cache, err := ristretto.NewCache(&ristretto.Config{
+		MaxCost:     int64(itemsCount),
+		NumCounters: 10 * int64(itemsCount),
+		BufferItems: 64,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cache
+}
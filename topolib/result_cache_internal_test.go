@@ -0,0 +1,105 @@
+package topolib
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ResultCacheTestSuite struct {
+	suite.Suite
+
+	providers []Provider
+}
+
+func (suite *ResultCacheTestSuite) SetupTest() {
+	suite.providers = []Provider{}
+
+	for _, v := range []string{"b", "a"} {
+		provider := &ProviderMock{}
+		provider.On("Name").Return(v)
+
+		suite.providers = append(suite.providers, provider)
+	}
+}
+
+func (suite *ResultCacheTestSuite) TestMakeKeyExact() {
+	cache := newResultCache(100, time.Minute, 0, 0)
+
+	suite.Equal("0|80.80.80.80|a,b",
+		cache.makeKey(net.ParseIP("80.80.80.80"), suite.providers, false))
+	suite.Equal("0|2a00:1450:4001::1|a,b",
+		cache.makeKey(net.ParseIP("2a00:1450:4001::1"), suite.providers, false))
+}
+
+func (suite *ResultCacheTestSuite) TestMakeKeyPrefix() {
+	cache := newResultCache(100, time.Minute, 24, 48)
+
+	suite.Equal("0|80.80.80.0|a,b",
+		cache.makeKey(net.ParseIP("80.80.80.80"), suite.providers, false))
+	suite.Equal("0|2a00:1450:4001::|b",
+		cache.makeKey(net.ParseIP("2a00:1450:4001:1::1"), suite.providers[:1], false))
+}
+
+func (suite *ResultCacheTestSuite) TestMakeKeyPrefixExact() {
+	cache := newResultCache(100, time.Minute, 24, 48)
+
+	suite.Equal("0|=80.80.80.80|a,b",
+		cache.makeKey(net.ParseIP("80.80.80.80"), suite.providers, true))
+	suite.Equal("0|=80.80.80.0|a,b",
+		cache.makeKey(net.ParseIP("80.80.80.0"), suite.providers, true))
+	suite.Equal("0|80.80.80.0|a,b",
+		cache.makeKey(net.ParseIP("80.80.80.0"), suite.providers, false))
+}
+
+func (suite *ResultCacheTestSuite) TestInvalidate() {
+	cache := newResultCache(100, time.Minute, 0, 0)
+	ip := net.ParseIP("80.80.80.80")
+
+	cache.invalidate()
+
+	suite.Equal("1|80.80.80.80|a,b", cache.makeKey(ip, suite.providers, false))
+}
+
+func (suite *ResultCacheTestSuite) TestGetSet() {
+	cache := newResultCache(100, time.Minute, 24, 48)
+	result := ResolveResult{IP: net.ParseIP("80.80.80.80"), City: "Moscow"}
+	ip := net.ParseIP("80.80.80.81")
+
+	cache.set(result.IP, suite.providers, false, result)
+
+	// ristretto is eventually consistent
+	time.Sleep(100 * time.Millisecond)
+
+	_, ok := cache.get(ip, suite.providers, false)
+
+	suite.False(ok)
+
+	result.SetCountry(Alpha2ToCountryCode("RU"))
+	cache.set(result.IP, suite.providers, false, result)
+
+	time.Sleep(100 * time.Millisecond)
+
+	cached, ok := cache.get(ip, suite.providers, false)
+
+	suite.True(ok)
+	suite.Equal("Moscow", cached.City)
+	suite.Equal("RU", cached.Country.Alpha2Code)
+	suite.True(cached.IP.Equal(ip))
+
+	_, ok = cache.get(ip, suite.providers[:1], false)
+
+	suite.False(ok)
+
+	cache.invalidate()
+
+	_, ok = cache.get(ip, suite.providers, false)
+
+	suite.False(ok)
+}
+
+func TestResultCache(t *testing.T) {
+	suite.Run(t, &ResultCacheTestSuite{})
+}
@@ -6,19 +6,29 @@ import "net"
 // made by Topographer.
 //
 // Consolidation means that there is a process of 'voting' for
-// the location. Currently it is done in a following way:
+// the location. This is done by Merger. A default one works in a
+// following way:
 //
 // 1. All providers give their results
 //
-// 2. Topographer choose the most 'popular country'
+// 2. Topographer choose the most 'popular country'. Votes are summed
+// by weights of providers.
 //
 // 3. It choose the most 'popular city' among these from step 2.
 //
-// 4. This is a verdict.
+// 4. It choose the most 'popular ASN' among all providers.
+//
+// 5. This is a verdict.
 type ResolveResult struct {
 	// IP is IP address which we resolve.
 	IP net.IP `json:"ip"`
 
+	// AddressType is a type of IP address. Only public addresses are
+	// resolved by providers. Others (private, loopback, multicast etc.)
+	// get an immediate result with no geolocation. Please see
+	// AddressType* constants.
+	AddressType string `json:"address_type"`
+
 	// Country is a set of details on a choosen country.
 	Country struct {
 		// Alpha2Code is 2-letter ISO3166 code. For example, for Russia
@@ -41,6 +51,43 @@ type ResolveResult struct {
 	// City is a name of the city this IP belongs to.
 	City string `json:"city"`
 
+	// Region is a name of the region (subdivision of the country like
+	// state, oblast or province) this IP belongs to.
+	Region string `json:"region"`
+
+	// Location is an averaged geographical position of this IP. It is
+	// nil if none of providers knows coordinates of the IP.
+	Location *Location `json:"location"`
+
+	// ASN is a number of autonomous system this IP belongs to. 0 means
+	// that none of providers knows it.
+	ASN uint32 `json:"asn"`
+
+	// Organization is a name of the organization which operates
+	// autonomous system.
+	Organization string `json:"organization"`
+
+	// CountryConfidence is a share of votes for the chosen country
+	// among providers which have answered with some country. It is a
+	// number in [0, 1] range where 1 means that all providers agree.
+	CountryConfidence float64 `json:"country_confidence"`
+
+	// CityConfidence is a share of votes for the chosen city among
+	// providers which have answered with some city. It is a number in
+	// [0, 1] range where 1 means that all providers agree.
+	CityConfidence float64 `json:"city_confidence"`
+
+	// Voters is a number of providers which have answered with some
+	// country.
+	Voters int `json:"voters"`
+
+	// Abstainers is a number of providers which were asked but have
+	// not answered with any country. For example, they have failed or
+	// have no information about this IP address. Providers which
+	// cannot answer with a country at all (like ASN databases) are
+	// neither voters nor abstainers.
+	Abstainers int `json:"abstainers"`
+
 	// Details is a list of 'raw' results generated by Providers. If you
 	// are interested in why Topographer choose this country or what was
 	// the choise of ipinfo.io, this is a field to go.
@@ -48,11 +95,36 @@ type ResolveResult struct {
 }
 
 // OK checks if response has some data. For example, it is possible that
-// we have no city or country.
+// we have no city or country. Results for special-purpose addresses
+// are always OK: there is nothing to resolve there.
 func (r *ResolveResult) OK() bool {
+	if r.Special() {
+		return true
+	}
+
 	return r.Country.Alpha2Code != "" && r.City != ""
 }
 
+// Special checks if IP address belongs to some special-purpose range
+// like private networks or loopback.
+func (r *ResolveResult) Special() bool {
+	return r.AddressType != "" && r.AddressType != AddressTypePublic
+}
+
+// SetCountry fills Country field with details of the given country
+// code. If country code is unknown, it does nothing.
+func (r *ResolveResult) SetCountry(code CountryCode) {
+	if !code.Known() {
+		return
+	}
+
+	details := code.Details()
+	r.Country.Alpha2Code = details.Alpha2
+	r.Country.Alpha3Code = details.Alpha3
+	r.Country.CommonName = details.Name.Common
+	r.Country.OfficialName = details.Name.Official
+}
+
 // ResolveResultDetail is a result generated by Provider.
 type ResolveResultDetail struct {
 	// ProviderName is a name of the provider which made
@@ -64,16 +136,98 @@ type ResolveResultDetail struct {
 
 	// City is a name of the city.
 	City string `json:"city"`
+
+	// Region is a name of the region.
+	Region string `json:"region"`
+
+	// Location is a geographical position of IP. It is nil if
+	// provider does not know coordinates.
+	Location *Location `json:"location"`
+
+	// TimeZone is a name of the time zone from IANA database (for
+	// example, Europe/Moscow) or UTC offset (like +03:00) if provider
+	// knows nothing better.
+	TimeZone string `json:"time_zone"`
+
+	// PostalCode is a postal code of the area.
+	PostalCode string `json:"postal_code"`
+
+	// ASN is a number of autonomous system.
+	ASN uint32 `json:"asn"`
+
+	// Organization is a name of the organization which operates
+	// autonomous system.
+	Organization string `json:"organization"`
+
+	// VoteWeight is a weight of the vote of this provider.
+	VoteWeight float64 `json:"vote_weight"`
+
+	// TimedOut is true if provider has not answered in time: lookup
+	// deadline or soft timeout of the provider was reached or a verdict
+	// was made without this provider (see WithEarlyVerdict).
+	TimedOut bool `json:"timed_out"`
+
+	// Capabilities is a set of things provider is able to answer with.
+	// 0 means that capabilities are unknown so provider is treated as
+	// capable of everything.
+	Capabilities Capability `json:"-"`
+}
+
+// Can checks if provider which made this result is able to answer
+// with given fields.
+func (r *ResolveResultDetail) Can(capability Capability) bool {
+	return r.Capabilities == 0 || r.Capabilities.Has(capability)
 }
 
 // ProviderLookupResult is a strucutre which is returned by Provider
 // interface. It is not the same as ResolveResultDetail. A latter one is
 // used in consolidated responses while ProviderLookupResult should be
 // used by those who want to implement their own providers.
+//
+// All fields except of CountryCode are optional. Please fill them if
+// provider has such information.
 type ProviderLookupResult struct {
 	// CountryCode is a code of the chosen country.
 	CountryCode CountryCode
 
 	// City is the name of the city.
 	City string
+
+	// Region is the name of the region (subdivision of the country
+	// like state, oblast or province).
+	Region string
+
+	// Location is a geographical position of IP. Please keep it nil
+	// if provider has no idea about coordinates.
+	Location *Location
+
+	// TimeZone is a name of the time zone from IANA database (for
+	// example, Europe/Moscow). If provider has no such name, it is
+	// ok to use UTC offset like +03:00.
+	TimeZone string
+
+	// PostalCode is a postal code of the area.
+	PostalCode string
+
+	// ASN is a number of autonomous system this IP belongs to. Please
+	// keep it 0 if provider does not know it.
+	ASN uint32
+
+	// Organization is a name of the organization which operates
+	// autonomous system.
+	Organization string
+}
+
+// Location is a geographical position of IP address.
+type Location struct {
+	// Latitude is a latitude in degrees.
+	Latitude float64 `json:"latitude"`
+
+	// Longitude is a longitude in degrees.
+	Longitude float64 `json:"longitude"`
+
+	// AccuracyRadius is a radius in kilometers around the given
+	// coordinates where this IP is located. 0 means that accuracy is
+	// unknown.
+	AccuracyRadius float64 `json:"accuracy_radius"`
 }
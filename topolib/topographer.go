@@ -2,14 +2,15 @@ package topolib
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/antzucaro/matchr"
 	"github.com/panjf2000/ants/v2"
 )
 
@@ -28,6 +29,16 @@ const (
 	// Usually you want to have this number of workers in pool.
 	DefaultWorkerPoolSize = 4096
 
+	// DefaultVoteWeight is a weight of the provider vote which is used
+	// if nothing else was set with WithVoteWeight.
+	DefaultVoteWeight = 1.0
+
+	// DefaultTierConfidence is a confidence of the country verdict
+	// which is required to skip the next tiers of providers. It is used
+	// if nothing else was set with WithTierConfidence. 1 means that all
+	// providers of previous tiers have to agree.
+	DefaultTierConfidence = 1.0
+
 	workerPoolExpireTime = time.Minute
 )
 
@@ -35,13 +46,64 @@ const (
 // provider management, background updates and IP lookups. It also
 // contains an instance of worker pool to use.
 type Topographer struct {
-	logger        Logger
-	providers     map[string]Provider
-	providerStats map[string]*UsageStats
-	rwmutex       sync.RWMutex
-	closeOnce     sync.Once
-	workerPool    *ants.PoolWithFunc
-	closed        bool
+	logger                 Logger
+	providers              map[string]Provider
+	providerStats          map[string]*UsageStats
+	providerInflight       map[string]*sync.WaitGroup
+	pendingProviders       map[string]struct{}
+	voteWeights            map[string]float64
+	trustedProxies         []*net.IPNet
+	trustedProxyHeader     string
+	httpClients            map[string]HTTPClient
+	httpStats              *httpStats
+	agreementStats         *agreementStats
+	resultCache            *resultCache
+	negativeCache          *negativeCache
+	resolveGroup           *resolveGroup
+	providerTimeouts       map[string]time.Duration
+	lookupDeadline         time.Duration
+	earlyVerdict           bool
+	earlyVerdictQuorum     int
+	providerTiers          map[string]int
+	tierConfidence         float64
+	merger                 Merger
+	readyMinProviders      int
+	readyRequiredProviders map[string]struct{}
+	rwmutex                sync.RWMutex
+	closeOnce              sync.Once
+	workerPool             *ants.PoolWithFunc
+	closed                 uint32
+}
+
+// providerLookup is a provider which is acquired for a single lookup
+// (see acquireProviders) with its settings. Settings are copied so
+// lookup does not access maps of Topographer which could be changed
+// concurrently.
+//
+// inflight tracks lookups which use this provider. Each goroutine
+// which calls the provider adds itself there, so provider is not shut
+// down until the last of them is finished, even if nobody waits for
+// its result.
+type providerLookup struct {
+	provider Provider
+	stat     *UsageStats
+	timeout  time.Duration
+	tier     int
+	inflight *sync.WaitGroup
+}
+
+// providerLookupResult is an answer of the provider. Provider is not
+// asked if cached is true: it is known from the negative cache that
+// provider does not know this IP.
+type providerLookupResult struct {
+	detail  ResolveResultDetail
+	err     error
+	elapsed time.Duration
+	cached  bool
+}
+
+func (p *providerLookup) Name() string {
+	return p.provider.Name()
 }
 
 // ServeHTTP is to conform http.Handler interface.
@@ -57,34 +119,41 @@ func (t *Topographer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 func (t *Topographer) ResolveAll(ctx context.Context,
 	ips []net.IP,
 	providers []string) ([]ResolveResult, error) {
-	t.rwmutex.RLock()
-	defer t.rwmutex.RUnlock()
+	providersToUse, err := t.selectProviders(providers)
+	if err != nil {
+		return nil, err
+	}
 
 	ctx, cancel := context.WithCancel(ctx)
 
 	defer cancel()
 
-	if t.closed {
-		return nil, ErrTopographerShutdown
-	}
-
-	providersToUse, err := t.getProvidersToUse(providers)
-	if err != nil {
-		return nil, err
-	}
+	return t.resolveAll(ctx, ips, providersToUse, false), nil
+}
 
+// resolveAll resolves a batch of IPs with given providers. Results are
+// returned in the same order as IPs. If exactCacheKey is set, results
+// are cached for exact addresses even if result cache aggregates them
+// by prefixes.
+func (t *Topographer) resolveAll(ctx context.Context,
+	ips []net.IP,
+	providersToUse []Provider,
+	exactCacheKey bool) []ResolveResult {
 	resultChannel := make(chan ResolveResult, len(ips))
 	rv := make([]ResolveResult, 0, len(ips))
 	wg := &sync.WaitGroup{}
 	groupRequest := newPoolGroupRequest(ctx, resultChannel,
 		providersToUse, wg, t.workerPool)
+	groupRequest.exactCacheKey = exactCacheKey
 
 	ipsToIndex := map[string]int{}
 
 	for i, v := range ips {
 		vv := v.To16()
 
-		if err := groupRequest.Do(ctx, vv); err != nil {
+		if addressType := getAddressType(vv); addressType != AddressTypePublic {
+			rv = append(rv, newSpecialResolveResult(vv, addressType))
+		} else if err := groupRequest.Do(ctx, vv); err != nil {
 			break
 		}
 
@@ -104,37 +173,105 @@ func (t *Topographer) ResolveAll(ctx context.Context,
 		return ipsToIndex[string(rv[i].IP)] < ipsToIndex[string(rv[j].IP)]
 	})
 
-	return rv, nil
+	return rv
 }
 
-// Resolve geolocation of the single IP.
+// ResolveStream resolves IP addresses which come from a given channel
+// and sends results as soon as they are ready. So, an order of results
+// is not the same as an order of given IPs.
+//
+// Resolving stops when ips channel is closed or context is done. After
+// that, a result channel is closed. Please pay attention that the
+// result channel is not buffered: if you do not read from it, workers
+// are blocked and no new IPs are consumed from ips channel. To stop
+// reading prematurely, cancel the context.
+//
+// A set of providers is taken when stream starts. If some of them is
+// replaced with ReplaceProvider, the stream uses a new one; if it is
+// removed, the stream does not use it anymore. Providers added after
+// stream has started are not used.
 //
 // 'providers' argument contains names of the providers to use. If you
 // want to use all providers, simply pass nil here.
-func (t *Topographer) Resolve(ctx context.Context,
-	ip net.IP,
-	providers []string) (ResolveResult, error) {
-	t.rwmutex.RLock()
-	defer t.rwmutex.RUnlock()
+func (t *Topographer) ResolveStream(ctx context.Context,
+	ips <-chan net.IP,
+	providers []string) (<-chan ResolveResult, error) {
+	providersToUse, err := t.selectProviders(providers)
+	if err != nil {
+		return nil, err
+	}
 
 	ctx, cancel := context.WithCancel(ctx)
+	resultChannel := make(chan ResolveResult)
+	wg := &sync.WaitGroup{}
+	groupRequest := newPoolGroupRequest(ctx, resultChannel,
+		providersToUse, wg, t.workerPool)
 
-	defer cancel()
+	go func() {
+		defer cancel()
+
+		t.resolveStream(ctx, ips, groupRequest, resultChannel)
+
+		wg.Wait()
+		close(resultChannel)
+	}()
+
+	return resultChannel, nil
+}
+
+func (t *Topographer) resolveStream(ctx context.Context,
+	ips <-chan net.IP,
+	groupRequest *poolGroupRequest,
+	resultChannel chan<- ResolveResult) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ip, ok := <-ips:
+			if !ok {
+				return
+			}
+
+			ip = ip.To16()
+
+			if addressType := getAddressType(ip); addressType != AddressTypePublic {
+				select {
+				case <-ctx.Done():
+					return
+				case resultChannel <- newSpecialResolveResult(ip, addressType):
+				}
+			} else if err := groupRequest.Do(ctx, ip); err != nil {
+				return
+			}
+		}
+	}
+}
 
+// Resolve geolocation of the single IP.
+//
+// 'providers' argument contains names of the providers to use. If you
+// want to use all providers, simply pass nil here.
+func (t *Topographer) Resolve(ctx context.Context,
+	ip net.IP,
+	providers []string) (ResolveResult, error) {
 	ip = ip.To16()
 	rv := ResolveResult{
 		IP: ip,
 	}
 
-	if t.closed {
-		return rv, ErrTopographerShutdown
-	}
-
-	providersToUse, err := t.getProvidersToUse(providers)
+	providersToUse, err := t.selectProviders(providers)
 	if err != nil {
 		return rv, err
 	}
 
+	ctx, cancel := context.WithCancel(ctx)
+
+	defer cancel()
+
+	if addressType := getAddressType(ip); addressType != AddressTypePublic {
+		return newSpecialResolveResult(ip, addressType), nil
+	}
+
 	resultChannel := make(chan ResolveResult)
 	wg := &sync.WaitGroup{}
 	groupRequest := newPoolGroupRequest(ctx, resultChannel,
@@ -154,6 +291,13 @@ func (t *Topographer) Resolve(ctx context.Context,
 
 // UsageStats returns an array with stats.
 func (t *Topographer) UsageStats() []*UsageStats {
+	t.rwmutex.RLock()
+	defer t.rwmutex.RUnlock()
+
+	return t.usageStats()
+}
+
+func (t *Topographer) usageStats() []*UsageStats {
 	stats := make([]*UsageStats, 0, len(t.providerStats))
 
 	for _, v := range t.providerStats {
@@ -167,6 +311,70 @@ func (t *Topographer) UsageStats() []*UsageStats {
 	return stats
 }
 
+func (t *Topographer) isClosed() bool {
+	return atomic.LoadUint32(&t.closed) != 0
+}
+
+// selectProviders returns providers with given names. These providers
+// only tell which ones to use: each lookup acquires actual providers
+// with acquireProviders.
+func (t *Topographer) selectProviders(names []string) ([]Provider, error) {
+	t.rwmutex.RLock()
+	defer t.rwmutex.RUnlock()
+
+	if t.isClosed() {
+		return nil, ErrTopographerShutdown
+	}
+
+	return t.getProvidersToUse(names)
+}
+
+// acquireProviders takes providers which are registered now under
+// names of given ones: removed providers are skipped, replaced ones
+// are substituted by their replacements.
+//
+// Acquired providers have to be released with a returned function.
+// RemoveProvider, ReplaceProvider and Shutdown wait until providers
+// are released before shutting them down, so lookups can use them
+// without holding a lock of Topographer.
+func (t *Topographer) acquireProviders(providers []Provider) ([]*providerLookup, func()) {
+	t.rwmutex.RLock()
+	defer t.rwmutex.RUnlock()
+
+	if t.isClosed() {
+		return nil, func() {}
+	}
+
+	rv := make([]*providerLookup, 0, len(providers))
+
+	for _, v := range providers {
+		name := v.Name()
+
+		provider, ok := t.providers[name]
+		if !ok {
+			continue
+		}
+
+		lookup := &providerLookup{
+			provider: provider,
+			stat:     t.providerStats[name],
+			timeout:  t.providerTimeouts[name],
+			tier:     t.providerTiers[name],
+			inflight: t.providerInflight[name],
+		}
+
+		lookup.inflight.Add(1)
+
+		rv = append(rv, lookup)
+	}
+
+	return rv, func() {
+		for _, v := range rv {
+			v.inflight.Done()
+		}
+	}
+}
+
 func (t *Topographer) getProvidersToUse(names []string) ([]Provider, error) {
 	rv := make([]Provider, 0, len(names))
 
@@ -188,15 +396,384 @@ func (t *Topographer) getProvidersToUse(names []string) ([]Provider, error) {
 	return rv, nil
 }
 
-func (t *Topographer) Shutdown() {
+// getCapableProviders filters out those providers which cannot
+// resolve a given IP address.
+func (t *Topographer) getCapableProviders(ip net.IP, providers []Provider) []Provider {
+	rv := make([]Provider, 0, len(providers))
+
+	for _, v := range providers {
+		if getCapabilities(v).SupportsIP(ip) {
+			rv = append(rv, v)
+		}
+	}
+
+	return rv
+}
+
+func (t *Topographer) getVoteWeight(name string) float64 {
+	if weight, ok := t.voteWeights[name]; ok {
+		return weight
+	}
+
+	return DefaultVoteWeight
+}
+
+// setCircuitBreakerCallback makes circuit breaker of the provider HTTP
+// client to report its state changes to the logger if it is capable of
+// that.
+func (t *Topographer) setCircuitBreakerCallback(name string) {
+	cbLogger, ok := t.logger.(CircuitBreakerLogger)
+	if !ok {
+		return
+	}
+
+	if client, ok := t.httpClients[name].(httpClient); ok {
+		client.circuitBreaker.setStateCallback(func(from, to uint32) {
+			cbLogger.CircuitBreakerStateChanged(name,
+				circuitBreakerStateNames[from],
+				circuitBreakerStateNames[to])
+		})
+	}
+}
+
+// invalidateCaches is called when some offline provider has reloaded
+// its database so cached verdicts and errors could be outdated.
+func (t *Topographer) invalidateCaches() {
+	if t.resultCache != nil {
+		t.resultCache.invalidate()
+	}
+
+	if t.negativeCache != nil {
+		t.negativeCache.invalidate()
+	}
+}
+
+// AddProvider registers a new provider in working Topographer. If
+// this is OfflineProvider, its background updates are started the same
+// way NewTopographer does.
+//
+// opts set up only this provider and they are applied only if provider
+// is registered. Settings which were set for the provider with this
+// name before (by options of NewTopographer or by previous calls) are
+// kept unless opts override them.
+//
+// A new provider is used by lookups which start after AddProvider
+// returns. In-flight lookups are not interrupted. Offline provider
+// opens its database without blocking lookups.
+func (t *Topographer) AddProvider(provider Provider, opts ...ProviderOption) error {
+	t.rwmutex.Lock()
+
+	if t.isClosed() {
+		t.rwmutex.Unlock()
+
+		return ErrTopographerShutdown
+	}
+
+	name := provider.Name()
+
+	if _, ok := t.providers[name]; ok {
+		t.rwmutex.Unlock()
+
+		return fmt.Errorf("provider %s is already registered", name)
+	}
+
+	if err := t.reserveProvider(name); err != nil {
+		t.rwmutex.Unlock()
+
+		return err
+	}
+
+	settings := t.getProviderSettings(name)
+
+	t.rwmutex.Unlock()
+
+	for _, opt := range opts {
+		opt(&settings)
+	}
+
+	provider, stat, err := t.startProvider(provider, settings)
+
 	t.rwmutex.Lock()
 	defer t.rwmutex.Unlock()
 
-	t.closed = true
+	delete(t.pendingProviders, name)
+
+	switch {
+	case err != nil:
+		return err
+	case t.isClosed():
+		shutdownProvider(provider)
+
+		return ErrTopographerShutdown
+	}
+
+	t.registerProvider(name, provider, stat, settings)
+	t.invalidateCaches()
+
+	return nil
+}
+
+// RemoveProvider unregisters a provider with a given name. New lookups
+// do not use it anymore; RemoveProvider waits until in-flight lookups
+// which use this provider are done, so nobody is using it after that.
+// Other lookups are not blocked. If this is OfflineProvider, it is
+// shutdown. Its HTTP client and agreement stats are forgotten.
+//
+// If this provider was required by WithReadiness, Topographer is not
+// ready until a provider with the same name is added back.
+func (t *Topographer) RemoveProvider(name string) error {
+	t.rwmutex.Lock()
+
+	if t.isClosed() {
+		t.rwmutex.Unlock()
+
+		return ErrTopographerShutdown
+	}
+
+	provider, ok := t.providers[name]
+	if !ok {
+		t.rwmutex.Unlock()
+
+		return fmt.Errorf("provider %s is unknown", name)
+	}
+
+	inflight := t.providerInflight[name]
+
+	delete(t.providers, name)
+	delete(t.providerStats, name)
+	delete(t.providerInflight, name)
+	delete(t.httpClients, name)
+	t.invalidateCaches()
+
+	t.rwmutex.Unlock()
+
+	inflight.Wait()
+	t.agreementStats.removeProvider(name)
+	shutdownProvider(provider)
+
+	return nil
+}
+
+// ReplaceProvider replaces a registered provider with the same name
+// by a given one. This is useful to reconfigure provider: for example,
+// to set a new token or HTTP client. opts are applied in the same way
+// AddProvider does.
+//
+// A new provider is started before the old one is shutdown. If new
+// provider cannot be started, the old one keeps working with its
+// settings. If both are offline providers with the same base
+// directory, a new one reuses an already downloaded database. Usage
+// stats of the provider are reset.
+//
+// Lookups which start after ReplaceProvider returns use a new provider.
+// ReplaceProvider waits until in-flight lookups which use the old one
+// are done before shutting it down.
+func (t *Topographer) ReplaceProvider(provider Provider, opts ...ProviderOption) error {
+	t.rwmutex.Lock()
+
+	if t.isClosed() {
+		t.rwmutex.Unlock()
+
+		return ErrTopographerShutdown
+	}
+
+	name := provider.Name()
+
+	if _, ok := t.providers[name]; !ok {
+		t.rwmutex.Unlock()
+
+		return fmt.Errorf("provider %s is unknown", name)
+	}
+
+	if err := t.reserveProvider(name); err != nil {
+		t.rwmutex.Unlock()
+
+		return err
+	}
+
+	settings := t.getProviderSettings(name)
+
+	t.rwmutex.Unlock()
+
+	for _, opt := range opts {
+		opt(&settings)
+	}
+
+	provider, stat, err := t.startProvider(provider, settings)
+
+	t.rwmutex.Lock()
+
+	delete(t.pendingProviders, name)
+
+	if err != nil {
+		t.rwmutex.Unlock()
+
+		return err
+	}
+
+	oldProvider, ok := t.providers[name]
+
+	switch {
+	case t.isClosed():
+		err = ErrTopographerShutdown
+	case !ok:
+		// provider was removed while a new one was starting.
+		err = fmt.Errorf("provider %s is unknown", name)
+	}
+
+	if err != nil {
+		t.rwmutex.Unlock()
+		shutdownProvider(provider)
+
+		return err
+	}
+
+	oldInflight := t.providerInflight[name]
+
+	t.registerProvider(name, provider, stat, settings)
+	t.invalidateCaches()
+	t.rwmutex.Unlock()
+
+	oldInflight.Wait()
+	shutdownProvider(oldProvider)
+
+	return nil
+}
+
+// reserveProvider marks that provider with a given name is being
+// started by AddProvider or ReplaceProvider, so nobody else can add or
+// replace it concurrently. It is expected that caller holds a write
+// lock.
+func (t *Topographer) reserveProvider(name string) error {
+	if _, ok := t.pendingProviders[name]; ok {
+		return fmt.Errorf("provider %s is being added or replaced", name)
+	}
+
+	t.pendingProviders[name] = struct{}{}
+
+	return nil
+}
+
+// getProviderSettings returns settings which are set for the provider
+// with a given name. It is expected that caller holds a lock.
+func (t *Topographer) getProviderSettings(name string) providerSettings {
+	return providerSettings{
+		voteWeight: t.getVoteWeight(name),
+		httpClient: t.httpClients[name],
+		timeout:    t.providerTimeouts[name],
+		tier:       t.providerTiers[name],
+	}
+}
+
+// startProvider makes usage stats of the provider and starts its
+// background updates if this is OfflineProvider. It does not touch a
+// state of Topographer, so it is called without a lock: opening of
+// offline database can take a while.
+func (t *Topographer) startProvider(provider Provider,
+	settings providerSettings) (Provider, *UsageStats, error) {
+	name := provider.Name()
+	stat := &UsageStats{
+		Name:       name,
+		voteWeight: settings.voteWeight,
+	}
+
+	if client, ok := settings.httpClient.(InspectableHTTPClient); ok {
+		stat.httpClient = client
+	}
+
+	if vv, ok := provider.(OfflineProvider); ok {
+		updater, err := newFsUpdater(vv, t.logger, stat, t.invalidateCaches)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot start provider %s: %w", name, err)
+		}
+
+		provider = updater
+	}
+
+	return provider, stat, nil
+}
+
+// registerProvider registers a started provider and its settings.
+// If provider with this name is registered already, it is overwritten.
+// It is expected that caller holds a write lock.
+func (t *Topographer) registerProvider(name string,
+	provider Provider,
+	stat *UsageStats,
+	settings providerSettings) {
+	t.providers[name] = provider
+	t.providerStats[name] = stat
+	t.providerInflight[name] = &sync.WaitGroup{}
+	t.voteWeights[name] = settings.voteWeight
+	t.providerTiers[name] = settings.tier
+
+	if settings.httpClient != nil {
+		t.httpClients[name] = settings.httpClient
+	} else {
+		delete(t.httpClients, name)
+	}
+
+	if settings.timeout > 0 {
+		t.providerTimeouts[name] = settings.timeout
+	} else {
+		delete(t.providerTimeouts, name)
+	}
+
+	t.setCircuitBreakerCallback(name)
+}
+
+// addProvider starts and registers a provider given to
+// NewTopographer.
+func (t *Topographer) addProvider(provider Provider) error {
+	name := provider.Name()
+
+	if _, ok := t.providers[name]; ok {
+		return fmt.Errorf("provider %s is already registered", name)
+	}
+
+	settings := t.getProviderSettings(name)
+
+	provider, stat, err := t.startProvider(provider, settings)
+	if err != nil {
+		return err
+	}
+
+	t.registerProvider(name, provider, stat, settings)
+
+	return nil
+}
+
+func shutdownProvider(provider Provider) {
+	if vv, ok := provider.(OfflineProvider); ok {
+		vv.Shutdown()
+	}
+}
+
+// Shutdown stops Topographer. It waits until in-flight lookups are
+// done and shuts down offline providers. New lookups fail with
+// ErrTopographerShutdown.
+func (t *Topographer) Shutdown() {
+	t.rwmutex.Lock()
+
+	atomic.StoreUint32(&t.closed, 1)
+
+	inflight := make([]*sync.WaitGroup, 0, len(t.providerInflight))
+
+	for _, v := range t.providerInflight {
+		inflight = append(inflight, v)
+	}
+
+	t.rwmutex.Unlock()
+
+	for _, v := range inflight {
+		v.Wait()
+	}
 
 	t.closeOnce.Do(func() {
 		t.workerPool.Release()
 
+		t.rwmutex.RLock()
+		defer t.rwmutex.RUnlock()
+
 		for _, v := range t.providers {
 			if vv, ok := v.(OfflineProvider); ok {
 				vv.Shutdown()
@@ -209,142 +786,419 @@ func (t *Topographer) resolveIP(args interface{}) {
 	params := args.(*resolveIPRequest)
 	defer params.wg.Done()
 
-	rv := make([]ResolveResultDetail, 0, len(params.providers))
-	taskChannel := make(chan ResolveResultDetail, len(params.providers))
-	wg := &sync.WaitGroup{}
+	providers, release := t.acquireProviders(params.providers)
+	result := t.resolveIPCoalesced(params.ctx, params.ip, providers, params.exactCacheKey)
 
-	wg.Add(len(params.providers))
+	release()
 
-	go func() {
-		wg.Wait()
-		close(taskChannel)
-	}()
+	select {
+	case <-params.ctx.Done():
+	case params.resultChannel <- result:
+	}
+}
+
+// resolveIPCoalesced resolves the IP with providers unless a result is
+// cached or the same IP is being resolved with the same providers
+// right now. In the latter case, it waits for the result of in-flight
+// resolving. If that resolving was interrupted, IP is resolved again.
+//
+// If exactCacheKey is set, result cache is keyed by the exact address
+// even if it aggregates addresses by prefixes.
+func (t *Topographer) resolveIPCoalesced(ctx context.Context,
+	ip net.IP,
+	providers []*providerLookup,
+	exactCacheKey bool) ResolveResult {
+	keyProviders := make([]Provider, len(providers))
 
-	for _, v := range params.providers {
-		go t.resolveIPLookup(params.ctx, params.ip, v, taskChannel, wg)
+	for i, v := range providers {
+		keyProviders[i] = v.provider
 	}
 
-	for res := range taskChannel {
-		rv = append(rv, res)
+	if t.resultCache != nil {
+		if result, ok := t.resultCache.get(ip, keyProviders, exactCacheKey); ok {
+			return result
+		}
 	}
 
-	select {
-	case <-params.ctx.Done():
-	case params.resultChannel <- t.resolveIPMerge(params.ip, rv):
+	result, ok := t.resolveGroup.do(ctx, makeResolveKey(ip, keyProviders), func() (ResolveResult, bool) {
+		result := t.resolveIPProviders(ctx, ip, providers, exactCacheKey)
+
+		return result, ctx.Err() == nil
+	})
+	if !ok && ctx.Err() == nil {
+		result = t.resolveIPProviders(ctx, ip, providers, exactCacheKey)
 	}
+
+	return result
 }
 
-func (t *Topographer) resolveIPLookup(ctx context.Context,
+// resolveIPProviders asks providers and merges their answers into a
+// verdict.
+//
+// Providers are asked tier by tier (see WithProviderTier): if a verdict
+// made by providers of a tier is confident enough, the next tiers are
+// not asked. Providers of the same tier are asked concurrently.
+func (t *Topographer) resolveIPProviders(ctx context.Context,
 	ip net.IP,
-	provider Provider,
-	taskChannel chan<- ResolveResultDetail,
-	wg *sync.WaitGroup) {
-	defer wg.Done()
+	providersToUse []*providerLookup,
+	exactCacheKey bool) ResolveResult {
+	var (
+		lookupCtx context.Context
+		cancel    context.CancelFunc
+	)
+
+	if t.lookupDeadline > 0 {
+		lookupCtx, cancel = context.WithTimeout(ctx, t.lookupDeadline)
+	} else {
+		lookupCtx, cancel = context.WithCancel(ctx)
+	}
 
-	detail := ResolveResultDetail{
-		ProviderName: provider.Name(),
+	defer cancel()
+
+	capableProviders := make([]*providerLookup, 0, len(providersToUse))
+
+	for _, v := range providersToUse {
+		if getCapabilities(v.provider).SupportsIP(ip) {
+			capableProviders = append(capableProviders, v)
+		}
 	}
-	stat := t.providerStats[provider.Name()]
 
-	if res, err := provider.Lookup(ctx, ip); err != nil {
-		stat.notifyUsed(err)
-		t.logger.LookupError(ip, provider.Name(), err)
-	} else {
-		detail.City = res.City
-		detail.CountryCode = res.CountryCode
-		stat.notifyUsed(nil)
+	tiers := t.groupByTiers(capableProviders)
+	rv := []ResolveResultDetail{}
+
+	for idx, v := range tiers {
+		rv = append(rv, t.resolveIPTier(lookupCtx, ip, v)...)
+
+		if idx == len(tiers)-1 || lookupCtx.Err() != nil || t.isConfident(ip, rv) {
+			break
+		}
 	}
 
-	select {
-	case <-ctx.Done():
-	case taskChannel <- detail:
+	result := t.mergeDetails(ip, rv)
+	result.AddressType = AddressTypePublic
+
+	t.agreementStats.notifyResult(&result, rv)
+
+	// results of interrupted resolving are incomplete. The same is for
+	// verdicts made without providers which have timed out.
+	if t.resultCache != nil && ctx.Err() == nil && !hasTimedOutDetails(rv) {
+		keyProviders := make([]Provider, len(providersToUse))
+
+		for i, v := range providersToUse {
+			keyProviders[i] = v.provider
+		}
+
+		t.resultCache.set(ip, keyProviders, exactCacheKey, result)
 	}
+
+	return result
 }
 
-func (t *Topographer) resolveIPMerge(ip net.IP, results []ResolveResultDetail) ResolveResult {
-	countries := map[CountryCode][]*ResolveResultDetail{}
+func hasTimedOutDetails(details []ResolveResultDetail) bool {
+	for i := range details {
+		if details[i].TimedOut {
+			return true
+		}
+	}
 
-	for i := range results {
-		current := &results[i]
+	return false
+}
 
-		if !current.CountryCode.Known() {
-			continue
+// resolveIPTier asks providers of the same tier concurrently.
+//
+// Usually, it waits for all providers. But it returns without
+// stragglers if lookup deadline is reached, if provider has exceeded
+// its soft timeout or if early verdict is possible (see
+// WithEarlyVerdict). Stragglers are marked as timed out in details and
+// their lookups are cancelled.
+//
+// Usage stats are updated here, only for those providers whose
+// answers are taken: so each lookup is counted only once, either by
+// its result or as timed out. Lookups which are interrupted because
+// request is cancelled are not counted.
+func (t *Topographer) resolveIPTier(ctx context.Context,
+	ip net.IP,
+	providers []*providerLookup) []ResolveResultDetail {
+	tierCtx, cancel := context.WithCancel(ctx)
+
+	defer cancel()
+
+	rv := make([]ResolveResultDetail, 0, len(providers))
+	pending := make(map[string]*providerLookup, len(providers))
+	cancels := make(map[string]context.CancelFunc, len(providers))
+	taskChannel := make(chan providerLookupResult, len(providers))
+	timeoutChannel := make(chan string, len(providers))
+	wg := &sync.WaitGroup{}
+
+	wg.Add(len(providers))
+
+	go func() {
+		wg.Wait()
+		close(taskChannel)
+	}()
+
+	for _, v := range providers {
+		name := v.Name()
+		pending[name] = v
+
+		if v.timeout > 0 {
+			timer := time.AfterFunc(v.timeout, func() {
+				timeoutChannel <- name
+			})
+			defer timer.Stop()
 		}
 
-		arr, ok := countries[current.CountryCode]
+		lookupCtx, lookupCancel := context.WithCancel(tierCtx)
+		cancels[name] = lookupCancel
 
-		if !ok {
-			arr = []*ResolveResultDetail{}
-			countries[current.CountryCode] = arr
+		v.inflight.Add(1)
+
+		go t.resolveIPLookup(lookupCtx, ip, v, taskChannel, wg)
+	}
+
+	started := time.Now()
+
+	for len(pending) > 0 && !t.canMakeEarlyVerdict(rv, providers) {
+		select {
+		case <-tierCtx.Done():
+			// deadline exceeded means that everyone who has not
+			// answered yet is too slow. Otherwise request was
+			// cancelled.
+			if errors.Is(tierCtx.Err(), context.DeadlineExceeded) {
+				for name := range pending {
+					rv = append(rv, t.timeoutLookup(ip, name, pending, started))
+				}
+			}
+
+			pending = nil
+		case name := <-timeoutChannel:
+			if _, ok := pending[name]; ok {
+				cancels[name]()
+				rv = append(rv, t.timeoutLookup(ip, name, pending, started))
+			}
+		case res := <-taskChannel:
+			if provider, ok := pending[res.detail.ProviderName]; ok {
+				delete(pending, res.detail.ProviderName)
+				t.notifyLookup(ip, provider, res)
+				rv = append(rv, res.detail)
+			}
 		}
+	}
 
-		countries[current.CountryCode] = append(arr, current)
+	// these are stragglers which were cut off by early verdict.
+	for name := range pending {
+		rv = append(rv, t.timeoutLookup(ip, name, pending, started))
 	}
 
-	var cityResults []*ResolveResultDetail
-	var selectedCountry CountryCode
+	return rv
+}
 
-	maxLen := 0
+// groupByTiers splits providers into tiers ordered by their priority.
+func (t *Topographer) groupByTiers(providers []*providerLookup) [][]*providerLookup {
+	groups := map[int][]*providerLookup{}
+	tiers := []int{}
 
-	for country, group := range countries {
-		if len(group) > maxLen {
-			cityResults = group
-			selectedCountry = country
-			maxLen = len(group)
+	for _, v := range providers {
+		if _, ok := groups[v.tier]; !ok {
+			tiers = append(tiers, v.tier)
 		}
-	}
 
-	rv := ResolveResult{
-		IP:      ip.To16(),
-		Details: results,
-		City:    t.resolveIPMergeCity(cityResults),
+		groups[v.tier] = append(groups[v.tier], v)
 	}
 
-	if selectedCountry.Known() {
-		details := selectedCountry.Details()
-		rv.Country.Alpha2Code = details.Alpha2
-		rv.Country.Alpha3Code = details.Alpha3
-		rv.Country.CommonName = details.Name.Common
-		rv.Country.OfficialName = details.Name.Official
+	sort.Ints(tiers)
+
+	rv := make([][]*providerLookup, 0, len(tiers))
+
+	for _, v := range tiers {
+		rv = append(rv, groups[v])
 	}
 
 	return rv
 }
 
-func (t *Topographer) resolveIPMergeCity(results []*ResolveResultDetail) string {
-	counters := make(map[string]int)
-	names := make(map[string]string)
+// isConfident checks if a verdict made out of given details is good
+// enough to skip the next tiers of providers.
+func (t *Topographer) isConfident(ip net.IP, details []ResolveResultDetail) bool {
+	result := t.mergeDetails(ip, details)
+
+	return result.Country.Alpha2Code != "" &&
+		result.CountryConfidence >= t.tierConfidence
+}
+
+func (t *Topographer) mergeDetails(ip net.IP, details []ResolveResultDetail) ResolveResult {
+	sort.Slice(details, func(i, j int) bool {
+		return details[i].ProviderName < details[j].ProviderName
+	})
+
+	return t.merger.Merge(ip, details)
+}
+
+// timeoutLookup makes a detail of the provider which is too slow and
+// removes it from pending ones.
+func (t *Topographer) timeoutLookup(ip net.IP,
+	name string,
+	pending map[string]*providerLookup,
+	started time.Time) ResolveResultDetail {
+	provider := pending[name]
+
+	delete(pending, name)
+	provider.stat.notifyUsed(ErrLookupTimedOut, time.Since(started))
+	t.logger.LookupError(ip, name, ErrLookupTimedOut)
+
+	return t.makeTimedOutDetail(provider)
+}
+
+func (t *Topographer) notifyLookup(ip net.IP, provider *providerLookup, res providerLookupResult) {
+	if res.cached {
+		return
+	}
+
+	provider.stat.notifyUsed(res.err, res.elapsed)
+
+	if res.err != nil {
+		t.logger.LookupError(ip, provider.Name(), res.err)
+	}
+}
+
+func (t *Topographer) makeTimedOutDetail(provider *providerLookup) ResolveResultDetail {
+	return ResolveResultDetail{
+		ProviderName: provider.Name(),
+		VoteWeight:   provider.stat.VoteWeight(),
+		Capabilities: getCapabilities(provider.provider),
+		TimedOut:     true,
+	}
+}
+
+// canMakeEarlyVerdict checks if a verdict can be made without waiting
+// for other providers: either enough providers agree on a country or
+// all offline providers have answered.
+func (t *Topographer) canMakeEarlyVerdict(details []ResolveResultDetail, providers []*providerLookup) bool {
+	if !t.earlyVerdict {
+		return false
+	}
+
+	answered := make(map[string]struct{}, len(details))
+	votes := map[CountryCode]int{}
 
-	for _, v := range results {
-		if v.City == "" {
+	for _, v := range details {
+		if v.TimedOut {
 			continue
 		}
 
-		normalizedCityName, _ := matchr.DoubleMetaphone(v.City)
+		answered[v.ProviderName] = struct{}{}
+
+		if v.CountryCode.Known() {
+			votes[v.CountryCode]++
+
+			if t.earlyVerdictQuorum > 0 && votes[v.CountryCode] >= t.earlyVerdictQuorum {
+				return true
+			}
+		}
+	}
+
+	hasOffline := false
+
+	for _, v := range providers {
+		if _, ok := v.provider.(OfflineProvider); !ok {
+			continue
+		}
+
+		if _, ok := answered[v.Name()]; !ok {
+			return false
+		}
+
+		hasOffline = true
+	}
+
+	return hasOffline
+}
 
-		counters[normalizedCityName] += 1
-		names[normalizedCityName] = v.City
+func (t *Topographer) resolveIPLookup(ctx context.Context,
+	ip net.IP,
+	lookup *providerLookup,
+	taskChannel chan<- providerLookupResult,
+	wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer lookup.inflight.Done()
+
+	provider := lookup.provider
+	rv := providerLookupResult{
+		detail: ResolveResultDetail{
+			ProviderName: provider.Name(),
+			VoteWeight:   lookup.stat.VoteWeight(),
+			Capabilities: getCapabilities(provider),
+		},
+	}
+
+	// provider has already told that it knows nothing about this IP,
+	// there is no need to ask again.
+	if t.negativeCache != nil && t.negativeCache.get(ip, provider.Name()) != nil {
+		rv.cached = true
+		taskChannel <- rv
+
+		return
 	}
 
-	maxLen := 0
-	cityName := ""
+	started := time.Now()
+	res, err := provider.Lookup(ctx, ip)
+
+	// if context is closed, nobody waits for this lookup anymore: it
+	// is either cancelled or marked as timed out.
+	if ctx.Err() != nil {
+		return
+	}
 
-	for k, v := range counters {
-		if v > maxLen {
-			cityName = names[k]
-			maxLen = v
+	rv.err = err
+	rv.elapsed = time.Since(started)
+
+	if err != nil {
+		if t.negativeCache != nil {
+			t.negativeCache.set(ip, provider.Name(), err)
 		}
+	} else {
+		rv.detail.City = res.City
+		rv.detail.CountryCode = res.CountryCode
+		rv.detail.Region = res.Region
+		rv.detail.Location = res.Location
+		rv.detail.TimeZone = res.TimeZone
+		rv.detail.PostalCode = res.PostalCode
+		rv.detail.ASN = res.ASN
+		rv.detail.Organization = res.Organization
 	}
 
-	return cityName
+	taskChannel <- rv
 }
 
 // NewTopographer creates a new instance of topographer.
-func NewTopographer(providers []Provider, logger Logger, workerPoolSize int) (*Topographer, error) {
+//
+// opts is a list of optional settings. Please see functions which
+// return Option to get a list of them.
+func NewTopographer(providers []Provider,
+	logger Logger,
+	workerPoolSize int,
+	opts ...Option) (*Topographer, error) {
 	rv := &Topographer{
-		logger:        logger,
-		providers:     map[string]Provider{},
-		providerStats: map[string]*UsageStats{},
+		logger:                 logger,
+		providers:              map[string]Provider{},
+		providerStats:          map[string]*UsageStats{},
+		providerInflight:       map[string]*sync.WaitGroup{},
+		pendingProviders:       map[string]struct{}{},
+		voteWeights:            map[string]float64{},
+		httpClients:            map[string]HTTPClient{},
+		httpStats:              newHTTPStats(),
+		agreementStats:         newAgreementStats(),
+		resolveGroup:           newResolveGroup(),
+		providerTimeouts:       map[string]time.Duration{},
+		providerTiers:          map[string]int{},
+		tierConfidence:         DefaultTierConfidence,
+		merger:                 NewMajorityMerger(),
+		readyMinProviders:      DefaultReadyMinProviders,
+		readyRequiredProviders: map[string]struct{}{},
+	}
+
+	for _, opt := range opts {
+		opt(rv)
 	}
 
 	poolSize := workerPoolSize
@@ -356,23 +1210,25 @@ func NewTopographer(providers []Provider, logger Logger, workerPoolSize int) (*T
 		ants.WithExpiryDuration(workerPoolExpireTime))
 
 	for _, v := range providers {
-		stat := &UsageStats{
-			Name: v.Name(),
+		if err := rv.addProvider(v); err != nil {
+			rv.Shutdown()
+
+			return nil, err
 		}
-		rv.providerStats[v.Name()] = stat
+	}
 
-		if vv, ok := v.(OfflineProvider); ok {
-			updater, err := newFsUpdater(vv, logger, stat)
-			if err != nil {
-				rv.Shutdown()
+	if len(rv.trustedProxies) > 0 && getHTTPForwardedHeader(rv.trustedProxyHeader) == nil {
+		rv.Shutdown()
 
-				return nil, fmt.Errorf("cannot start provider %s: %w", v.Name(), err)
-			}
+		return nil, fmt.Errorf("unknown header of trusted proxies %q", rv.trustedProxyHeader)
+	}
 
-			v = updater
-		}
+	for name := range rv.readyRequiredProviders {
+		if _, ok := rv.providers[name]; !ok {
+			rv.Shutdown()
 
-		rv.providers[v.Name()] = v
+			return nil, fmt.Errorf("required provider %s is unknown", name)
+		}
 	}
 
 	return rv, nil
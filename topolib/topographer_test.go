@@ -5,8 +5,13 @@ import (
 	"errors"
 	"io/ioutil"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -78,7 +83,7 @@ func (suite *TopographerTestSuite) TearDownTest() {
 func (suite *TopographerTestSuite) TestResolveShutdown() {
 	suite.t.Shutdown()
 
-	ip := net.ParseIP("127.0.0.1")
+	ip := net.ParseIP("80.80.80.80")
 	res, err := suite.t.Resolve(context.Background(), ip, nil)
 
 	suite.True(errors.Is(err, topolib.ErrTopographerShutdown))
@@ -90,7 +95,7 @@ func (suite *TopographerTestSuite) TestResolveGivenCtxClosed() {
 
 	cancel()
 
-	ip := net.ParseIP("127.0.0.1")
+	ip := net.ParseIP("80.80.80.80")
 	res, err := suite.t.Resolve(ctx, ip, nil)
 
 	suite.NoError(err)
@@ -98,7 +103,7 @@ func (suite *TopographerTestSuite) TestResolveGivenCtxClosed() {
 }
 
 func (suite *TopographerTestSuite) TestResolveUnknownProvider() {
-	ip := net.ParseIP("127.0.0.1")
+	ip := net.ParseIP("80.80.80.80")
 	res, err := suite.t.Resolve(context.Background(), ip, []string{"o0", "u"})
 
 	suite.EqualError(err, "provider u is unknown")
@@ -108,7 +113,7 @@ func (suite *TopographerTestSuite) TestResolveUnknownProvider() {
 func (suite *TopographerTestSuite) TestResolveAllShutdown() {
 	suite.t.Shutdown()
 
-	ip := net.ParseIP("127.0.0.1")
+	ip := net.ParseIP("80.80.80.80")
 	res, err := suite.t.ResolveAll(context.Background(), []net.IP{ip}, nil)
 
 	suite.True(errors.Is(err, topolib.ErrTopographerShutdown))
@@ -120,13 +125,1212 @@ func (suite *TopographerTestSuite) TestResolveAllGivenCtxClosed() {
 
 	cancel()
 
-	ip := net.ParseIP("127.0.0.1")
+	ip := net.ParseIP("80.80.80.80")
 	res, err := suite.t.ResolveAll(ctx, []net.IP{ip}, nil)
 
 	suite.NoError(err)
 	suite.Empty(res)
 }
 
+func (suite *TopographerTestSuite) TestResolveCustomMerger() {
+	mergerMock := &MergerMock{}
+	ip := net.ParseIP("80.80.80.80").To16()
+	result := topolib.ResolveResult{City: "Atlantis"}
+	providers := []topolib.Provider{}
+
+	for _, v := range suite.providerMocks {
+		v.On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+			CountryCode: topolib.Alpha2ToCountryCode("RU"),
+		}, nil).Once()
+
+		providers = append(providers, v)
+	}
+
+	mergerMock.On("Merge", ip, mock.Anything).Return(result).Once().Run(func(args mock.Arguments) {
+		details := args.Get(1).([]topolib.ResolveResultDetail)
+
+		suite.Len(details, 2)
+		suite.Equal("p0", details[0].ProviderName)
+		suite.Equal("p1", details[1].ProviderName)
+	})
+
+	topo, err := topolib.NewTopographer(providers, suite.logMock, 10,
+		topolib.WithMerger(mergerMock))
+
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	res, err := topo.Resolve(context.Background(), ip, nil)
+
+	suite.NoError(err)
+	suite.Equal("Atlantis", res.City)
+	mergerMock.AssertExpectations(suite.T())
+}
+
+func (suite *TopographerTestSuite) TestResolveVoteWeights() {
+	ip := net.ParseIP("80.80.80.80").To16()
+	providers := []topolib.Provider{}
+	countries := []string{"DE", "NL"}
+
+	for idx, v := range suite.providerMocks {
+		v.On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+			CountryCode: topolib.Alpha2ToCountryCode(countries[idx]),
+		}, nil).Once()
+
+		providers = append(providers, v)
+	}
+
+	topo, err := topolib.NewTopographer(providers, suite.logMock, 10,
+		topolib.WithVoteWeight("p1", 2.5))
+
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	res, err := topo.Resolve(context.Background(), ip, nil)
+
+	suite.NoError(err)
+	suite.Equal("NL", res.Country.Alpha2Code)
+	suite.Equal(topolib.DefaultVoteWeight, res.Details[0].VoteWeight)
+	suite.Equal(2.5, res.Details[1].VoteWeight)
+
+	stats := topo.UsageStats()
+
+	suite.Equal(topolib.DefaultVoteWeight, stats[0].VoteWeight())
+	suite.Equal(2.5, stats[1].VoteWeight())
+}
+
+func (suite *TopographerTestSuite) TestResolveSkipIncapable() {
+	ip := net.ParseIP("2a00:1450:4001::1").To16()
+	capableMock := &CapableProviderMock{}
+
+	capableMock.On("Name").Return("c0").Maybe()
+	capableMock.On("Capabilities").Return(topolib.CapabilityIPv4 | topolib.CapabilityCountry)
+
+	suite.providerMocks[0].On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("DE"),
+		City:        "Berlin",
+	}, nil).Once()
+
+	topo, err := topolib.NewTopographer([]topolib.Provider{
+		suite.providerMocks[0],
+		capableMock,
+	}, suite.logMock, 10)
+
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	res, err := topo.Resolve(context.Background(), ip, nil)
+
+	suite.NoError(err)
+	suite.Equal("DE", res.Country.Alpha2Code)
+	suite.Equal(topolib.AddressTypePublic, res.AddressType)
+	suite.Len(res.Details, 1)
+	suite.Equal("p0", res.Details[0].ProviderName)
+	suite.Equal(0, res.Abstainers)
+
+	capableMock.AssertNotCalled(suite.T(), "Lookup", mock.Anything, mock.Anything)
+}
+
+func (suite *TopographerTestSuite) TestResolveSpecial() {
+	ip := net.ParseIP("10.0.0.1")
+	res, err := suite.t.Resolve(context.Background(), ip, nil)
+
+	suite.NoError(err)
+	suite.True(res.OK())
+	suite.True(res.Special())
+	suite.Equal(topolib.AddressTypePrivate, res.AddressType)
+	suite.Empty(res.Country.Alpha2Code)
+	suite.Empty(res.Details)
+
+	for _, v := range suite.providerMocks {
+		v.AssertNotCalled(suite.T(), "Lookup", mock.Anything, mock.Anything)
+	}
+}
+
+func (suite *TopographerTestSuite) TestResolveAllSpecial() {
+	ips := []net.IP{
+		net.ParseIP("fe80::1"),
+		net.ParseIP("127.0.0.1"),
+		net.ParseIP("100.64.1.1"),
+	}
+	res, err := suite.t.ResolveAll(context.Background(), ips, nil)
+
+	suite.NoError(err)
+	suite.Len(res, 3)
+	suite.Equal(topolib.AddressTypeLinkLocal, res[0].AddressType)
+	suite.Equal(topolib.AddressTypeLoopback, res[1].AddressType)
+	suite.Equal(topolib.AddressTypeShared, res[2].AddressType)
+
+	for _, v := range suite.providerMocks {
+		v.AssertNotCalled(suite.T(), "Lookup", mock.Anything, mock.Anything)
+	}
+}
+
+func (suite *TopographerTestSuite) TestResolveStreamShutdown() {
+	suite.t.Shutdown()
+
+	_, err := suite.t.ResolveStream(context.Background(), make(chan net.IP), nil)
+
+	suite.True(errors.Is(err, topolib.ErrTopographerShutdown))
+}
+
+func (suite *TopographerTestSuite) TestResolveStreamUnknownProvider() {
+	_, err := suite.t.ResolveStream(context.Background(), make(chan net.IP), []string{"u"})
+
+	suite.EqualError(err, "provider u is unknown")
+}
+
+func (suite *TopographerTestSuite) TestResolveStream() {
+	ip := net.ParseIP("80.80.80.80").To16()
+	ips := make(chan net.IP, 2)
+
+	suite.providerMocks[0].On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("NL"),
+		City:        "Amsterdam",
+	}, nil).Once()
+
+	ips <- ip
+	ips <- net.ParseIP("10.0.0.1")
+	close(ips)
+
+	results, err := suite.t.ResolveStream(context.Background(), ips, []string{"p0"})
+
+	suite.NoError(err)
+
+	collected := map[string]topolib.ResolveResult{}
+
+	for res := range results {
+		collected[res.IP.String()] = res
+	}
+
+	suite.Len(collected, 2)
+	suite.Equal("Amsterdam", collected["80.80.80.80"].City)
+	suite.Equal(topolib.AddressTypePrivate, collected["10.0.0.1"].AddressType)
+}
+
+func (suite *TopographerTestSuite) TestResolveStreamCancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	ips := make(chan net.IP)
+
+	results, err := suite.t.ResolveStream(ctx, ips, []string{"p0"})
+
+	suite.NoError(err)
+
+	cancel()
+
+	for range results {
+	}
+}
+
+func (suite *TopographerTestSuite) TestResolvePrefixShutdown() {
+	suite.t.Shutdown()
+
+	_, prefix, _ := net.ParseCIDR("80.80.80.0/24")
+	_, err := suite.t.ResolvePrefix(context.Background(), prefix, nil)
+
+	suite.True(errors.Is(err, topolib.ErrTopographerShutdown))
+}
+
+func (suite *TopographerTestSuite) TestResolvePrefixUnknownProvider() {
+	_, prefix, _ := net.ParseCIDR("80.80.80.0/24")
+	_, err := suite.t.ResolvePrefix(context.Background(), prefix, []string{"u"})
+
+	suite.EqualError(err, "provider u is unknown")
+}
+
+func (suite *TopographerTestSuite) TestResolvePrefixTooLarge() {
+	for _, v := range []string{"80.0.0.0/8", "2a00::/16"} {
+		_, prefix, _ := net.ParseCIDR(v)
+		_, err := suite.t.ResolvePrefix(context.Background(), prefix, nil)
+
+		suite.True(errors.Is(err, topolib.ErrPrefixTooLarge), v)
+	}
+}
+
+func (suite *TopographerTestSuite) TestResolvePrefix() {
+	_, prefix, _ := net.ParseCIDR("80.80.80.0/24")
+	_, network1, _ := net.ParseCIDR("80.80.0.0/17")
+	_, network2, _ := net.ParseCIDR("80.80.80.128/26")
+	prefixMock := &PrefixProviderMock{}
+
+	prefixMock.On("Name").Return("x0").Maybe()
+	prefixMock.On("Networks", mock.Anything, prefix).Return([]*net.IPNet{network1, network2}, nil).Once()
+
+	for city, ip := range map[string]string{
+		"Amsterdam": "80.80.80.0",
+		"Rotterdam": "80.80.80.128",
+		"Utrecht":   "80.80.80.192",
+	} {
+		prefixMock.On("Lookup", mock.Anything, net.ParseIP(ip).To16()).Return(topolib.ProviderLookupResult{
+			CountryCode: topolib.Alpha2ToCountryCode("NL"),
+			City:        city,
+		}, nil).Once()
+	}
+
+	topo, err := topolib.NewTopographer([]topolib.Provider{
+		suite.providerMocks[0],
+		prefixMock,
+	}, suite.logMock, 10)
+
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	res, err := topo.ResolvePrefix(context.Background(), prefix, nil)
+
+	suite.NoError(err)
+	suite.Len(res, 3)
+	suite.Equal("80.80.80.0/25", res[0].Prefix.String())
+	suite.Equal("Amsterdam", res[0].City)
+	suite.Equal("80.80.80.128/26", res[1].Prefix.String())
+	suite.Equal("Rotterdam", res[1].City)
+	suite.Equal("80.80.80.192/26", res[2].Prefix.String())
+	suite.Equal("Utrecht", res[2].City)
+
+	for _, v := range res {
+		suite.Len(v.Details, 1)
+		suite.Equal("x0", v.Details[0].ProviderName)
+	}
+
+	prefixMock.AssertExpectations(suite.T())
+	suite.providerMocks[0].AssertNotCalled(suite.T(), "Lookup", mock.Anything, mock.Anything)
+}
+
+func (suite *TopographerTestSuite) TestResolvePrefixSpecial() {
+	_, prefix, _ := net.ParseCIDR("192.0.0.0/23")
+	ip := net.ParseIP("192.0.1.0").To16()
+	prefixMock := &PrefixProviderMock{}
+
+	prefixMock.On("Name").Return("x0").Maybe()
+	prefixMock.On("Networks", mock.Anything, prefix).Return([]*net.IPNet{}, nil).Once()
+	prefixMock.On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("US"),
+	}, nil).Once()
+
+	topo, err := topolib.NewTopographer([]topolib.Provider{prefixMock}, suite.logMock, 10)
+
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	res, err := topo.ResolvePrefix(context.Background(), prefix, nil)
+
+	suite.NoError(err)
+	suite.Len(res, 2)
+	suite.Equal("192.0.0.0/24", res[0].Prefix.String())
+	suite.Equal(topolib.AddressTypeReserved, res[0].AddressType)
+	suite.Equal("192.0.1.0/24", res[1].Prefix.String())
+	suite.Equal(topolib.AddressTypePublic, res[1].AddressType)
+	suite.Equal("US", res[1].Country.Alpha2Code)
+
+	prefixMock.AssertExpectations(suite.T())
+}
+
+func (suite *TopographerTestSuite) TestResolvePrefixProviderTooLarge() {
+	_, prefix, _ := net.ParseCIDR("80.80.0.0/16")
+	prefixMock := &PrefixProviderMock{}
+
+	prefixMock.On("Name").Return("x0").Maybe()
+	prefixMock.On("Networks", mock.Anything, prefix).Return([]*net.IPNet(nil), topolib.ErrPrefixTooLarge).Once()
+
+	topo, err := topolib.NewTopographer([]topolib.Provider{prefixMock}, suite.logMock, 10)
+
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	_, err = topo.ResolvePrefix(context.Background(), prefix, nil)
+
+	suite.True(errors.Is(err, topolib.ErrPrefixTooLarge))
+	prefixMock.AssertExpectations(suite.T())
+}
+
+func (suite *TopographerTestSuite) TestAgreementStats() {
+	ip := net.ParseIP("80.80.80.80").To16()
+	providers := []topolib.Provider{}
+	countries := []string{"DE", "NL"}
+
+	for idx, v := range suite.providerMocks {
+		v.On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+			CountryCode: topolib.Alpha2ToCountryCode(countries[idx]),
+			City:        "Amsterdam",
+		}, nil).Once()
+
+		providers = append(providers, v)
+	}
+
+	topo, err := topolib.NewTopographer(providers, suite.logMock, 10,
+		topolib.WithVoteWeight("p1", 2.5))
+
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	_, err = topo.Resolve(context.Background(), ip, nil)
+
+	suite.NoError(err)
+	suite.Equal(topolib.AgreementStats{
+		Providers: []topolib.ProviderAgreement{
+			{Name: "p0", CountryDissented: 1, CityDissented: 1},
+			{Name: "p1", CountryAgreed: 1, CityAgreed: 1},
+		},
+		CountryDisagreements: []topolib.ProviderPairDisagreement{
+			{ProviderA: "p0", ProviderB: "p1", Compared: 1, Disagreed: 1},
+		},
+	}, topo.AgreementStats())
+}
+
+func (suite *TopographerTestSuite) TestResolveResultCache() {
+	var lookups int32
+
+	suite.providerMocks[0].On("Lookup", mock.Anything, mock.Anything).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("RU"),
+		City:        "Moscow",
+	}, nil).Run(func(_ mock.Arguments) {
+		atomic.AddInt32(&lookups, 1)
+	})
+
+	topo, err := topolib.NewTopographer(
+		[]topolib.Provider{suite.providerMocks[0]}, suite.logMock, 10,
+		topolib.WithResultCache(100, time.Minute, 24, 48))
+
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	result, err := topo.Resolve(context.Background(), net.ParseIP("80.80.80.80"), nil)
+
+	suite.NoError(err)
+	suite.Equal("RU", result.Country.Alpha2Code)
+
+	ip := net.ParseIP("80.80.80.81")
+
+	// ristretto is eventually consistent
+	suite.Eventually(func() bool {
+		before := atomic.LoadInt32(&lookups)
+		result, err := topo.Resolve(context.Background(), ip, nil)
+
+		return err == nil &&
+			result.IP.Equal(ip) &&
+			result.Country.Alpha2Code == "RU" &&
+			atomic.LoadInt32(&lookups) == before
+	}, time.Second, 10*time.Millisecond)
+
+	before := atomic.LoadInt32(&lookups)
+	_, err = topo.Resolve(context.Background(), net.ParseIP("80.80.81.80"), nil)
+
+	suite.NoError(err)
+	suite.EqualValues(before+1, atomic.LoadInt32(&lookups))
+}
+
+func (suite *TopographerTestSuite) TestResolvePrefixResultCache() {
+	_, prefix, _ := net.ParseCIDR("80.80.80.0/24")
+	_, network1, _ := net.ParseCIDR("80.80.80.0/25")
+	_, network2, _ := net.ParseCIDR("80.80.80.128/25")
+	prefixMock := &PrefixProviderMock{}
+
+	prefixMock.On("Name").Return("x0").Maybe()
+	prefixMock.On("Networks", mock.Anything, prefix).Return([]*net.IPNet{network1, network2}, nil)
+	prefixMock.On("Lookup", mock.Anything, net.ParseIP("80.80.80.0").To16()).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("NL"),
+	}, nil)
+	prefixMock.On("Lookup", mock.Anything, net.ParseIP("80.80.80.128").To16()).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("DE"),
+	}, nil)
+
+	topo, err := topolib.NewTopographer([]topolib.Provider{prefixMock}, suite.logMock, 10,
+		topolib.WithResultCache(1000, time.Minute, 24, 48))
+
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	// this verdict is cached for the whole 80.80.80.0/24
+	result, err := topo.Resolve(context.Background(), net.ParseIP("80.80.80.128"), nil)
+
+	suite.NoError(err)
+	suite.Equal("DE", result.Country.Alpha2Code)
+
+	// ristretto is eventually consistent
+	suite.Eventually(func() bool {
+		result, err := topo.Resolve(context.Background(), net.ParseIP("80.80.80.1"), nil)
+
+		return err == nil && result.Country.Alpha2Code == "DE"
+	}, time.Second, 10*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		res, err := topo.ResolvePrefix(context.Background(), prefix, nil)
+
+		suite.NoError(err)
+		suite.Len(res, 2)
+		suite.Equal("80.80.80.0/25", res[0].Prefix.String())
+		suite.Equal("NL", res[0].Country.Alpha2Code)
+		suite.Equal("80.80.80.128/25", res[1].Prefix.String())
+		suite.Equal("DE", res[1].Country.Alpha2Code)
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (suite *TopographerTestSuite) TestResolveAllCoalesced() {
+	ip := net.ParseIP("80.80.80.80").To16()
+
+	suite.providerMocks[0].On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("RU"),
+	}, nil).After(100 * time.Millisecond).Once()
+
+	topo, err := topolib.NewTopographer(
+		[]topolib.Provider{suite.providerMocks[0]}, suite.logMock, 10)
+
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	results, err := topo.ResolveAll(context.Background(), []net.IP{ip, ip, ip}, nil)
+
+	suite.NoError(err)
+	suite.Len(results, 3)
+
+	for _, v := range results {
+		suite.Equal("RU", v.Country.Alpha2Code)
+	}
+}
+
+func (suite *TopographerTestSuite) TestResolveNegativeCache() {
+	var lookups int32
+
+	suite.providerMocks[0].On("Lookup", mock.Anything, mock.Anything).
+		Return(topolib.ProviderLookupResult{}, topolib.ErrIPNotFound).
+		Run(func(_ mock.Arguments) {
+			atomic.AddInt32(&lookups, 1)
+		})
+
+	topo, err := topolib.NewTopographer(
+		[]topolib.Provider{suite.providerMocks[0]}, suite.logMock, 10,
+		topolib.WithNegativeCache(100, time.Minute))
+
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	ip := net.ParseIP("80.80.80.80")
+
+	_, err = topo.Resolve(context.Background(), ip, nil)
+
+	suite.NoError(err)
+
+	// ristretto is eventually consistent
+	suite.Eventually(func() bool {
+		before := atomic.LoadInt32(&lookups)
+		result, err := topo.Resolve(context.Background(), ip, nil)
+
+		return err == nil &&
+			len(result.Details) == 1 &&
+			atomic.LoadInt32(&lookups) == before
+	}, time.Second, 10*time.Millisecond)
+}
+
+func (suite *TopographerTestSuite) TestAddProvider() {
+	ip := net.ParseIP("80.80.80.80").To16()
+	providerMock := &ProviderMock{}
+
+	providerMock.On("Name").Return("p2")
+	providerMock.On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("RU"),
+	}, nil).Once()
+
+	defer providerMock.AssertExpectations(suite.T())
+
+	_, err := suite.t.Resolve(context.Background(), ip, []string{"p2"})
+
+	suite.EqualError(err, "provider p2 is unknown")
+	suite.NoError(suite.t.AddProvider(providerMock))
+	suite.EqualError(suite.t.AddProvider(suite.providerMocks[0]),
+		"provider p0 is already registered")
+
+	result, err := suite.t.Resolve(context.Background(), ip, []string{"p2"})
+
+	suite.NoError(err)
+	suite.Equal("RU", result.Country.Alpha2Code)
+	suite.Len(suite.t.UsageStats(), 4)
+}
+
+func (suite *TopographerTestSuite) TestAddProviderDuplicateKeepsSettings() {
+	ip := net.ParseIP("80.80.80.80").To16()
+
+	suite.providerMocks[0].On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("RU"),
+	}, nil).Once()
+
+	suite.Error(suite.t.AddProvider(suite.providerMocks[0],
+		topolib.ProviderVoteWeight(5),
+		topolib.ProviderTimeout(time.Nanosecond)))
+
+	result, err := suite.t.Resolve(context.Background(), ip, []string{"p0"})
+
+	suite.NoError(err)
+	suite.Equal("RU", result.Country.Alpha2Code)
+	suite.False(result.Details[0].TimedOut)
+	suite.InEpsilon(topolib.DefaultVoteWeight, result.Details[0].VoteWeight, 0.001)
+}
+
+func (suite *TopographerTestSuite) TestAddOfflineProviderDoesNotBlock() {
+	ip := net.ParseIP("80.80.80.80").To16()
+	offlineMock := &OfflineProviderMock{}
+	baseDir, _ := ioutil.TempDir("", "topo_test_")
+	added := make(chan error, 1)
+
+	defer os.RemoveAll(baseDir)
+
+	offlineMock.On("Name").Return("o1")
+	offlineMock.On("Shutdown").Maybe()
+	offlineMock.On("BaseDirectory").Return(baseDir).Maybe()
+	offlineMock.On("UpdateEvery").Return(time.Minute).Maybe()
+	offlineMock.On("Download", mock.Anything, mock.Anything).Return(nil).Maybe()
+	offlineMock.On("Open", mock.Anything).Return(nil).After(500 * time.Millisecond).Maybe()
+	suite.providerMocks[0].On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("RU"),
+	}, nil).Once()
+
+	go func() {
+		added <- suite.t.AddProvider(offlineMock)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	started := time.Now()
+	result, err := suite.t.Resolve(context.Background(), ip, []string{"p0"})
+
+	suite.NoError(err)
+	suite.Equal("RU", result.Country.Alpha2Code)
+	suite.Less(int64(time.Since(started)), int64(250*time.Millisecond))
+	suite.NoError(<-added)
+	suite.Len(suite.t.UsageStats(), 4)
+}
+
+func (suite *TopographerTestSuite) TestAddOfflineProvider() {
+	offlineMock := &OfflineProviderMock{}
+	baseDir, _ := ioutil.TempDir("", "topo_test_")
+
+	defer os.RemoveAll(baseDir)
+
+	offlineMock.On("Name").Return("o1")
+	offlineMock.On("Shutdown").Once()
+	offlineMock.On("BaseDirectory").Return(baseDir).Maybe()
+	offlineMock.On("UpdateEvery").Return(time.Minute).Maybe()
+	offlineMock.On("Download", mock.Anything, mock.Anything).Return(nil).Maybe()
+	offlineMock.On("Open", mock.Anything).Return(nil).Maybe()
+
+	defer offlineMock.AssertExpectations(suite.T())
+
+	suite.NoError(suite.t.AddProvider(offlineMock))
+	suite.Len(suite.t.Readiness().Providers, 4)
+	suite.NoError(suite.t.RemoveProvider("o1"))
+	suite.Len(suite.t.Readiness().Providers, 3)
+}
+
+func (suite *TopographerTestSuite) TestRemoveProvider() {
+	suite.NoError(suite.t.RemoveProvider("o0"))
+	suite.EqualError(suite.t.RemoveProvider("o0"), "provider o0 is unknown")
+
+	_, err := suite.t.Resolve(context.Background(), net.ParseIP("80.80.80.80"), []string{"o0"})
+
+	suite.EqualError(err, "provider o0 is unknown")
+
+	stats := suite.t.UsageStats()
+
+	suite.Len(stats, 2)
+	suite.Equal("p0", stats[0].Name)
+	suite.Equal("p1", stats[1].Name)
+}
+
+func (suite *TopographerTestSuite) TestRemoveProviderDrains() {
+	var lookupsDone int32
+
+	ip := net.ParseIP("80.80.80.80").To16()
+	resolved := make(chan topolib.ResolveResult, 1)
+
+	suite.providerMocks[0].On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("RU"),
+	}, nil).After(200 * time.Millisecond).Run(func(args mock.Arguments) {
+		atomic.AddInt32(&lookupsDone, 1)
+	}).Once()
+
+	go func() {
+		result, _ := suite.t.Resolve(context.Background(), ip, []string{"p0"})
+		resolved <- result
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	suite.NoError(suite.t.RemoveProvider("p0"))
+	suite.EqualValues(1, atomic.LoadInt32(&lookupsDone))
+	suite.Equal("RU", (<-resolved).Country.Alpha2Code)
+}
+
+func (suite *TopographerTestSuite) TestStreamDoesNotBlockProviders() {
+	ip := net.ParseIP("80.80.80.80").To16()
+	providerMock := &ProviderMock{}
+	ips := make(chan net.IP)
+	done := make(chan struct{})
+
+	defer close(ips)
+
+	providerMock.On("Name").Return("p2")
+	suite.providerMocks[0].On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("RU"),
+	}, nil).Once()
+
+	_, err := suite.t.ResolveStream(context.Background(), ips, nil)
+
+	suite.NoError(err)
+
+	go func() {
+		defer close(done)
+
+		suite.NoError(suite.t.AddProvider(providerMock))
+		suite.NoError(suite.t.RemoveProvider("p1"))
+
+		result, err := suite.t.Resolve(context.Background(), ip, []string{"p0"})
+
+		suite.NoError(err)
+		suite.Equal("RU", result.Country.Alpha2Code)
+		suite.Len(suite.t.Readiness().Providers, 3)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		suite.FailNow("providers cannot be changed while stream is open")
+	}
+}
+
+func (suite *TopographerTestSuite) TestReplaceProvider() {
+	ip := net.ParseIP("80.80.80.80").To16()
+	providerMock := &ProviderMock{}
+
+	providerMock.On("Name").Return("p0")
+	providerMock.On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("RU"),
+	}, nil).Once()
+
+	defer providerMock.AssertExpectations(suite.T())
+
+	suite.NoError(suite.t.ReplaceProvider(providerMock, topolib.ProviderVoteWeight(2)))
+
+	result, err := suite.t.Resolve(context.Background(), ip, []string{"p0"})
+
+	suite.NoError(err)
+	suite.Equal("RU", result.Country.Alpha2Code)
+	suite.InEpsilon(2.0, result.Details[0].VoteWeight, 0.001)
+}
+
+func (suite *TopographerTestSuite) TestReplaceProviderUnknown() {
+	providerMock := &ProviderMock{}
+
+	providerMock.On("Name").Return("p2")
+
+	suite.EqualError(suite.t.ReplaceProvider(providerMock), "provider p2 is unknown")
+}
+
+func (suite *TopographerTestSuite) TestReplaceOfflineProvider() {
+	offlineMock := &OfflineProviderMock{}
+
+	offlineMock.On("Name").Return("o0")
+	offlineMock.On("Shutdown").Once()
+	offlineMock.On("BaseDirectory").Return(suite.tmpDir).Maybe()
+	offlineMock.On("UpdateEvery").Return(time.Minute).Maybe()
+	offlineMock.On("Download", mock.Anything, mock.Anything).Return(nil).Maybe()
+	offlineMock.On("Open", mock.Anything).Return(nil).Maybe()
+
+	suite.NoError(suite.t.ReplaceProvider(offlineMock))
+	suite.Len(suite.t.UsageStats(), 3)
+
+	suite.t.Shutdown()
+	offlineMock.AssertExpectations(suite.T())
+}
+
+func (suite *TopographerTestSuite) TestReplaceProviderCannotStart() {
+	offlineMock := &OfflineProviderMock{}
+
+	offlineMock.On("Name").Return("o0")
+	offlineMock.On("BaseDirectory").Return(filepath.Join(suite.tmpDir, "unknown"))
+
+	defer offlineMock.AssertExpectations(suite.T())
+
+	suite.Error(suite.t.ReplaceProvider(offlineMock))
+	suite.Len(suite.t.UsageStats(), 3)
+	suite.Len(suite.t.Readiness().Providers, 3)
+}
+
+func (suite *TopographerTestSuite) TestReplaceProviderCannotStartKeepsSettings() {
+	ip := net.ParseIP("80.80.80.80").To16()
+	offlineMock := &OfflineProviderMock{}
+
+	offlineMock.On("Name").Return("o0")
+	offlineMock.On("BaseDirectory").Return(filepath.Join(suite.tmpDir, "unknown"))
+	suite.offlineProviderMocks[0].On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("RU"),
+	}, nil).Once()
+
+	defer offlineMock.AssertExpectations(suite.T())
+
+	suite.Error(suite.t.ReplaceProvider(offlineMock,
+		topolib.ProviderVoteWeight(5),
+		topolib.ProviderTimeout(time.Nanosecond),
+		topolib.ProviderTier(1)))
+
+	result, err := suite.t.Resolve(context.Background(), ip, []string{"o0"})
+
+	suite.NoError(err)
+	suite.Equal("RU", result.Country.Alpha2Code)
+	suite.Len(result.Details, 1)
+	suite.False(result.Details[0].TimedOut)
+	suite.InEpsilon(topolib.DefaultVoteWeight, result.Details[0].VoteWeight, 0.001)
+}
+
+func (suite *TopographerTestSuite) TestAddRemoveProviderShutdown() {
+	suite.t.Shutdown()
+
+	suite.True(errors.Is(suite.t.AddProvider(&ProviderMock{}), topolib.ErrTopographerShutdown))
+	suite.True(errors.Is(suite.t.RemoveProvider("p0"), topolib.ErrTopographerShutdown))
+	suite.True(errors.Is(suite.t.ReplaceProvider(suite.providerMocks[0]), topolib.ErrTopographerShutdown))
+}
+
+func (suite *TopographerTestSuite) TestReadinessRequiredRemoved() {
+	topo, err := topolib.NewTopographer(
+		[]topolib.Provider{suite.providerMocks[0], suite.providerMocks[1]},
+		suite.logMock, 10, topolib.WithReadiness(1, "p0"))
+
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	suite.True(topo.Readiness().Ready)
+	suite.NoError(topo.RemoveProvider("p0"))
+	suite.False(topo.Readiness().Ready)
+}
+
+func (suite *TopographerTestSuite) setupSlowProvider(ip net.IP) []topolib.Provider {
+	suite.providerMocks[0].On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("RU"),
+	}, nil).Once()
+	suite.providerMocks[1].On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("UA"),
+	}, nil).After(500 * time.Millisecond).Once()
+
+	return []topolib.Provider{suite.providerMocks[0], suite.providerMocks[1]}
+}
+
+func (suite *TopographerTestSuite) checkSlowProviderTimedOut(topo *topolib.Topographer, ip net.IP) {
+	started := time.Now()
+	result, err := topo.Resolve(context.Background(), ip, nil)
+
+	suite.NoError(err)
+	suite.Less(int64(time.Since(started)), int64(400*time.Millisecond))
+	suite.Equal("RU", result.Country.Alpha2Code)
+	suite.Len(result.Details, 2)
+	suite.False(result.Details[0].TimedOut)
+	suite.True(result.Details[1].TimedOut)
+	suite.Equal("p1", result.Details[1].ProviderName)
+	suite.False(result.Details[1].CountryCode.Known())
+
+	// wait until slow lookup is finished so mock expectations are met
+	time.Sleep(500 * time.Millisecond)
+}
+
+func (suite *TopographerTestSuite) TestResolveLookupDeadline() {
+	ip := net.ParseIP("80.80.80.80").To16()
+	topo, err := topolib.NewTopographer(suite.setupSlowProvider(ip), suite.logMock, 10,
+		topolib.WithLookupDeadline(100*time.Millisecond))
+
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	suite.checkSlowProviderTimedOut(topo, ip)
+	suite.Equal(map[string]uint64{topolib.ErrorClassTimeout: 1},
+		topo.UsageStats()[1].FailuresByClass())
+}
+
+func (suite *TopographerTestSuite) TestResolveProviderTimeout() {
+	ip := net.ParseIP("80.80.80.80").To16()
+	topo, err := topolib.NewTopographer(suite.setupSlowProvider(ip), suite.logMock, 10,
+		topolib.WithProviderTimeout("p1", 100*time.Millisecond))
+
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	suite.checkSlowProviderTimedOut(topo, ip)
+	suite.Equal(map[string]uint64{topolib.ErrorClassTimeout: 1},
+		topo.UsageStats()[1].FailuresByClass())
+}
+
+func (suite *TopographerTestSuite) TestResolveProviderTimeoutCancelsLookup() {
+	ip := net.ParseIP("80.80.80.80").To16()
+	cancelled := make(chan struct{}, 1)
+
+	suite.providerMocks[0].On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("RU"),
+	}, nil).After(300 * time.Millisecond).Once()
+	suite.providerMocks[1].On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("UA"),
+	}, nil).Run(func(args mock.Arguments) {
+		select {
+		case <-args.Get(0).(context.Context).Done():
+			cancelled <- struct{}{}
+		case <-time.After(200 * time.Millisecond):
+		}
+	}).Once()
+
+	topo, err := topolib.NewTopographer(
+		[]topolib.Provider{suite.providerMocks[0], suite.providerMocks[1]}, suite.logMock, 10,
+		topolib.WithProviderTimeout("p1", 100*time.Millisecond),
+		topolib.WithResultCache(100, time.Minute, 0, 0))
+
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	result, err := topo.Resolve(context.Background(), ip, nil)
+
+	suite.NoError(err)
+	suite.Equal("RU", result.Country.Alpha2Code)
+	suite.True(result.Details[1].TimedOut)
+	suite.Len(cancelled, 1)
+
+	stats := topo.UsageStats()
+
+	suite.EqualValues(0, stats[1].Successes())
+	suite.EqualValues(1, stats[1].Failures())
+	suite.Equal(map[string]uint64{topolib.ErrorClassTimeout: 1},
+		stats[1].FailuresByClass())
+
+	// a verdict without timed out provider is not cached, so
+	// providers are asked again.
+	suite.providerMocks[0].On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("RU"),
+	}, nil).Once()
+	suite.providerMocks[1].On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("RU"),
+	}, nil).Once()
+
+	time.Sleep(50 * time.Millisecond)
+
+	result, err = topo.Resolve(context.Background(), ip, nil)
+
+	suite.NoError(err)
+	suite.False(result.Details[1].TimedOut)
+	suite.InEpsilon(1.0, result.CountryConfidence, 0.001)
+}
+
+func (suite *TopographerTestSuite) TestResolveEarlyVerdictQuorum() {
+	ip := net.ParseIP("80.80.80.80").To16()
+	topo, err := topolib.NewTopographer(suite.setupSlowProvider(ip), suite.logMock, 10,
+		topolib.WithEarlyVerdict(1))
+
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	suite.checkSlowProviderTimedOut(topo, ip)
+	suite.Equal(map[string]uint64{topolib.ErrorClassTimeout: 1},
+		topo.UsageStats()[1].FailuresByClass())
+}
+
+func (suite *TopographerTestSuite) TestResolveEarlyVerdictOffline() {
+	ip := net.ParseIP("80.80.80.80").To16()
+	offlineMock := &OfflineProviderMock{}
+	baseDir, _ := ioutil.TempDir("", "topo_test_")
+
+	defer os.RemoveAll(baseDir)
+
+	offlineMock.On("Name").Return("p0")
+	offlineMock.On("Shutdown").Once()
+	offlineMock.On("BaseDirectory").Return(baseDir).Maybe()
+	offlineMock.On("UpdateEvery").Return(time.Minute).Maybe()
+	offlineMock.On("Download", mock.Anything, mock.Anything).Return(nil).Maybe()
+	offlineMock.On("Open", mock.Anything).Return(nil).Maybe()
+	offlineMock.On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("RU"),
+	}, nil).Once()
+	suite.providerMocks[1].On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("UA"),
+	}, nil).After(500 * time.Millisecond).Once()
+
+	topo, err := topolib.NewTopographer(
+		[]topolib.Provider{offlineMock, suite.providerMocks[1]}, suite.logMock, 10,
+		topolib.WithEarlyVerdict(2))
+
+	suite.NoError(err)
+
+	suite.checkSlowProviderTimedOut(topo, ip)
+	suite.Equal(map[string]uint64{topolib.ErrorClassTimeout: 1},
+		topo.UsageStats()[1].FailuresByClass())
+
+	topo.Shutdown()
+	offlineMock.AssertExpectations(suite.T())
+}
+
+func (suite *TopographerTestSuite) TestResolveTiersConfident() {
+	ip := net.ParseIP("80.80.80.80").To16()
+
+	suite.providerMocks[0].On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("RU"),
+	}, nil).Once()
+
+	topo, err := topolib.NewTopographer(
+		[]topolib.Provider{suite.providerMocks[0], suite.providerMocks[1]}, suite.logMock, 10,
+		topolib.WithProviderTier("p1", 1))
+
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	result, err := topo.Resolve(context.Background(), ip, nil)
+
+	suite.NoError(err)
+	suite.Equal("RU", result.Country.Alpha2Code)
+	suite.Len(result.Details, 1)
+	suite.Equal("p0", result.Details[0].ProviderName)
+}
+
+func (suite *TopographerTestSuite) TestResolveTiersNothingFound() {
+	ip := net.ParseIP("80.80.80.80").To16()
+
+	suite.providerMocks[0].On("Lookup", mock.Anything, ip).
+		Return(topolib.ProviderLookupResult{}, topolib.ErrIPNotFound).
+		Once()
+	suite.providerMocks[1].On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("RU"),
+	}, nil).Once()
+
+	topo, err := topolib.NewTopographer(
+		[]topolib.Provider{suite.providerMocks[0], suite.providerMocks[1]}, suite.logMock, 10,
+		topolib.WithProviderTier("p0", -1))
+
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	result, err := topo.Resolve(context.Background(), ip, nil)
+
+	suite.NoError(err)
+	suite.Equal("RU", result.Country.Alpha2Code)
+	suite.Len(result.Details, 2)
+}
+
+func (suite *TopographerTestSuite) TestResolveTiersDisagree() {
+	ip := net.ParseIP("80.80.80.80").To16()
+	providerMock := &ProviderMock{}
+
+	providerMock.On("Name").Return("p2")
+	providerMock.On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("UA"),
+	}, nil).Once()
+	suite.providerMocks[0].On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("RU"),
+	}, nil).Once()
+	suite.providerMocks[1].On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+		CountryCode: topolib.Alpha2ToCountryCode("UA"),
+	}, nil).Once()
+
+	defer providerMock.AssertExpectations(suite.T())
+
+	topo, err := topolib.NewTopographer(
+		[]topolib.Provider{suite.providerMocks[0], suite.providerMocks[1], providerMock},
+		suite.logMock, 10,
+		topolib.WithProviderTier("p2", 1),
+		topolib.WithTierConfidence(0.6))
+
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	result, err := topo.Resolve(context.Background(), ip, nil)
+
+	suite.NoError(err)
+	suite.Equal("UA", result.Country.Alpha2Code)
+	suite.Len(result.Details, 3)
+}
+
+func (suite *TopographerTestSuite) TestReadinessRequiredUnknown() {
+	_, err := topolib.NewTopographer([]topolib.Provider{suite.providerMocks[0]},
+		suite.logMock, 10, topolib.WithReadiness(1, "u"))
+
+	suite.EqualError(err, "required provider u is unknown")
+}
+
+func (suite *TopographerTestSuite) TestReadinessDatabaseNotReady() {
+	offlineMock := &OfflineProviderMock{}
+	tmpDir, _ := ioutil.TempDir("", "topo_test_")
+
+	defer os.RemoveAll(tmpDir)
+
+	offlineMock.On("Name").Return("o").Maybe()
+	offlineMock.On("Shutdown").Twice()
+	offlineMock.On("BaseDirectory").Return(tmpDir).Maybe()
+	offlineMock.On("UpdateEvery").Return(time.Minute).Maybe()
+	offlineMock.On("Download", mock.Anything, mock.Anything).Return(errors.New("err")).Maybe()
+
+	topo, err := topolib.NewTopographer(
+		[]topolib.Provider{suite.providerMocks[0], offlineMock},
+		suite.logMock, 10)
+	suite.NoError(err)
+
+	readiness := topo.Readiness()
+
+	suite.True(readiness.Ready)
+	suite.Equal(1, readiness.ReadyProviders)
+	suite.Equal([]topolib.ProviderReadiness{
+		{Name: "o", Status: topolib.ProviderStatusDatabaseNotReady},
+		{Name: "p0", Ready: true, Status: topolib.ProviderStatusAvailable},
+	}, readiness.Providers)
+
+	topo.Shutdown()
+
+	topo, err = topolib.NewTopographer(
+		[]topolib.Provider{suite.providerMocks[0], offlineMock},
+		suite.logMock, 10, topolib.WithReadiness(0, "o"))
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	readiness = topo.Readiness()
+
+	suite.False(readiness.Ready)
+	suite.Equal(0, readiness.MinProviders)
+	suite.Equal(1, readiness.ReadyProviders)
+	suite.True(readiness.Providers[0].Required)
+	suite.False(readiness.Providers[0].Ready)
+}
+
+func (suite *TopographerTestSuite) TestReadinessCircuitBreaker() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := topolib.NewHTTPClient(server.Client(), "test", time.Millisecond, 1,
+		1, time.Minute, time.Minute)
+	topo, err := topolib.NewTopographer(
+		[]topolib.Provider{suite.providerMocks[0], suite.providerMocks[1]},
+		suite.logMock, 10,
+		topolib.WithProviderHTTPClient("p0", client),
+		topolib.WithReadiness(2))
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	readiness := topo.Readiness()
+
+	suite.True(readiness.Ready)
+	suite.Equal(topolib.ProviderStatusCircuitBreakerClosed, readiness.Providers[0].Status)
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		_, err = client.Do(req)
+		suite.Error(err)
+	}
+
+	readiness = topo.Readiness()
+
+	suite.False(readiness.Ready)
+	suite.Equal(1, readiness.ReadyProviders)
+	suite.Equal(topolib.ProviderStatusCircuitBreakerOpened, readiness.Providers[0].Status)
+	suite.False(readiness.Providers[0].Ready)
+	suite.True(readiness.Providers[1].Ready)
+}
+
+func (suite *TopographerTestSuite) TestHTTPClientState() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	logMock := &CircuitBreakerLoggerMock{}
+	client := topolib.NewHTTPClient(server.Client(), "test", time.Millisecond, 1,
+		1, time.Minute, time.Minute)
+
+	logMock.On("CircuitBreakerStateChanged", "p0",
+		topolib.CircuitBreakerStateClosed, topolib.CircuitBreakerStateOpened).Once()
+
+	topo, err := topolib.NewTopographer(
+		[]topolib.Provider{suite.providerMocks[0], suite.providerMocks[1]},
+		logMock, 10,
+		topolib.WithProviderHTTPClient("p0", client))
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		_, err = client.Do(req)
+		suite.Error(err)
+	}
+
+	stats := topo.UsageStats()
+
+	state, ok := stats[0].HTTPClientState()
+
+	suite.True(ok)
+	suite.Equal(topolib.CircuitBreakerStateOpened, state.CircuitBreakerState)
+	suite.True(state.CircuitBreakerHalfOpenIn > 0)
+
+	_, ok = stats[1].HTTPClientState()
+
+	suite.False(ok)
+
+	logMock.AssertExpectations(suite.T())
+}
+
+func (suite *TopographerTestSuite) TestRemoveProviderForgetsState() {
+	ip := net.ParseIP("80.80.80.80").To16()
+	client := topolib.NewHTTPClient(http.DefaultClient, "test", time.Millisecond, 1,
+		1, time.Minute, time.Minute)
+	providers := []topolib.Provider{}
+
+	for _, v := range suite.providerMocks {
+		v.On("Lookup", mock.Anything, ip).Return(topolib.ProviderLookupResult{
+			CountryCode: topolib.Alpha2ToCountryCode("NL"),
+		}, nil).Once()
+
+		providers = append(providers, v)
+	}
+
+	topo, err := topolib.NewTopographer(providers, suite.logMock, 10,
+		topolib.WithProviderHTTPClient("p0", client))
+	suite.NoError(err)
+
+	defer topo.Shutdown()
+
+	_, err = topo.Resolve(context.Background(), ip, nil)
+
+	suite.NoError(err)
+	suite.NoError(topo.RemoveProvider("p0"))
+
+	metrics := &strings.Builder{}
+
+	suite.NoError(topo.WriteMetrics(metrics))
+	suite.NotContains(metrics.String(), `provider="p0"`)
+	suite.Equal(topolib.AgreementStats{
+		Providers: []topolib.ProviderAgreement{
+			{Name: "p1", CountryAgreed: 1},
+		},
+		CountryDisagreements: []topolib.ProviderPairDisagreement{},
+	}, topo.AgreementStats())
+}
+
+func (suite *TopographerTestSuite) TestReadinessShutdown() {
+	suite.t.Shutdown()
+
+	suite.False(suite.t.Readiness().Ready)
+}
+
 func TestTopographer(t *testing.T) {
 	suite.Run(t, &TopographerTestSuite{})
 }
@@ -2,10 +2,20 @@ package topolib
 
 import (
 	"encoding/json"
+	"math"
+	"sort"
 	"sync"
 	"time"
 )
 
+const (
+	// UsageStatsLatencyWindow is a period of time which is used to
+	// calculate latency percentiles of the provider.
+	UsageStatsLatencyWindow = 5 * time.Minute
+
+	usageStatsLatencyCapacity = 1024
+)
+
 // UsageStats collects different counters which could be useful to
 // detect providers which are in used or stale.
 //
@@ -16,11 +26,23 @@ type UsageStats struct {
 	// A name of the provider.
 	Name string
 
-	mutex        sync.Mutex
-	lastUpdated  time.Time
-	lastUsed     time.Time
-	successCount uint64
-	failureCount uint64
+	mutex              sync.Mutex
+	voteWeight         float64
+	lastUpdated        time.Time
+	lastUsed           time.Time
+	successCount       uint64
+	failureCount       uint64
+	updateSuccessCount uint64
+	updateFailureCount uint64
+	lookupDuration     metricsHistogram
+	latencies          usageStatsLatencies
+	failuresByClass    map[string]uint64
+	httpClient         InspectableHTTPClient
+}
+
+// VoteWeight returns a weight of votes of this provider.
+func (u *UsageStats) VoteWeight() float64 {
+	return u.voteWeight
 }
 
 // LastUpdated returns a timestamp when provider was updated last time.
@@ -43,19 +65,104 @@ func (u *UsageStats) Failures() uint64 {
 	return u.failureCount
 }
 
-func (u *UsageStats) notifyUsed(err error) {
+// UpdateSuccesses returns a count of successful database updates.
+func (u *UsageStats) UpdateSuccesses() uint64 {
+	return u.updateSuccessCount
+}
+
+// UpdateFailures returns a count of failed database updates.
+func (u *UsageStats) UpdateFailures() uint64 {
+	return u.updateFailureCount
+}
+
+// HTTPClientState returns a state of circuit breaker and rate limiter
+// of the provider. The second value is false if HTTP client of the
+// provider is unknown (see WithProviderHTTPClient) or does not
+// implement InspectableHTTPClient.
+func (u *UsageStats) HTTPClientState() (HTTPClientState, bool) {
+	if u.httpClient == nil {
+		return HTTPClientState{}, false
+	}
+
+	return u.httpClient.State(), true
+}
+
+// LatencyP50 returns a median latency of lookups within
+// UsageStatsLatencyWindow.
+func (u *UsageStats) LatencyP50() time.Duration {
+	return u.latencyPercentile(0.5)
+}
+
+// LatencyP95 returns 95th percentile of lookup latencies within
+// UsageStatsLatencyWindow.
+func (u *UsageStats) LatencyP95() time.Duration {
+	return u.latencyPercentile(0.95)
+}
+
+// LatencyP99 returns 99th percentile of lookup latencies within
+// UsageStatsLatencyWindow.
+func (u *UsageStats) LatencyP99() time.Duration {
+	return u.latencyPercentile(0.99)
+}
+
+// FailuresByClass returns counts of failed lookups grouped by error
+// class. Keys are ErrorClass* constants.
+func (u *UsageStats) FailuresByClass() map[string]uint64 {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	rv := make(map[string]uint64, len(u.failuresByClass))
+
+	for k, v := range u.failuresByClass {
+		rv[k] = v
+	}
+
+	return rv
+}
+
+func (u *UsageStats) latencyPercentile(percentile float64) time.Duration {
 	now := time.Now()
 
 	u.mutex.Lock()
 	defer u.mutex.Unlock()
 
+	return u.latencies.percentile(now, percentile)
+}
+
+func (u *UsageStats) notifyUsed(err error, duration time.Duration) {
+	now := time.Now()
+	errClass := ""
+
+	if err != nil {
+		errClass = classifyError(err)
+	}
+
+	u.lookupDuration.observe(duration)
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
 	u.lastUsed = now
 
+	// fail-fast errors do not reach a provider so they would only
+	// skew latencies down.
+	if errClass != ErrorClassCircuitOpen && errClass != ErrorClassNotReady {
+		u.latencies.add(now, duration)
+	}
+
 	if err == nil {
 		u.successCount += 1
-	} else {
-		u.failureCount += 1
+
+		return
+	}
+
+	u.failureCount += 1
+
+	if u.failuresByClass == nil {
+		u.failuresByClass = map[string]uint64{}
 	}
+
+	u.failuresByClass[errClass]++
 }
 
 func (u *UsageStats) notifyUpdated(now time.Time) {
@@ -65,12 +172,66 @@ func (u *UsageStats) notifyUpdated(now time.Time) {
 	u.lastUpdated = now
 }
 
+func (u *UsageStats) notifyUpdateResult(err error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if err == nil {
+		u.updateSuccessCount += 1
+	} else {
+		u.updateFailureCount += 1
+	}
+}
+
+func (u *UsageStats) lookupCounts() (uint64, uint64) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	return u.successCount, u.failureCount
+}
+
+func (u *UsageStats) updateCounts() (uint64, uint64) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	return u.updateSuccessCount, u.updateFailureCount
+}
+
+func (u *UsageStats) lastUpdatedTime() time.Time {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	return u.lastUpdated
+}
+
 // MarshalJSON to conform json.Marshaller interface.
 func (u *UsageStats) MarshalJSON() ([]byte, error) {
-	var lastUpdatedTime, lastUsedTime int64
+	var (
+		lastUpdatedTime, lastUsedTime int64
+		httpClient                    *usageStatsHTTPClient
+	)
+
+	if state, ok := u.HTTPClientState(); ok {
+		httpClient = &usageStatsHTTPClient{
+			CircuitBreakerState:      state.CircuitBreakerState,
+			CircuitBreakerFailures:   state.CircuitBreakerFailures,
+			CircuitBreakerHalfOpenIn: state.CircuitBreakerHalfOpenIn.Seconds(),
+			RateLimiterTokens:        state.RateLimiterTokens,
+			RateLimiterBurst:         state.RateLimiterBurst,
+		}
+	}
+
+	now := time.Now()
+	failuresByClass := u.FailuresByClass()
 
 	u.mutex.Lock()
 
+	latency := usageStatsLatency{
+		P50: u.latencies.percentile(now, 0.5).Seconds(),
+		P95: u.latencies.percentile(now, 0.95).Seconds(),
+		P99: u.latencies.percentile(now, 0.99).Seconds(),
+	}
+
 	if !u.lastUpdated.IsZero() {
 		lastUpdatedTime = u.lastUpdated.Unix()
 	}
@@ -80,20 +241,98 @@ func (u *UsageStats) MarshalJSON() ([]byte, error) {
 	}
 
 	rawStruct := struct {
-		Name         string `json:"name"`
-		LastUpdated  int64  `json:"last_updated"`
-		LastUsed     int64  `json:"last_used"`
-		SuccessCount uint64 `json:"success_count"`
-		FailureCount uint64 `json:"failure_count"`
+		Name            string                `json:"name"`
+		VoteWeight      float64               `json:"vote_weight"`
+		LastUpdated     int64                 `json:"last_updated"`
+		LastUsed        int64                 `json:"last_used"`
+		SuccessCount    uint64                `json:"success_count"`
+		FailureCount    uint64                `json:"failure_count"`
+		FailuresByClass map[string]uint64     `json:"failures_by_class"`
+		Latency         usageStatsLatency     `json:"latency"`
+		HTTPClient      *usageStatsHTTPClient `json:"http_client,omitempty"`
 	}{
-		Name:         u.Name,
-		LastUpdated:  lastUpdatedTime,
-		LastUsed:     lastUsedTime,
-		SuccessCount: u.successCount,
-		FailureCount: u.failureCount,
+		Name:            u.Name,
+		VoteWeight:      u.voteWeight,
+		LastUpdated:     lastUpdatedTime,
+		LastUsed:        lastUsedTime,
+		SuccessCount:    u.successCount,
+		FailureCount:    u.failureCount,
+		FailuresByClass: failuresByClass,
+		Latency:         latency,
+		HTTPClient:      httpClient,
 	}
 
 	u.mutex.Unlock()
 
 	return json.Marshal(&rawStruct)
 }
+
+type usageStatsLatency struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+type usageStatsHTTPClient struct {
+	CircuitBreakerState      string  `json:"circuit_breaker_state"`
+	CircuitBreakerFailures   uint32  `json:"circuit_breaker_failures"`
+	CircuitBreakerHalfOpenIn float64 `json:"circuit_breaker_half_open_in"`
+	RateLimiterTokens        float64 `json:"rate_limiter_tokens"`
+	RateLimiterBurst         int     `json:"rate_limiter_burst"`
+}
+
+// usageStatsLatencies is a ring buffer of latest lookup latencies.
+type usageStatsLatencies struct {
+	samples []usageStatsLatencySample
+	next    int
+}
+
+type usageStatsLatencySample struct {
+	at       time.Time
+	duration time.Duration
+}
+
+func (u *usageStatsLatencies) add(now time.Time, duration time.Duration) {
+	sample := usageStatsLatencySample{
+		at:       now,
+		duration: duration,
+	}
+
+	if len(u.samples) < usageStatsLatencyCapacity {
+		u.samples = append(u.samples, sample)
+
+		return
+	}
+
+	u.samples[u.next] = sample
+	u.next = (u.next + 1) % usageStatsLatencyCapacity
+}
+
+// percentile returns a latency percentile using nearest-rank method.
+// Only samples within UsageStatsLatencyWindow are taken into account.
+// If there are no such samples, 0 is returned.
+func (u *usageStatsLatencies) percentile(now time.Time, percentile float64) time.Duration {
+	durations := make([]time.Duration, 0, len(u.samples))
+	threshold := now.Add(-UsageStatsLatencyWindow)
+
+	for _, v := range u.samples {
+		if v.at.After(threshold) {
+			durations = append(durations, v.duration)
+		}
+	}
+
+	if len(durations) == 0 {
+		return 0
+	}
+
+	sort.Slice(durations, func(i, j int) bool {
+		return durations[i] < durations[j]
+	})
+
+	idx := int(math.Ceil(percentile*float64(len(durations)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+
+	return durations[idx]
+}
@@ -10,11 +10,19 @@ import (
 )
 
 type usageStatsJSON struct {
-	Name         string `json:"name"`
-	LastUpdated  int64  `json:"last_updated"`
-	LastUsed     int64  `json:"last_used"`
-	SuccessCount uint64 `json:"success_count"`
-	FailureCount uint64 `json:"failure_count"`
+	Name         string  `json:"name"`
+	VoteWeight   float64 `json:"vote_weight"`
+	LastUpdated  int64   `json:"last_updated"`
+	LastUsed     int64   `json:"last_used"`
+	SuccessCount uint64  `json:"success_count"`
+	FailureCount uint64  `json:"failure_count"`
+
+	FailuresByClass map[string]uint64 `json:"failures_by_class"`
+	Latency         struct {
+		P50 float64 `json:"p50"`
+		P95 float64 `json:"p95"`
+		P99 float64 `json:"p99"`
+	} `json:"latency"`
 }
 
 type UsageStatsTestSuite struct {
@@ -25,7 +33,8 @@ type UsageStatsTestSuite struct {
 
 func (suite *UsageStatsTestSuite) SetupTest() {
 	suite.u = &UsageStats{
-		Name: "test",
+		Name:       "test",
+		voteWeight: DefaultVoteWeight,
 	}
 }
 
@@ -47,6 +56,7 @@ func (suite *UsageStatsTestSuite) Verify(lastUsed, lastUpdated time.Time,
 
 	suite.NoError(json.Unmarshal(v, &raw))
 	suite.Equal("test", raw.Name)
+	suite.Equal(DefaultVoteWeight, raw.VoteWeight)
 	suite.EqualValues(success, raw.SuccessCount)
 	suite.EqualValues(failure, raw.FailureCount)
 	suite.VerifyTime(lastUsed, raw.LastUsed)
@@ -58,16 +68,16 @@ func (suite *UsageStatsTestSuite) TestEmpty() {
 }
 
 func (suite *UsageStatsTestSuite) TestUsed() {
-	suite.u.notifyUsed(nil)
+	suite.u.notifyUsed(nil, time.Millisecond)
 	suite.Verify(time.Now(), time.Time{}, 1, 0)
 
-	suite.u.notifyUsed(io.EOF)
+	suite.u.notifyUsed(io.EOF, time.Millisecond)
 	suite.Verify(time.Now(), time.Time{}, 1, 1)
 
-	suite.u.notifyUsed(io.EOF)
+	suite.u.notifyUsed(io.EOF, time.Millisecond)
 	suite.Verify(time.Now(), time.Time{}, 1, 2)
 
-	suite.u.notifyUsed(nil)
+	suite.u.notifyUsed(nil, time.Millisecond)
 	suite.Verify(time.Now(), time.Time{}, 2, 2)
 }
 
@@ -76,6 +86,92 @@ func (suite *UsageStatsTestSuite) TestUpdated() {
 	suite.Verify(time.Time{}, time.Now(), 0, 0)
 }
 
+func (suite *UsageStatsTestSuite) TestUpdateResult() {
+	suite.u.notifyUpdateResult(nil)
+	suite.u.notifyUpdateResult(io.EOF)
+	suite.u.notifyUpdateResult(nil)
+
+	suite.EqualValues(2, suite.u.UpdateSuccesses())
+	suite.EqualValues(1, suite.u.UpdateFailures())
+}
+
+func (suite *UsageStatsTestSuite) TestLookupDuration() {
+	suite.u.notifyUsed(nil, 20*time.Millisecond)
+	suite.u.notifyUsed(io.EOF, 2*time.Second)
+
+	snapshot := suite.u.lookupDuration.snapshot()
+
+	suite.EqualValues(2, snapshot.count)
+	suite.InDelta(2.02, snapshot.sum, 0.0001)
+	suite.EqualValues(0, snapshot.counts[1])
+	suite.EqualValues(1, snapshot.counts[2])
+	suite.EqualValues(2, snapshot.counts[len(snapshot.counts)-1])
+}
+
+func (suite *UsageStatsTestSuite) TestLatencyPercentiles() {
+	suite.EqualValues(0, suite.u.LatencyP50())
+
+	for i := 1; i <= 100; i++ {
+		suite.u.notifyUsed(nil, time.Duration(i)*time.Millisecond)
+	}
+
+	suite.u.notifyUsed(ErrCircuitBreakerOpened, 0)
+	suite.u.notifyUsed(ErrDatabaseIsNotReadyYet, 0)
+
+	suite.Equal(50*time.Millisecond, suite.u.LatencyP50())
+	suite.Equal(95*time.Millisecond, suite.u.LatencyP95())
+	suite.Equal(99*time.Millisecond, suite.u.LatencyP99())
+
+	v, err := json.Marshal(suite.u)
+
+	suite.NoError(err)
+
+	raw := usageStatsJSON{}
+
+	suite.NoError(json.Unmarshal(v, &raw))
+	suite.InDelta(0.05, raw.Latency.P50, 0.0001)
+	suite.InDelta(0.095, raw.Latency.P95, 0.0001)
+	suite.InDelta(0.099, raw.Latency.P99, 0.0001)
+}
+
+func (suite *UsageStatsTestSuite) TestLatencyWindow() {
+	now := time.Now()
+
+	for i := 0; i < usageStatsLatencyCapacity+10; i++ {
+		suite.u.latencies.add(now.Add(-2*UsageStatsLatencyWindow), time.Hour)
+	}
+
+	suite.u.latencies.add(now, time.Millisecond)
+
+	suite.Equal(time.Millisecond, suite.u.latencies.percentile(now, 0.99))
+	suite.Len(suite.u.latencies.samples, usageStatsLatencyCapacity)
+}
+
+func (suite *UsageStatsTestSuite) TestFailuresByClass() {
+	suite.u.notifyUsed(nil, time.Millisecond)
+	suite.u.notifyUsed(io.EOF, time.Millisecond)
+	suite.u.notifyUsed(ErrCircuitBreakerOpened, 0)
+	suite.u.notifyUsed(ErrCircuitBreakerOpened, 0)
+	suite.u.notifyUsed(&HTTPStatusError{StatusCode: 500}, time.Millisecond)
+
+	expected := map[string]uint64{
+		ErrorClassOther:       1,
+		ErrorClassCircuitOpen: 2,
+		ErrorClassHTTPStatus:  1,
+	}
+
+	suite.Equal(expected, suite.u.FailuresByClass())
+
+	v, err := json.Marshal(suite.u)
+
+	suite.NoError(err)
+
+	raw := usageStatsJSON{}
+
+	suite.NoError(json.Unmarshal(v, &raw))
+	suite.Equal(expected, raw.FailuresByClass)
+}
+
 func TestUsageStats(t *testing.T) {
 	suite.Run(t, &UsageStatsTestSuite{})
 }
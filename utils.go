@@ -15,6 +15,16 @@ import (
 	"github.com/9seconds/topographer/topolib"
 )
 
+// makeReloadChannel returns a channel which gets a value each time when
+// topographer is asked to reload its config (SIGHUP).
+func makeReloadChannel() <-chan os.Signal {
+	sigChan := make(chan os.Signal, 1)
+
+	signal.Notify(sigChan, syscall.SIGHUP)
+
+	return sigChan
+}
+
 func makeRootContext() (context.Context, context.CancelFunc) {
 	rootCtx, cancel := context.WithCancel(context.Background())
 
@@ -31,79 +41,160 @@ func makeRootContext() (context.Context, context.CancelFunc) {
 	return rootCtx, cancel
 }
 
-func makeProviders(conf *config) ([]topolib.Provider, error) {
+func makeHTTPClients(conf *config) map[string]topolib.HTTPClient {
+	rv := make(map[string]topolib.HTTPClient, len(conf.GetProviders()))
+
+	for _, v := range conf.GetProviders() {
+		rv[v.GetName()] = makeNewHTTPClient(v)
+	}
+
+	return rv
+}
+
+func makeProviders(conf *config, httpClients map[string]topolib.HTTPClient) ([]topolib.Provider, error) {
 	rv := make([]topolib.Provider, 0, len(conf.GetProviders()))
 
 	for _, v := range conf.GetProviders() {
-		httpClient := makeNewHTTPClient(v)
-
-		switch v.GetName() {
-		case providers.NameDBIPLite:
-			baseDir, err := ensureDir(conf, v)
-			if err != nil {
-				return nil, fmt.Errorf("cannot create base directory for dbip provider: %w", err)
-			}
-
-			rv = append(rv, providers.NewDBIPLite(httpClient, v.GetUpdateEvery(), baseDir))
-		case providers.NameIP2C:
-			rv = append(rv, providers.NewIP2C(httpClient))
-		case providers.NameIP2Location:
-			baseDir, err := ensureDir(conf, v)
-			if err != nil {
-				return nil, fmt.Errorf("cannot create base directory for ip2location provider: %w", err)
-			}
-
-			params := v.GetSpecificParameters()
-
-			prov, err := providers.NewIP2Location(httpClient, v.GetUpdateEvery(), baseDir, params["auth_token"], params["db_code"])
-			if err != nil {
-				return nil, fmt.Errorf("cannot create ip2location provider: %w", err)
-			}
-
-			rv = append(rv, prov)
-		case providers.NameIPInfo:
-			token := v.GetSpecificParameters()["auth_token"]
-			rv = append(rv, providers.NewIPInfo(httpClient, token))
-		case providers.NameIPStack:
-			params := v.GetSpecificParameters()
-
-			prov, err := providers.NewIPStack(httpClient,
-				params["auth_token"], boolParam(params["secure"]))
-			if err != nil {
-				return nil, fmt.Errorf("cannot create ipstack provider: %w", err)
-			}
-
-			rv = append(rv, prov)
-		case providers.NameMaxmindLite:
-			baseDir, err := ensureDir(conf, v)
-			if err != nil {
-				return nil, fmt.Errorf("cannot create base directory for maxmind provider: %w", err)
-			}
-
-			licenseKey := v.GetSpecificParameters()["license_key"]
-
-			prov, err := providers.NewMaxmindLite(httpClient, v.GetUpdateEvery(), baseDir,
-				licenseKey)
-			if err != nil {
-				return nil, fmt.Errorf("cannot create ipstack provider: %w", err)
-			}
-
-			rv = append(rv, prov)
-		case providers.NameSoftware77:
-			baseDir, err := ensureDir(conf, v)
-			if err != nil {
-				return nil, fmt.Errorf("cannot create base directory for sofware77 provider: %w", err)
-			}
-
-			rv = append(rv, providers.NewSoftware77(httpClient, v.GetUpdateEvery(), baseDir))
-		default:
-			return nil, fmt.Errorf("unsupported provider name: %s", v.GetName())
+		prov, err := makeProvider(conf, v, httpClients[v.GetName()])
+		if err != nil {
+			return nil, err
 		}
+
+		rv = append(rv, prov)
 	}
 
 	return rv, nil
 }
 
+func makeProvider(conf *config, v configProvider, httpClient topolib.HTTPClient) (topolib.Provider, error) {
+	switch v.GetName() {
+	case providers.NameDBIPLite:
+		baseDir, err := ensureDir(conf, v)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create base directory for dbip provider: %w", err)
+		}
+
+		return providers.NewDBIPLite(httpClient, v.GetUpdateEvery(), baseDir), nil
+	case providers.NameDBIPASNLite:
+		baseDir, err := ensureDir(conf, v)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create base directory for dbip asn provider: %w", err)
+		}
+
+		return providers.NewDBIPASNLite(httpClient, v.GetUpdateEvery(), baseDir), nil
+	case providers.NameIP2C:
+		return providers.NewIP2C(httpClient), nil
+	case providers.NameIP2Location:
+		baseDir, err := ensureDir(conf, v)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create base directory for ip2location provider: %w", err)
+		}
+
+		params := v.GetSpecificParameters()
+
+		prov, err := providers.NewIP2Location(httpClient, v.GetUpdateEvery(), baseDir, params["auth_token"], params["db_code"])
+		if err != nil {
+			return nil, fmt.Errorf("cannot create ip2location provider: %w", err)
+		}
+
+		return prov, nil
+	case providers.NameIPInfo:
+		token := v.GetSpecificParameters()["auth_token"]
+		return providers.NewIPInfo(httpClient, token), nil
+	case providers.NameIPStack:
+		params := v.GetSpecificParameters()
+
+		prov, err := providers.NewIPStack(httpClient,
+			params["auth_token"], boolParam(params["secure"]))
+		if err != nil {
+			return nil, fmt.Errorf("cannot create ipstack provider: %w", err)
+		}
+
+		return prov, nil
+	case providers.NameMaxmindLite:
+		baseDir, err := ensureDir(conf, v)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create base directory for maxmind provider: %w", err)
+		}
+
+		licenseKey := v.GetSpecificParameters()["license_key"]
+
+		prov, err := providers.NewMaxmindLite(httpClient, v.GetUpdateEvery(), baseDir,
+			licenseKey)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create ipstack provider: %w", err)
+		}
+
+		return prov, nil
+	case providers.NameMaxmindASNLite:
+		baseDir, err := ensureDir(conf, v)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create base directory for maxmind asn provider: %w", err)
+		}
+
+		licenseKey := v.GetSpecificParameters()["license_key"]
+
+		prov, err := providers.NewMaxmindASNLite(httpClient, v.GetUpdateEvery(), baseDir,
+			licenseKey)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create maxmind asn provider: %w", err)
+		}
+
+		return prov, nil
+	case providers.NameSoftware77:
+		baseDir, err := ensureDir(conf, v)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create base directory for sofware77 provider: %w", err)
+		}
+
+		return providers.NewSoftware77(httpClient, v.GetUpdateEvery(), baseDir), nil
+	}
+
+	return nil, fmt.Errorf("unsupported provider name: %s", v.GetName())
+}
+
+func makeTopographerOptions(conf *config, httpClients map[string]topolib.HTTPClient) []topolib.Option {
+	opts := []topolib.Option{}
+
+	if conf.GetMergeMode() == MergeModeGeoCluster {
+		opts = append(opts, topolib.WithMerger(topolib.NewGeoClusterMerger(conf.GetGeoClusterRadius())))
+	}
+
+	for _, v := range conf.GetProviders() {
+		opts = append(opts, topolib.WithVoteWeight(v.GetName(), v.GetVoteWeight()),
+			topolib.WithProviderTimeout(v.GetName(), v.GetSoftTimeout()),
+			topolib.WithProviderTier(v.GetName(), v.GetTier()))
+	}
+
+	for name, client := range httpClients {
+		opts = append(opts, topolib.WithProviderHTTPClient(name, client))
+	}
+
+	opts = append(opts, topolib.WithReadiness(conf.GetReadyMinProviders(), conf.GetReadyProviders()...))
+	opts = append(opts, topolib.WithResultCache(conf.GetResultCacheSize(),
+		conf.GetResultCacheTTL(),
+		conf.GetResultCacheIPv4Prefix(),
+		conf.GetResultCacheIPv6Prefix()))
+	opts = append(opts, topolib.WithNegativeCache(conf.GetNegativeCacheSize(),
+		conf.GetNegativeCacheTTL()))
+
+	opts = append(opts, topolib.WithTierConfidence(conf.GetTierConfidence()))
+
+	if deadline := conf.GetLookupDeadline(); deadline > 0 {
+		opts = append(opts, topolib.WithLookupDeadline(deadline))
+	}
+
+	if conf.UseEarlyVerdict() {
+		opts = append(opts, topolib.WithEarlyVerdict(conf.GetEarlyVerdictQuorum()))
+	}
+
+	if proxies := conf.GetTrustedProxies(); len(proxies) > 0 {
+		opts = append(opts, topolib.WithTrustedProxies(conf.GetTrustedProxyHeader(), proxies...))
+	}
+
+	return opts
+}
+
 func makeNewHTTPClient(conf configProvider) topolib.HTTPClient {
 	jar, err := cookiejar.New(nil)
 	if err != nil {